@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"myapp/internal/config"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: server [command] [flags]
+
+Commands:
+  serve                     Run the HTTP server (default)
+  token mint                Mint a LiveKit access token
+  token identity            Sign a service identity assertion for a user
+  rooms ls | rm <room>      List or delete LiveKit rooms
+  storage ls|put|get|rm     Manage a user's R2 objects
+  apikey hash [key]         Print the SHA-256 hash stored for an API key
+
+Common flags:
+  --config <file>           YAML or TOML config file (overrides APP_CONFIG)
+  --output table|json       Output format (default table)
+  --timeout <duration>      Deadline for remote calls (default 5m)
+
+Run "server <command> -h" for command flags.
+`
+
+func main() {
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches to a subcommand. Without one (or with only flags) the
+// server is started, so existing deployments keep working.
+func run(args []string) error {
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help") {
+		return runServe(args)
+	}
+
+	switch args[0] {
+	case "serve":
+		return runServe(args[1:])
+	case "token":
+		return runToken(args[1:])
+	case "rooms":
+		return runRooms(args[1:])
+	case "storage":
+		return runStorage(args[1:])
+	case "apikey":
+		return runAPIKey(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// commonFlags are accepted by every command
+type commonFlags struct {
+	configFile string
+	output     string
+	timeout    time.Duration
+}
+
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	c := &commonFlags{}
+	fs.StringVar(&c.configFile, "config", "", "path to a YAML or TOML config file (overrides APP_CONFIG)")
+	fs.StringVar(&c.output, "output", "table", "output format: table or json")
+	fs.DurationVar(&c.timeout, "timeout", 5*time.Minute, "deadline for remote calls")
+	return c
+}
+
+func (c *commonFlags) options() config.Options {
+	return config.Options{File: c.configFile}
+}
+
+// load reads and validates the configuration for a command
+func (c *commonFlags) load() (*config.Config, error) {
+	return config.LoadWith(c.options())
+}
+
+// print writes v as indented JSON, or as a table built from headers and rows
+func (c *commonFlags) print(v interface{}, headers []string, rows [][]string) error {
+	switch c.output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q (use table or json)", c.output)
+	}
+}
+
+// subcommand splits "<verb> [args]" and reports a usage error when missing
+func subcommand(name string, args []string, verbs ...string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("usage: server %s <%s>", name, strings.Join(verbs, "|"))
+	}
+	for _, verb := range verbs {
+		if args[0] == verb {
+			return verb, args[1:], nil
+		}
+	}
+	return "", nil, fmt.Errorf("unknown %s command %q (use %s)", name, args[0], strings.Join(verbs, ", "))
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"myapp/internal/config"
+	"myapp/internal/handler"
+	"myapp/internal/livekit"
+	"myapp/internal/serviceauth"
+	"myapp/internal/storage"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// runToken handles "token mint" and "token identity"
+func runToken(args []string) error {
+	verb, args, err := subcommand("token", args, "mint", "identity")
+	if err != nil {
+		return err
+	}
+	if verb == "identity" {
+		return mintIdentity(args)
+	}
+
+	fs := flag.NewFlagSet("token mint", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	room := fs.String("room", "", "room to join (required)")
+	identity := fs.String("identity", "", "participant identity (required)")
+	name := fs.String("name", "", "participant display name")
+	ttl := fs.Duration("ttl", livekit.DefaultTokenTTL, "token validity")
+	roleName := fs.String("role", string(livekit.DefaultRole), "permission preset: host, speaker, viewer, recorder or observer")
+	metadata := fs.String("metadata", "", "participant metadata")
+	attributes := map[string]string{}
+	fs.Func("attr", "participant attribute as key=value (repeatable)", func(value string) error {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("expected key=value")
+		}
+		attributes[key] = val
+		return nil
+	})
+	fs.Parse(args)
+
+	if *room == "" || *identity == "" {
+		return fmt.Errorf("--room and --identity are required")
+	}
+	role, err := livekit.ParseRole(*roleName)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.load()
+	if err != nil {
+		return err
+	}
+
+	// Tokens minted by the operator are trusted, so hosts keep RoomAdmin
+	token, err := livekit.NewClient(cfg, livekit.NewMemoryACLStore()).MintToken(livekit.TokenOptions{
+		Room:       *room,
+		Identity:   *identity,
+		Name:       *name,
+		TTL:        *ttl,
+		Role:       role,
+		Metadata:   *metadata,
+		Attributes: attributes,
+		RoomAdmin:  role == livekit.RoleHost,
+	})
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(*ttl).UTC().Format(time.RFC3339)
+	return common.print(map[string]string{
+		"token":     token,
+		"room":      *room,
+		"identity":  *identity,
+		"role":      string(role),
+		"expiresAt": expiresAt,
+	}, []string{"ROOM", "IDENTITY", "ROLE", "EXPIRES", "TOKEN"}, [][]string{{*room, *identity, string(role), expiresAt, token}})
+}
+
+// mintIdentity signs a service identity assertion, as an internal service
+// would, for calling user-scoped endpoints
+func mintIdentity(args []string) error {
+	fs := flag.NewFlagSet("token identity", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	user := fs.String("user", "", "user ID to assert (required)")
+	kid := fs.String("kid", "", "service key ID from SERVICE_AUTH_KEYS (required)")
+	privateKey := fs.String("private-key", "", "file holding the base64 Ed25519 private key or seed, for ed25519 keys")
+	ttl := fs.Duration("ttl", time.Minute, "assertion validity, at most SERVICE_AUTH_MAX_TTL")
+	fs.Parse(args)
+
+	if *user == "" || *kid == "" {
+		return fmt.Errorf("--user and --kid are required")
+	}
+
+	cfg, err := common.load()
+	if err != nil {
+		return err
+	}
+	if *ttl <= 0 || *ttl > cfg.ServiceAuthMaxTTL {
+		return fmt.Errorf("--ttl must be between 0 and %s", cfg.ServiceAuthMaxTTL)
+	}
+	keys, err := config.ParseServiceKeys(cfg.ServiceAuthKeys)
+	if err != nil {
+		return err
+	}
+
+	var key *config.ServiceKey
+	for i := range keys {
+		if keys[i].ID == *kid {
+			key = &keys[i]
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("no service key %q in SERVICE_AUTH_KEYS", *kid)
+	}
+
+	var signingKey interface{} = key.Key
+	if key.Algorithm == "EdDSA" {
+		if *privateKey == "" {
+			return fmt.Errorf("--private-key is required for ed25519 key %q", *kid)
+		}
+		signingKey, err = readEd25519PrivateKey(*privateKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	assertion, err := serviceauth.Sign(*key, signingKey, *user, cfg.ServiceAuthAudience, *ttl)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(*ttl).UTC().Format(time.RFC3339)
+	return common.print(map[string]string{
+		"header":    serviceauth.Header,
+		"assertion": assertion,
+		"user":      *user,
+		"expiresAt": expiresAt,
+	}, []string{"USER", "EXPIRES", "ASSERTION"}, [][]string{{*user, expiresAt, assertion}})
+}
+
+// readEd25519PrivateKey reads a base64 Ed25519 private key or 32-byte seed
+func readEd25519PrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: not valid base64", path)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("%s: want a %d byte seed or %d byte private key", path, ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
+
+// runRooms handles "rooms ls" and "rooms rm <room>"
+func runRooms(args []string) error {
+	verb, args, err := subcommand("rooms", args, "ls", "rm")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("rooms "+verb, flag.ExitOnError)
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := common.load()
+	if err != nil {
+		return err
+	}
+	rooms := livekit.NewClient(cfg, livekit.NewMemoryACLStore()).RoomService()
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.timeout)
+	defer cancel()
+
+	switch verb {
+	case "ls":
+		res, err := rooms.ListRooms(ctx, &lkproto.ListRoomsRequest{})
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(res.Rooms))
+		for _, room := range res.Rooms {
+			rows = append(rows, []string{
+				room.Name,
+				room.Sid,
+				strconv.FormatUint(uint64(room.NumParticipants), 10),
+				time.Unix(room.CreationTime, 0).UTC().Format(time.RFC3339),
+			})
+		}
+		return common.print(res.Rooms, []string{"NAME", "SID", "PARTICIPANTS", "CREATED"}, rows)
+	default:
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: server rooms rm [flags] <room>")
+		}
+		room := fs.Arg(0)
+		if _, err := rooms.DeleteRoom(ctx, &lkproto.DeleteRoomRequest{Room: room}); err != nil {
+			return err
+		}
+		return common.print(handler.StatusResponse{Status: "deleted"}, []string{"ROOM", "STATUS"}, [][]string{{room, "deleted"}})
+	}
+}
+
+// runStorage handles "storage ls|put|get|rm" scoped to a user's prefix
+func runStorage(args []string) error {
+	verb, args, err := subcommand("storage", args, "ls", "put", "get", "rm")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("storage "+verb, flag.ExitOnError)
+	common := addCommonFlags(fs)
+	user := fs.String("user", "", "user ID whose users/{id}/ prefix to operate on (required)")
+	prefix := fs.String("prefix", "", "folder within the user's storage (ls, put)")
+	contentType := fs.String("content-type", "", "content type for put (guessed from the extension by default)")
+	out := fs.String("o", "", "file to write for get (defaults to the object name, - for stdout)")
+	recursive := fs.Bool("recursive", false, "delete a folder and everything under it (rm)")
+	fs.Parse(args)
+
+	if *user == "" {
+		return fmt.Errorf("--user is required")
+	}
+
+	cfg, err := common.load()
+	if err != nil {
+		return err
+	}
+	if !cfg.R2Enabled() {
+		return fmt.Errorf("R2 storage is not configured")
+	}
+	r2, err := storage.NewR2Client(cfg)
+	if err != nil {
+		return err
+	}
+
+	userPrefix := storage.UserPrefix(*user)
+	scoped := func(key string) string {
+		return userPrefix + strings.TrimPrefix(key, "/")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.timeout)
+	defer cancel()
+
+	switch verb {
+	case "ls":
+		result, err := r2.List(ctx, scoped(*prefix))
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(result.Folders)+len(result.Files))
+		for i := range result.Folders {
+			result.Folders[i].Key = strings.TrimPrefix(result.Folders[i].Key, userPrefix)
+			rows = append(rows, []string{result.Folders[i].Key, "-", "-"})
+		}
+		for i := range result.Files {
+			f := &result.Files[i]
+			f.Key = strings.TrimPrefix(f.Key, userPrefix)
+			size, updated := "-", "-"
+			if f.Size != nil {
+				size = strconv.FormatInt(*f.Size, 10)
+			}
+			if f.UpdatedAt != nil {
+				updated = *f.UpdatedAt
+			}
+			rows = append(rows, []string{f.Key, size, updated})
+		}
+		result.Prefix = strings.TrimPrefix(result.Prefix, userPrefix)
+		return common.print(result, []string{"KEY", "SIZE", "UPDATED"}, rows)
+
+	case "put":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: server storage put [flags] <file>")
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		key := scoped(strings.TrimSuffix(*prefix, "/"))
+		if *prefix != "" {
+			key += "/"
+		}
+		key += filepath.Base(fs.Arg(0))
+
+		entry, err := r2.Upload(ctx, key, f, *contentType)
+		if err != nil {
+			return err
+		}
+		entry.Key = strings.TrimPrefix(entry.Key, userPrefix)
+		return common.print(entry, []string{"KEY", "TYPE"}, [][]string{{entry.Key, *entry.ContentType}})
+
+	case "get":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: server storage get [flags] <key>")
+		}
+		body, entry, err := r2.Get(ctx, scoped(fs.Arg(0)))
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		var w io.Writer = os.Stdout
+		if *out != "-" {
+			path := *out
+			if path == "" {
+				path = entry.Name
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		bw := bufio.NewWriter(w)
+		if _, err := io.Copy(bw, body); err != nil {
+			return err
+		}
+		return bw.Flush()
+
+	default:
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: server storage rm [flags] <key>")
+		}
+		key := fs.Arg(0)
+		if err := r2.Delete(ctx, scoped(key), *recursive); err != nil {
+			return err
+		}
+		return common.print(map[string]string{"key": key, "status": "deleted"}, []string{"KEY", "STATUS"}, [][]string{{key, "deleted"}})
+	}
+}
+
+// runAPIKey handles "apikey hash [key]". The key is read from stdin when
+// not given, to keep it out of shell history.
+func runAPIKey(args []string) error {
+	_, args, err := subcommand("apikey", args, "hash")
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("apikey hash", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	key := fs.Arg(0)
+	if key == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		key = strings.TrimSpace(line)
+	}
+	if key == "" {
+		return fmt.Errorf("usage: server apikey hash [key] (or pipe the key on stdin)")
+	}
+
+	hash := handler.HashAPIKey(key)
+	return common.print(map[string]string{"hash": hash}, []string{"HASH"}, [][]string{{hash}})
+}
@@ -0,0 +1,128 @@
+package background
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tracker keeps track of in-flight work (requests and fire-and-forget
+// goroutines) so shutdown can wait for it and report what was abandoned.
+type Tracker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	running map[uint64]task
+	wg      sync.WaitGroup
+
+	// ctx is handed to background tasks and cancelled once the drain
+	// deadline passes
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type task struct {
+	name    string
+	started time.Time
+}
+
+// Abandoned describes work still running when the drain deadline passed
+type Abandoned struct {
+	Name    string
+	Running time.Duration
+}
+
+func (a Abandoned) String() string {
+	return fmt.Sprintf("%s (running %s)", a.Name, a.Running.Round(time.Millisecond))
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tracker{
+		running: make(map[uint64]task),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Track registers a unit of work and returns the function that marks it
+// finished. It is used for work that runs on the caller's goroutine, such
+// as HTTP requests.
+func (t *Tracker) Track(name string) (done func()) {
+	t.mu.Lock()
+	id := t.nextID
+	t.nextID++
+	t.running[id] = task{name: name, started: time.Now()}
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			delete(t.running, id)
+			t.mu.Unlock()
+			t.wg.Done()
+		})
+	}
+}
+
+// Go runs fn in a new goroutine and tracks it until it returns. The
+// context passed to fn is cancelled when a drain gives up waiting, so
+// long-running work should honour it. Panics are recovered and logged.
+func (t *Tracker) Go(name string, fn func(ctx context.Context)) {
+	done := t.Track(name)
+	go func() {
+		defer done()
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("background task panicked", "task", name, "panic", r)
+			}
+		}()
+		fn(t.ctx)
+	}()
+}
+
+// Context returns the context handed to background tasks, cancelled once a
+// drain gives up waiting. It is for long-lived workers that start tracked
+// work of their own rather than running under Go.
+func (t *Tracker) Context() context.Context {
+	return t.ctx
+}
+
+// Running returns the work currently in flight, oldest first
+func (t *Tracker) Running() []Abandoned {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	items := make([]Abandoned, 0, len(t.running))
+	for _, tk := range t.running {
+		items = append(items, Abandoned{Name: tk.name, Running: now.Sub(tk.started)})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Running > items[j].Running })
+	return items
+}
+
+// Drain waits for all tracked work to finish or ctx to expire. On expiry
+// it cancels the context given to background tasks and returns whatever
+// was still running; nil means everything finished.
+func (t *Tracker) Drain(ctx context.Context) []Abandoned {
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		abandoned := t.Running()
+		t.cancel()
+		return abandoned
+	}
+}
@@ -1,16 +1,26 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	LivekitHost   string
 	LivekitAPIKey string
 	LivekitSecret string
-	Port          string
-	CORSOrigins   []string
+	// LiveKit token policy: the longest TTL a client may request, and the
+	// roles any user may request (others need room owner or moderator)
+	LivekitTokenMaxTTL time.Duration
+	LivekitPublicRoles []string
+	// Where room ACLs are shared between replicas (in memory unless a Redis
+	// URL is set)
+	RoomACLRedisURL string
+	Port            string
+	CORSOrigins     []string
 	// R2 Configuration
 	R2AccessKeyID     string
 	R2SecretAccessKey string
@@ -22,37 +32,316 @@ type Config struct {
 	UnsplashUTMSource string
 	// Convex Configuration
 	ConvexURL string
+	// Local verification of Convex Auth JWTs (disabled when the issuer is empty)
+	ConvexAuthIssuer      string
+	ConvexAuthAudience    string
+	ConvexAuthJWKSURL     string
+	ConvexAuthJWKSRefresh time.Duration
+	ConvexAuthMaxTokenTTL time.Duration
+	// Session validation cache (in memory unless a Redis URL is set)
+	SessionCacheSize        int
+	SessionCacheTTL         time.Duration
+	SessionCacheNegativeTTL time.Duration
+	SessionCacheRedisURL    string
+	// How long revoked users and sessions stay on the deny list, and the
+	// service keys allowed to revoke them (revocation is disabled when
+	// none are listed)
+	SessionRevocationTTL time.Duration
+	SessionRevokers      []string
+	// Meeting invites (disabled when the signing key is empty)
+	InviteSigningKey string
+	InviteMaxTTL     time.Duration
+	InviteRedisURL   string
+	InviteLinkBase   string
+	// Lobby tickets: how long guests wait for a host, and where tickets are
+	// shared between replicas (in memory unless a Redis URL is set)
+	LobbyTicketTTL time.Duration
+	LobbyRedisURL  string
+	// Webhook event history: the JSON lines file events are kept in (in
+	// memory when empty) or the Redis shared by every replica, how long
+	// they are kept and how many are held in memory
+	EventStorePath      string
+	EventStoreRedisURL  string
+	EventRetention      time.Duration
+	EventStoreMaxEvents int
+	// Live event stream: events kept for reconnecting clients, and how
+	// often idle streams get a heartbeat
+	EventStreamBuffer    int
+	EventStreamHeartbeat time.Duration
+	// Webhook handlers: queue capacity, concurrency and retry policy
+	WebhookQueueSize    int
+	WebhookWorkers      int
+	WebhookMaxAttempts  int
+	WebhookRetryBackoff time.Duration
+	// Outbound webhook forwarding (disabled when no URLs are set)
+	WebhookForwardURLs   []string
+	WebhookForwardSecret string
+	// API hub rate limiting, used when a key has no limit of its own
+	RateLimitDefault int
+	RateLimitWindow  time.Duration
+	// Graceful shutdown drain deadline
+	ShutdownTimeout time.Duration
+	// Logging
+	LogLevel  string
+	LogFormat string
+	// Admin endpoints (disabled when empty)
+	AdminToken string
+	// Signed service-to-service identity (disabled when no keys are set).
+	// Used nonces are kept in memory unless a Redis URL is set.
+	ServiceAuthKeys         string
+	ServiceAuthAudience     string
+	ServiceAuthTrustedCIDRs []string
+	ServiceAuthMaxTTL       time.Duration
+	ServiceAuthRedisURL     string
+	// Accept a raw X-User-ID header as the user; local development only
+	DevTrustUserIDHeader bool
+	// Tracing (disabled when the exporter is empty)
+	TracingExporter    string
+	TracingSampleRatio float64
 }
 
-func Load() *Config {
-	corsOrigins := []string{"http://localhost:1234", "http://127.0.0.1:1234"}
-	if customOrigins := getEnv("CORS_ORIGINS", ""); customOrigins != "" {
-		corsOrigins = strings.Split(customOrigins, ",")
-		for i := range corsOrigins {
-			corsOrigins[i] = strings.TrimSpace(corsOrigins[i])
+// Options controls where configuration is read from. The zero value reads
+// the process environment and the file named by APP_CONFIG, if any.
+type Options struct {
+	// File is a YAML or TOML config file. When empty, APP_CONFIG is used.
+	File string
+	// LookupEnv replaces os.LookupEnv, so precedence can be exercised
+	// without touching the process environment
+	LookupEnv func(key string) (string, bool)
+}
+
+// defaults are applied last, after the environment and the config file
+var defaults = map[string]string{
+	"PORT":                       ":1323",
+	"CORS_ORIGINS":               "http://localhost:1234,http://127.0.0.1:1234",
+	"LIVEKIT_TOKEN_MAX_TTL":      "6h",
+	"LIVEKIT_PUBLIC_ROLES":       "speaker,viewer",
+	"SESSION_CACHE_SIZE":         "10000",
+	"SESSION_CACHE_TTL":          "5m",
+	"SESSION_CACHE_NEGATIVE_TTL": "30s",
+	"SESSION_REVOCATION_TTL":     "1h",
+	"INVITE_MAX_TTL":             "168h",
+	"LOBBY_TICKET_TTL":           "10m",
+	"EVENT_RETENTION":            "2160h",
+	"EVENT_STORE_MAX_EVENTS":     "100000",
+	"EVENT_STREAM_BUFFER":        "1000",
+	"EVENT_STREAM_HEARTBEAT":     "15s",
+	"WEBHOOK_QUEUE_SIZE":         "1000",
+	"WEBHOOK_WORKERS":            "4",
+	"WEBHOOK_MAX_ATTEMPTS":       "5",
+	"WEBHOOK_RETRY_BACKOFF":      "1s",
+	"RATE_LIMIT_DEFAULT":         "100",
+	"RATE_LIMIT_WINDOW":          "1m",
+	"LOG_LEVEL":                  "info",
+	"LOG_FORMAT":                 "text",
+	"SHUTDOWN_TIMEOUT":           "30s",
+	"TRACING_SAMPLE_RATIO":       "1",
+	"SERVICE_AUTH_AUDIENCE":      "backend",
+	"SERVICE_AUTH_MAX_TTL":       "5m",
+	"DEV_TRUST_USER_ID_HEADER":   "false",
+	"CONVEX_AUTH_AUDIENCE":       "convex",
+	"CONVEX_AUTH_JWKS_REFRESH":   "1h",
+	"CONVEX_AUTH_MAX_TOKEN_TTL":  "1h",
+}
+
+// Load reads the configuration from the environment and the optional
+// config file named by APP_CONFIG, then validates it.
+// The returned error is a *ValidationError listing every problem found.
+func Load() (*Config, error) {
+	return LoadWith(Options{})
+}
+
+// LoadWith reads the configuration using opts. Environment variables take
+// precedence over the config file, which takes precedence over defaults.
+func LoadWith(opts Options) (*Config, error) {
+	lookupEnv := opts.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	path := opts.File
+	if path == "" {
+		path, _ = lookupEnv("APP_CONFIG")
+	}
+
+	var file map[string]string
+	if path != "" {
+		var err error
+		file, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	errs := &ValidationError{}
+	getEnv := func(key string) string {
+		if isSecret(key) {
+			if value, ok := readSecretEnv(errs, lookupEnv, key); ok {
+				return value
+			}
+		} else if value, ok := lookupEnv(key); ok && value != "" {
+			return value
+		}
+		if value := file[key]; value != "" {
+			return value
 		}
+		return defaults[key]
+	}
+
+	cfg := &Config{
+		LivekitHost:             getEnv("LIVEKIT_URL"),
+		LivekitAPIKey:           getEnv("LIVEKIT_API_KEY"),
+		LivekitSecret:           getEnv("LIVEKIT_API_SECRET"),
+		LivekitTokenMaxTTL:      parseDuration(errs, "livekit", "LIVEKIT_TOKEN_MAX_TTL", getEnv("LIVEKIT_TOKEN_MAX_TTL")),
+		LivekitPublicRoles:      splitList(getEnv("LIVEKIT_PUBLIC_ROLES")),
+		RoomACLRedisURL:         getEnv("ROOM_ACL_REDIS_URL"),
+		Port:                    getEnv("PORT"),
+		CORSOrigins:             splitList(getEnv("CORS_ORIGINS")),
+		R2AccessKeyID:           getEnv("R2_ACCESS_KEY_ID"),
+		R2SecretAccessKey:       getEnv("R2_SECRET_ACCESS_KEY"),
+		R2Bucket:                getEnv("R2_BUCKET"),
+		R2Endpoint:              getEnv("R2_ENDPOINT"),
+		R2PublicBase:            getEnv("R2_PUBLIC_BASE"),
+		UnsplashAccessKey:       getEnv("UNSPLASH_ACCESS_KEY"),
+		UnsplashUTMSource:       getEnv("UNSPLASH_UTM_SOURCE"),
+		ConvexURL:               getEnv("CONVEX_URL"),
+		ConvexAuthIssuer:        strings.TrimSuffix(getEnv("CONVEX_AUTH_ISSUER"), "/"),
+		ConvexAuthAudience:      getEnv("CONVEX_AUTH_AUDIENCE"),
+		ConvexAuthJWKSURL:       getEnv("CONVEX_AUTH_JWKS_URL"),
+		ConvexAuthJWKSRefresh:   parseDuration(errs, "convexauth", "CONVEX_AUTH_JWKS_REFRESH", getEnv("CONVEX_AUTH_JWKS_REFRESH")),
+		ConvexAuthMaxTokenTTL:   parseDuration(errs, "convexauth", "CONVEX_AUTH_MAX_TOKEN_TTL", getEnv("CONVEX_AUTH_MAX_TOKEN_TTL")),
+		SessionCacheSize:        parseInt(errs, "sessioncache", "SESSION_CACHE_SIZE", getEnv("SESSION_CACHE_SIZE")),
+		SessionCacheTTL:         parseDuration(errs, "sessioncache", "SESSION_CACHE_TTL", getEnv("SESSION_CACHE_TTL")),
+		SessionCacheNegativeTTL: parseDuration(errs, "sessioncache", "SESSION_CACHE_NEGATIVE_TTL", getEnv("SESSION_CACHE_NEGATIVE_TTL")),
+		SessionCacheRedisURL:    getEnv("SESSION_CACHE_REDIS_URL"),
+		SessionRevocationTTL:    parseDuration(errs, "sessioncache", "SESSION_REVOCATION_TTL", getEnv("SESSION_REVOCATION_TTL")),
+		SessionRevokers:         splitList(getEnv("SESSION_REVOKERS")),
+		InviteSigningKey:        getEnv("INVITE_SIGNING_KEY"),
+		InviteMaxTTL:            parseDuration(errs, "invites", "INVITE_MAX_TTL", getEnv("INVITE_MAX_TTL")),
+		InviteRedisURL:          getEnv("INVITE_REDIS_URL"),
+		InviteLinkBase:          getEnv("INVITE_LINK_BASE"),
+		LobbyTicketTTL:          parseDuration(errs, "lobby", "LOBBY_TICKET_TTL", getEnv("LOBBY_TICKET_TTL")),
+		LobbyRedisURL:           getEnv("LOBBY_REDIS_URL"),
+		EventStorePath:          getEnv("EVENT_STORE_PATH"),
+		EventStoreRedisURL:      getEnv("EVENT_STORE_REDIS_URL"),
+		EventRetention:          parseDuration(errs, "events", "EVENT_RETENTION", getEnv("EVENT_RETENTION")),
+		EventStoreMaxEvents:     parseInt(errs, "events", "EVENT_STORE_MAX_EVENTS", getEnv("EVENT_STORE_MAX_EVENTS")),
+		EventStreamBuffer:       parseInt(errs, "events", "EVENT_STREAM_BUFFER", getEnv("EVENT_STREAM_BUFFER")),
+		EventStreamHeartbeat:    parseDuration(errs, "events", "EVENT_STREAM_HEARTBEAT", getEnv("EVENT_STREAM_HEARTBEAT")),
+		WebhookQueueSize:        parseInt(errs, "webhooks", "WEBHOOK_QUEUE_SIZE", getEnv("WEBHOOK_QUEUE_SIZE")),
+		WebhookWorkers:          parseInt(errs, "webhooks", "WEBHOOK_WORKERS", getEnv("WEBHOOK_WORKERS")),
+		WebhookMaxAttempts:      parseInt(errs, "webhooks", "WEBHOOK_MAX_ATTEMPTS", getEnv("WEBHOOK_MAX_ATTEMPTS")),
+		WebhookRetryBackoff:     parseDuration(errs, "webhooks", "WEBHOOK_RETRY_BACKOFF", getEnv("WEBHOOK_RETRY_BACKOFF")),
+		WebhookForwardURLs:      splitList(getEnv("WEBHOOK_FORWARD_URLS")),
+		WebhookForwardSecret:    getEnv("WEBHOOK_FORWARD_SECRET"),
+		RateLimitDefault:        parseInt(errs, "ratelimit", "RATE_LIMIT_DEFAULT", getEnv("RATE_LIMIT_DEFAULT")),
+		RateLimitWindow:         parseDuration(errs, "ratelimit", "RATE_LIMIT_WINDOW", getEnv("RATE_LIMIT_WINDOW")),
+		ShutdownTimeout:         parseDuration(errs, "server", "SHUTDOWN_TIMEOUT", getEnv("SHUTDOWN_TIMEOUT")),
+		LogLevel:                strings.ToLower(getEnv("LOG_LEVEL")),
+		LogFormat:               strings.ToLower(getEnv("LOG_FORMAT")),
+		AdminToken:              getEnv("ADMIN_TOKEN"),
+		ServiceAuthKeys:         getEnv("SERVICE_AUTH_KEYS"),
+		ServiceAuthAudience:     getEnv("SERVICE_AUTH_AUDIENCE"),
+		ServiceAuthTrustedCIDRs: splitList(getEnv("SERVICE_AUTH_TRUSTED_CIDRS")),
+		ServiceAuthMaxTTL:       parseDuration(errs, "serviceauth", "SERVICE_AUTH_MAX_TTL", getEnv("SERVICE_AUTH_MAX_TTL")),
+		ServiceAuthRedisURL:     getEnv("SERVICE_AUTH_REDIS_URL"),
+		DevTrustUserIDHeader:    parseBool(errs, "server", "DEV_TRUST_USER_ID_HEADER", getEnv("DEV_TRUST_USER_ID_HEADER")),
+		TracingExporter:         strings.ToLower(getEnv("TRACING_EXPORTER")),
+		TracingSampleRatio:      parseFloat(errs, "tracing", "TRACING_SAMPLE_RATIO", getEnv("TRACING_SAMPLE_RATIO")),
+	}
+
+	if cfg.ConvexAuthJWKSURL == "" && cfg.ConvexAuthIssuer != "" {
+		cfg.ConvexAuthJWKSURL = cfg.ConvexAuthIssuer + "/.well-known/jwks.json"
 	}
 
-	return &Config{
-		LivekitHost:       getEnv("LIVEKIT_URL", "https://your-project.livekit.cloud"),
-		LivekitAPIKey:     getEnv("LIVEKIT_API_KEY", ""),
-		LivekitSecret:     getEnv("LIVEKIT_API_SECRET", ""),
-		Port:              getEnv("PORT", ":1323"),
-		CORSOrigins:       corsOrigins,
-		R2AccessKeyID:     getEnv("R2_ACCESS_KEY_ID", ""),
-		R2SecretAccessKey: getEnv("R2_SECRET_ACCESS_KEY", ""),
-		R2Bucket:          getEnv("R2_BUCKET", ""),
-		R2Endpoint:        getEnv("R2_ENDPOINT", ""),
-		R2PublicBase:      getEnv("R2_PUBLIC_BASE", ""),
-		UnsplashAccessKey: getEnv("UNSPLASH_ACCESS_KEY", ""),
-		UnsplashUTMSource: getEnv("UNSPLASH_UTM_SOURCE", ""),
-		ConvexURL:         getEnv("CONVEX_URL", ""),
+	cfg.validate(errs)
+	if len(errs.Problems) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+// R2Enabled reports whether R2 storage is configured
+func (c *Config) R2Enabled() bool {
+	return SubsystemR2.Enabled(c)
+}
+
+// AdminEnabled reports whether the admin endpoints are configured
+func (c *Config) AdminEnabled() bool {
+	return SubsystemAdmin.Enabled(c)
+}
+
+// ConvexAuthEnabled reports whether Convex Auth JWTs are verified locally
+func (c *Config) ConvexAuthEnabled() bool {
+	return SubsystemConvexAuth.Enabled(c)
+}
+
+// InvitesEnabled reports whether meeting invites are configured
+func (c *Config) InvitesEnabled() bool {
+	return SubsystemInvites.Enabled(c)
+}
+
+// WebhookForwardEnabled reports whether webhook events are forwarded to
+// other endpoints
+func (c *Config) WebhookForwardEnabled() bool {
+	return SubsystemWebhookForward.Enabled(c)
+}
+
+// ServiceAuthEnabled reports whether signed service identities are accepted
+func (c *Config) ServiceAuthEnabled() bool {
+	return SubsystemServiceAuth.Enabled(c)
+}
+
+// TracingEnabled reports whether spans are exported
+func (c *Config) TracingEnabled() bool {
+	return SubsystemTracing.Enabled(c)
+}
+
+// UnsplashEnabled reports whether the Unsplash proxy is configured
+func (c *Config) UnsplashEnabled() bool {
+	return SubsystemUnsplash.Enabled(c)
+}
+
+// splitList splits a comma-separated value, trimming blanks
+func splitList(value string) []string {
+	parts := strings.Split(value, ",")
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
+
+func parseInt(errs *ValidationError, subsystem, key, value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		errs.add(subsystem, key, fmt.Sprintf("%q is not an integer", value))
+	}
+	return n
+}
+
+func parseBool(errs *ValidationError, subsystem, key, value string) bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		errs.add(subsystem, key, fmt.Sprintf("%q is not a boolean", value))
+	}
+	return b
+}
+
+func parseFloat(errs *ValidationError, subsystem, key, value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		errs.add(subsystem, key, fmt.Sprintf("%q is not a number", value))
 	}
+	return f
 }
 
-func getEnv(key, fallback string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func parseDuration(errs *ValidationError, subsystem, key, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		errs.add(subsystem, key, fmt.Sprintf("%q is not a duration (e.g. 30s, 1m)", value))
 	}
-	return fallback
+	return d
 }
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlBase = `
+livekit:
+  url: wss://file.livekit.example
+  api_key: file-key
+  api_secret: file-secret
+server:
+  port: ":9000"
+events:
+  retention: 48h
+  stream_buffer: 50
+`
+
+const tomlBase = `
+[livekit]
+url = "wss://file.livekit.example"
+api_key = "file-key"
+api_secret = "file-secret"
+
+[server]
+port = ":9000"
+
+[events]
+retention = "48h"
+stream_buffer = 50
+`
+
+// requiredEnv is the least environment that passes validation without a
+// config file
+var requiredEnv = map[string]string{
+	"LIVEKIT_URL":        "wss://env.livekit.example",
+	"LIVEKIT_API_KEY":    "env-key",
+	"LIVEKIT_API_SECRET": "env-secret",
+}
+
+func TestLoadWithPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		// file is written to a temporary file with this extension unless
+		// empty
+		ext  string
+		file string
+		env  map[string]string
+		// want checks the loaded config; wantErr is a substring of the
+		// error instead
+		want    func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "defaults without file",
+			env:  requiredEnv,
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "Port", cfg.Port, ":1323")
+				equal(t, "EventRetention", cfg.EventRetention, 2160*time.Hour)
+				equal(t, "EventStreamBuffer", cfg.EventStreamBuffer, 1000)
+			},
+		},
+		{
+			name: "yaml file",
+			ext:  ".yaml",
+			file: yamlBase,
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "LivekitHost", cfg.LivekitHost, "wss://file.livekit.example")
+				equal(t, "LivekitSecret", cfg.LivekitSecret, "file-secret")
+				equal(t, "Port", cfg.Port, ":9000")
+				equal(t, "EventRetention", cfg.EventRetention, 48*time.Hour)
+				equal(t, "EventStreamBuffer", cfg.EventStreamBuffer, 50)
+			},
+		},
+		{
+			name: "toml file",
+			ext:  ".toml",
+			file: tomlBase,
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "LivekitHost", cfg.LivekitHost, "wss://file.livekit.example")
+				equal(t, "LivekitSecret", cfg.LivekitSecret, "file-secret")
+				equal(t, "Port", cfg.Port, ":9000")
+				equal(t, "EventRetention", cfg.EventRetention, 48*time.Hour)
+				equal(t, "EventStreamBuffer", cfg.EventStreamBuffer, 50)
+			},
+		},
+		{
+			name: "file fills in what the environment leaves unset",
+			ext:  ".yml",
+			file: yamlBase,
+			env:  map[string]string{"LIVEKIT_API_KEY": "env-key", "PORT": ""},
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "LivekitAPIKey", cfg.LivekitAPIKey, "env-key")
+				equal(t, "LivekitHost", cfg.LivekitHost, "wss://file.livekit.example")
+				equal(t, "Port", cfg.Port, ":9000")
+				equal(t, "EventStreamHeartbeat", cfg.EventStreamHeartbeat, 15*time.Second)
+			},
+		},
+		{
+			name: "environment overrides yaml",
+			ext:  ".yaml",
+			file: yamlBase,
+			env:  map[string]string{"PORT": ":9100", "EVENT_RETENTION": "72h", "EVENT_STREAM_BUFFER": "70"},
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "Port", cfg.Port, ":9100")
+				equal(t, "EventRetention", cfg.EventRetention, 72*time.Hour)
+				equal(t, "EventStreamBuffer", cfg.EventStreamBuffer, 70)
+			},
+		},
+		{
+			name: "environment overrides toml",
+			ext:  ".toml",
+			file: tomlBase,
+			env:  map[string]string{"PORT": ":9100", "LIVEKIT_API_SECRET": "env-secret"},
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "Port", cfg.Port, ":9100")
+				equal(t, "LivekitSecret", cfg.LivekitSecret, "env-secret")
+			},
+		},
+		{
+			name: "file list replaced by environment list",
+			ext:  ".yaml",
+			file: yamlBase + "cors:\n  origins: [https://a.example, https://b.example]\n",
+			env:  map[string]string{"CORS_ORIGINS": "https://c.example"},
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "CORSOrigins", strings.Join(cfg.CORSOrigins, ","), "https://c.example")
+			},
+		},
+		{
+			name:    "unknown yaml key",
+			ext:     ".yaml",
+			file:    yamlBase + "  stream_bufer: 10\n",
+			wantErr: "stream_bufer",
+		},
+		{
+			name:    "unknown toml key",
+			ext:     ".toml",
+			file:    tomlBase + "stream_bufer = 10\n",
+			wantErr: `unknown key "events.stream_bufer"`,
+		},
+		{
+			name:    "malformed toml",
+			ext:     ".toml",
+			file:    "[livekit\n",
+			wantErr: "failed to parse TOML config",
+		},
+		{
+			name:    "unsupported extension",
+			ext:     ".json",
+			file:    "{}",
+			wantErr: "unsupported config file extension",
+		},
+		{
+			name:    "invalid file value reported by key",
+			ext:     ".yaml",
+			file:    strings.Replace(yamlBase, "retention: 48h", "retention: soon", 1),
+			wantErr: "EVENT_RETENTION",
+		},
+		{
+			name: "valid environment value masks invalid file value",
+			ext:  ".yaml",
+			file: strings.Replace(yamlBase, "retention: 48h", "retention: soon", 1),
+			env:  map[string]string{"EVENT_RETENTION": "24h"},
+			want: func(t *testing.T, cfg *Config) {
+				equal(t, "EventRetention", cfg.EventRetention, 24*time.Hour)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{LookupEnv: lookup(tt.env)}
+			if tt.ext != "" {
+				opts.File = writeFile(t, "config"+tt.ext, tt.file)
+			}
+
+			cfg, err := LoadWith(opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadWith() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadWith() error = %v", err)
+			}
+			tt.want(t, cfg)
+		})
+	}
+}
+
+func TestLoadWithAppConfig(t *testing.T) {
+	path := writeFile(t, "app.toml", tomlBase)
+	cfg, err := LoadWith(Options{LookupEnv: lookup(map[string]string{"APP_CONFIG": path})})
+	if err != nil {
+		t.Fatalf("LoadWith() error = %v", err)
+	}
+	equal(t, "Port", cfg.Port, ":9000")
+
+	// An explicit file wins over APP_CONFIG
+	other := writeFile(t, "other.yaml", strings.Replace(yamlBase, `":9000"`, `":9200"`, 1))
+	cfg, err = LoadWith(Options{File: other, LookupEnv: lookup(map[string]string{"APP_CONFIG": path})})
+	if err != nil {
+		t.Fatalf("LoadWith() error = %v", err)
+	}
+	equal(t, "Port", cfg.Port, ":9200")
+}
+
+func TestLoadWithSecretFile(t *testing.T) {
+	secret := writeFile(t, "secret", "from-secret-file\n")
+	file := writeFile(t, "config.yaml", yamlBase)
+
+	cfg, err := LoadWith(Options{File: file, LookupEnv: lookup(map[string]string{"LIVEKIT_API_SECRET_FILE": secret})})
+	if err != nil {
+		t.Fatalf("LoadWith() error = %v", err)
+	}
+	equal(t, "LivekitSecret", cfg.LivekitSecret, "from-secret-file")
+
+	_, err = LoadWith(Options{File: file, LookupEnv: lookup(map[string]string{
+		"LIVEKIT_API_SECRET":      "env-secret",
+		"LIVEKIT_API_SECRET_FILE": secret,
+	})})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || !verr.has("LIVEKIT_API_SECRET") {
+		t.Fatalf("LoadWith() error = %v, want a LIVEKIT_API_SECRET problem", err)
+	}
+}
+
+func TestValidateSessionRevocation(t *testing.T) {
+	const key = "revoker=hs256:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	tests := []struct {
+		name string
+		env  map[string]string
+		// wantErr is a substring of the SESSION_* problem, empty for none
+		wantErr string
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:    "shorter than the cache TTL",
+			env:     map[string]string{"SESSION_CACHE_TTL": "10m", "SESSION_REVOCATION_TTL": "5m"},
+			wantErr: "SESSION_REVOCATION_TTL: must be at least SESSION_CACHE_TTL",
+		},
+		{
+			name:    "shorter than the Convex Auth token lifetime",
+			env:     map[string]string{"CONVEX_AUTH_ISSUER": "https://app.convex.site", "CONVEX_AUTH_MAX_TOKEN_TTL": "2h"},
+			wantErr: "SESSION_REVOCATION_TTL: must be at least CONVEX_AUTH_MAX_TOKEN_TTL",
+		},
+		{
+			name:    "shorter than the service assertion lifetime",
+			env:     map[string]string{"SERVICE_AUTH_KEYS": key, "SERVICE_AUTH_MAX_TTL": "90m"},
+			wantErr: "SESSION_REVOCATION_TTL: must be at least SERVICE_AUTH_MAX_TTL",
+		},
+		{
+			name: "revokers with Redis",
+			env:  map[string]string{"SERVICE_AUTH_KEYS": key, "SESSION_REVOKERS": "revoker", "SESSION_CACHE_REDIS_URL": "redis://localhost:6379"},
+		},
+		{
+			name:    "revokers without Redis",
+			env:     map[string]string{"SERVICE_AUTH_KEYS": key, "SESSION_REVOKERS": "revoker"},
+			wantErr: "SESSION_REVOKERS: requires SESSION_CACHE_REDIS_URL",
+		},
+		{
+			name:    "revoker without a key",
+			env:     map[string]string{"SERVICE_AUTH_KEYS": key, "SESSION_REVOKERS": "other", "SESSION_CACHE_REDIS_URL": "redis://localhost:6379"},
+			wantErr: `SESSION_REVOKERS: "other" is not a key in SERVICE_AUTH_KEYS`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			for k, v := range requiredEnv {
+				env[k] = v
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+
+			_, err := LoadWith(Options{LookupEnv: lookup(env)})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("LoadWith() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadWith() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// lookup serves env in place of the process environment
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func equal[T comparable](t *testing.T, field string, got, want T) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %v, want %v", field, got, want)
+	}
+}
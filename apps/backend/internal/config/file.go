@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of a YAML or TOML config file. Every field maps
+// onto the environment variable of the same setting.
+type fileConfig struct {
+	Server struct {
+		Port            string `yaml:"port" toml:"port"`
+		LogLevel        string `yaml:"log_level" toml:"log_level"`
+		LogFormat       string `yaml:"log_format" toml:"log_format"`
+		ShutdownTimeout string `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+		// DevTrustUserIDHeader is a pointer so an unset value falls through
+		DevTrustUserIDHeader *bool `yaml:"dev_trust_user_id_header" toml:"dev_trust_user_id_header"`
+	} `yaml:"server" toml:"server"`
+	Admin struct {
+		Token string `yaml:"token" toml:"token"`
+	} `yaml:"admin" toml:"admin"`
+	LiveKit struct {
+		URL       string `yaml:"url" toml:"url"`
+		APIKey    string `yaml:"api_key" toml:"api_key"`
+		APISecret string `yaml:"api_secret" toml:"api_secret"`
+		Token     struct {
+			MaxTTL      string   `yaml:"max_ttl" toml:"max_ttl"`
+			PublicRoles []string `yaml:"public_roles" toml:"public_roles"`
+		} `yaml:"token" toml:"token"`
+		ACLRedisURL string `yaml:"acl_redis_url" toml:"acl_redis_url"`
+	} `yaml:"livekit" toml:"livekit"`
+	R2 struct {
+		AccessKeyID     string `yaml:"access_key_id" toml:"access_key_id"`
+		SecretAccessKey string `yaml:"secret_access_key" toml:"secret_access_key"`
+		Bucket          string `yaml:"bucket" toml:"bucket"`
+		Endpoint        string `yaml:"endpoint" toml:"endpoint"`
+		PublicBase      string `yaml:"public_base" toml:"public_base"`
+	} `yaml:"r2" toml:"r2"`
+	Unsplash struct {
+		AccessKey string `yaml:"access_key" toml:"access_key"`
+		UTMSource string `yaml:"utm_source" toml:"utm_source"`
+	} `yaml:"unsplash" toml:"unsplash"`
+	Convex struct {
+		URL  string `yaml:"url" toml:"url"`
+		Auth struct {
+			Issuer      string `yaml:"issuer" toml:"issuer"`
+			Audience    string `yaml:"audience" toml:"audience"`
+			JWKSURL     string `yaml:"jwks_url" toml:"jwks_url"`
+			JWKSRefresh string `yaml:"jwks_refresh" toml:"jwks_refresh"`
+			MaxTokenTTL string `yaml:"max_token_ttl" toml:"max_token_ttl"`
+		} `yaml:"auth" toml:"auth"`
+	} `yaml:"convex" toml:"convex"`
+	CORS struct {
+		Origins []string `yaml:"origins" toml:"origins"`
+	} `yaml:"cors" toml:"cors"`
+	ServiceAuth struct {
+		Keys         string   `yaml:"keys" toml:"keys"`
+		Audience     string   `yaml:"audience" toml:"audience"`
+		TrustedCIDRs []string `yaml:"trusted_cidrs" toml:"trusted_cidrs"`
+		MaxTTL       string   `yaml:"max_ttl" toml:"max_ttl"`
+		RedisURL     string   `yaml:"redis_url" toml:"redis_url"`
+	} `yaml:"service_auth" toml:"service_auth"`
+	Tracing struct {
+		Exporter    string   `yaml:"exporter" toml:"exporter"`
+		SampleRatio *float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+	} `yaml:"tracing" toml:"tracing"`
+	SessionCache struct {
+		Size          int      `yaml:"size" toml:"size"`
+		TTL           string   `yaml:"ttl" toml:"ttl"`
+		NegativeTTL   string   `yaml:"negative_ttl" toml:"negative_ttl"`
+		RedisURL      string   `yaml:"redis_url" toml:"redis_url"`
+		RevocationTTL string   `yaml:"revocation_ttl" toml:"revocation_ttl"`
+		Revokers      []string `yaml:"revokers" toml:"revokers"`
+	} `yaml:"session_cache" toml:"session_cache"`
+	Invites struct {
+		SigningKey string `yaml:"signing_key" toml:"signing_key"`
+		MaxTTL     string `yaml:"max_ttl" toml:"max_ttl"`
+		RedisURL   string `yaml:"redis_url" toml:"redis_url"`
+		LinkBase   string `yaml:"link_base" toml:"link_base"`
+	} `yaml:"invites" toml:"invites"`
+	Lobby struct {
+		TicketTTL string `yaml:"ticket_ttl" toml:"ticket_ttl"`
+		RedisURL  string `yaml:"redis_url" toml:"redis_url"`
+	} `yaml:"lobby" toml:"lobby"`
+	Events struct {
+		Path            string `yaml:"path" toml:"path"`
+		RedisURL        string `yaml:"redis_url" toml:"redis_url"`
+		Retention       string `yaml:"retention" toml:"retention"`
+		MaxEvents       int    `yaml:"max_events" toml:"max_events"`
+		StreamBuffer    int    `yaml:"stream_buffer" toml:"stream_buffer"`
+		StreamHeartbeat string `yaml:"stream_heartbeat" toml:"stream_heartbeat"`
+	} `yaml:"events" toml:"events"`
+	Webhooks struct {
+		QueueSize    int    `yaml:"queue_size" toml:"queue_size"`
+		Workers      int    `yaml:"workers" toml:"workers"`
+		MaxAttempts  int    `yaml:"max_attempts" toml:"max_attempts"`
+		RetryBackoff string `yaml:"retry_backoff" toml:"retry_backoff"`
+		Forward      struct {
+			URLs   []string `yaml:"urls" toml:"urls"`
+			Secret string   `yaml:"secret" toml:"secret"`
+		} `yaml:"forward" toml:"forward"`
+	} `yaml:"webhooks" toml:"webhooks"`
+	RateLimit struct {
+		Default int    `yaml:"default" toml:"default"`
+		Window  string `yaml:"window" toml:"window"`
+	} `yaml:"rate_limit" toml:"rate_limit"`
+}
+
+// values flattens the file into environment variable names so it can be
+// layered between the environment and the defaults
+func (f *fileConfig) values() map[string]string {
+	values := map[string]string{
+		"PORT":                       f.Server.Port,
+		"LOG_LEVEL":                  f.Server.LogLevel,
+		"LOG_FORMAT":                 f.Server.LogFormat,
+		"SHUTDOWN_TIMEOUT":           f.Server.ShutdownTimeout,
+		"ADMIN_TOKEN":                f.Admin.Token,
+		"LIVEKIT_URL":                f.LiveKit.URL,
+		"LIVEKIT_API_KEY":            f.LiveKit.APIKey,
+		"LIVEKIT_API_SECRET":         f.LiveKit.APISecret,
+		"LIVEKIT_TOKEN_MAX_TTL":      f.LiveKit.Token.MaxTTL,
+		"LIVEKIT_PUBLIC_ROLES":       strings.Join(f.LiveKit.Token.PublicRoles, ","),
+		"ROOM_ACL_REDIS_URL":         f.LiveKit.ACLRedisURL,
+		"R2_ACCESS_KEY_ID":           f.R2.AccessKeyID,
+		"R2_SECRET_ACCESS_KEY":       f.R2.SecretAccessKey,
+		"R2_BUCKET":                  f.R2.Bucket,
+		"R2_ENDPOINT":                f.R2.Endpoint,
+		"R2_PUBLIC_BASE":             f.R2.PublicBase,
+		"UNSPLASH_ACCESS_KEY":        f.Unsplash.AccessKey,
+		"UNSPLASH_UTM_SOURCE":        f.Unsplash.UTMSource,
+		"CONVEX_URL":                 f.Convex.URL,
+		"CONVEX_AUTH_ISSUER":         f.Convex.Auth.Issuer,
+		"CONVEX_AUTH_AUDIENCE":       f.Convex.Auth.Audience,
+		"CONVEX_AUTH_JWKS_URL":       f.Convex.Auth.JWKSURL,
+		"CONVEX_AUTH_JWKS_REFRESH":   f.Convex.Auth.JWKSRefresh,
+		"CONVEX_AUTH_MAX_TOKEN_TTL":  f.Convex.Auth.MaxTokenTTL,
+		"CORS_ORIGINS":               strings.Join(f.CORS.Origins, ","),
+		"SESSION_CACHE_TTL":          f.SessionCache.TTL,
+		"SESSION_CACHE_NEGATIVE_TTL": f.SessionCache.NegativeTTL,
+		"SESSION_CACHE_REDIS_URL":    f.SessionCache.RedisURL,
+		"SESSION_REVOCATION_TTL":     f.SessionCache.RevocationTTL,
+		"SESSION_REVOKERS":           strings.Join(f.SessionCache.Revokers, ","),
+		"INVITE_SIGNING_KEY":         f.Invites.SigningKey,
+		"INVITE_MAX_TTL":             f.Invites.MaxTTL,
+		"INVITE_REDIS_URL":           f.Invites.RedisURL,
+		"INVITE_LINK_BASE":           f.Invites.LinkBase,
+		"LOBBY_TICKET_TTL":           f.Lobby.TicketTTL,
+		"LOBBY_REDIS_URL":            f.Lobby.RedisURL,
+		"EVENT_STORE_PATH":           f.Events.Path,
+		"EVENT_STORE_REDIS_URL":      f.Events.RedisURL,
+		"EVENT_RETENTION":            f.Events.Retention,
+		"EVENT_STREAM_HEARTBEAT":     f.Events.StreamHeartbeat,
+		"WEBHOOK_RETRY_BACKOFF":      f.Webhooks.RetryBackoff,
+		"WEBHOOK_FORWARD_URLS":       strings.Join(f.Webhooks.Forward.URLs, ","),
+		"WEBHOOK_FORWARD_SECRET":     f.Webhooks.Forward.Secret,
+		"RATE_LIMIT_WINDOW":          f.RateLimit.Window,
+		"TRACING_EXPORTER":           f.Tracing.Exporter,
+		"SERVICE_AUTH_KEYS":          f.ServiceAuth.Keys,
+		"SERVICE_AUTH_AUDIENCE":      f.ServiceAuth.Audience,
+		"SERVICE_AUTH_TRUSTED_CIDRS": strings.Join(f.ServiceAuth.TrustedCIDRs, ","),
+		"SERVICE_AUTH_MAX_TTL":       f.ServiceAuth.MaxTTL,
+		"SERVICE_AUTH_REDIS_URL":     f.ServiceAuth.RedisURL,
+	}
+	if f.Server.DevTrustUserIDHeader != nil {
+		values["DEV_TRUST_USER_ID_HEADER"] = strconv.FormatBool(*f.Server.DevTrustUserIDHeader)
+	}
+	if f.Tracing.SampleRatio != nil {
+		values["TRACING_SAMPLE_RATIO"] = strconv.FormatFloat(*f.Tracing.SampleRatio, 'g', -1, 64)
+	}
+	if f.SessionCache.Size != 0 {
+		values["SESSION_CACHE_SIZE"] = strconv.Itoa(f.SessionCache.Size)
+	}
+	if f.Events.MaxEvents != 0 {
+		values["EVENT_STORE_MAX_EVENTS"] = strconv.Itoa(f.Events.MaxEvents)
+	}
+	if f.Events.StreamBuffer != 0 {
+		values["EVENT_STREAM_BUFFER"] = strconv.Itoa(f.Events.StreamBuffer)
+	}
+	if f.Webhooks.QueueSize != 0 {
+		values["WEBHOOK_QUEUE_SIZE"] = strconv.Itoa(f.Webhooks.QueueSize)
+	}
+	if f.Webhooks.Workers != 0 {
+		values["WEBHOOK_WORKERS"] = strconv.Itoa(f.Webhooks.Workers)
+	}
+	if f.Webhooks.MaxAttempts != 0 {
+		values["WEBHOOK_MAX_ATTEMPTS"] = strconv.Itoa(f.Webhooks.MaxAttempts)
+	}
+	if f.RateLimit.Default != 0 {
+		values["RATE_LIMIT_DEFAULT"] = strconv.Itoa(f.RateLimit.Default)
+	}
+	return values
+}
+
+// readFile parses a config file, choosing the format from its extension
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseFile(filepath.Ext(path), data)
+}
+
+// parseFile decodes data as YAML (.yaml, .yml) or TOML (.toml). Unknown
+// keys are rejected so typos don't silently fall through to defaults.
+func parseFile(ext string, data []byte) (map[string]string, error) {
+	var f fileConfig
+
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TOML config: %w", err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("failed to parse TOML config: unknown key %q", undecoded[0].String())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q (use .yaml, .yml or .toml)", ext)
+	}
+
+	return f.values(), nil
+}
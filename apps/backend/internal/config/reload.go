@@ -0,0 +1,135 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevels lists the accepted LOG_LEVEL values
+var LogLevels = map[string]struct{}{
+	"debug": {},
+	"info":  {},
+	"warn":  {},
+	"error": {},
+	"off":   {},
+}
+
+// Runtime is the subset of Config that can change while the server is
+// running. Readers should fetch it from a Reloader on every use rather
+// than caching it.
+type Runtime struct {
+	CORSOrigins       []string
+	UnsplashAccessKey string
+	UnsplashUTMSource string
+	RateLimitDefault  int
+	RateLimitWindow   time.Duration
+	LogLevel          string
+}
+
+// Runtime returns the reloadable subset of c
+func (c *Config) Runtime() *Runtime {
+	return &Runtime{
+		CORSOrigins:       append([]string(nil), c.CORSOrigins...),
+		UnsplashAccessKey: c.UnsplashAccessKey,
+		UnsplashUTMSource: c.UnsplashUTMSource,
+		RateLimitDefault:  c.RateLimitDefault,
+		RateLimitWindow:   c.RateLimitWindow,
+		LogLevel:          c.LogLevel,
+	}
+}
+
+// AllowsOrigin reports whether origin is in the CORS allow list
+func (r *Runtime) AllowsOrigin(origin string) bool {
+	for _, allowed := range r.CORSOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadResult describes the outcome of a successful reload
+type ReloadResult struct {
+	// Changed lists reloadable settings that now have new values
+	Changed []string `json:"changed"`
+	// RequiresRestart lists settings that changed but only take effect
+	// after a restart
+	RequiresRestart []string `json:"requiresRestart"`
+}
+
+// Reloader re-reads configuration on demand and atomically publishes the
+// reloadable settings. Environment variables are fixed for the lifetime of
+// the process, so in practice a reload picks up changes to the config file
+// and to *_FILE secrets.
+type Reloader struct {
+	opts    Options
+	mu      sync.Mutex // serializes reloads
+	config  *Config    // last loaded config, guarded by mu
+	current atomic.Pointer[Runtime]
+	hooks   []func(*Runtime)
+}
+
+// NewReloader publishes cfg's runtime settings. Later reloads read
+// configuration with the same opts cfg was loaded with.
+func NewReloader(cfg *Config, opts Options) *Reloader {
+	r := &Reloader{opts: opts, config: cfg}
+	r.current.Store(cfg.Runtime())
+	return r
+}
+
+// Current returns the active runtime settings
+func (r *Reloader) Current() *Runtime {
+	return r.current.Load()
+}
+
+// OnReload registers fn to run after every successful reload. Hooks must
+// be registered before the first reload.
+func (r *Reloader) OnReload(fn func(*Runtime)) {
+	r.hooks = append(r.hooks, fn)
+}
+
+// Reload loads and validates the configuration again. On success the new
+// runtime settings are swapped in; on failure the current ones are kept
+// and the error (usually a *ValidationError) is returned.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := LoadWith(r.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{Changed: []string{}, RequiresRestart: []string{}}
+	for _, sub := range Subsystems {
+		for _, setting := range sub.settings() {
+			if setting.Value(r.config) == setting.Value(next) {
+				continue
+			}
+			if setting.Reloadable {
+				result.Changed = append(result.Changed, setting.Env)
+			} else {
+				result.RequiresRestart = append(result.RequiresRestart, setting.Env)
+			}
+		}
+	}
+
+	// Keep non-reloadable settings as they were so later reloads keep
+	// reporting them as requiring a restart
+	merged := *r.config
+	runtime := next.Runtime()
+	merged.CORSOrigins = runtime.CORSOrigins
+	merged.UnsplashAccessKey = runtime.UnsplashAccessKey
+	merged.UnsplashUTMSource = runtime.UnsplashUTMSource
+	merged.RateLimitDefault = runtime.RateLimitDefault
+	merged.RateLimitWindow = runtime.RateLimitWindow
+	merged.LogLevel = runtime.LogLevel
+	r.config = &merged
+
+	r.current.Store(runtime)
+	for _, hook := range r.hooks {
+		hook(runtime)
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxSecretFileSize bounds how much of a *_FILE secret is read
+const maxSecretFileSize = 64 * 1024
+
+// isSecret reports whether key is marked as a secret by any subsystem.
+// Secrets may be supplied through <KEY>_FILE instead of <KEY>.
+func isSecret(key string) bool {
+	for _, sub := range Subsystems {
+		for _, setting := range sub.settings() {
+			if setting.Env == key {
+				return setting.Secret
+			}
+		}
+	}
+	return false
+}
+
+// readSecretEnv resolves a secret from either <KEY> or the file named by
+// <KEY>_FILE (as used for Docker and Kubernetes secrets). Setting both is
+// an error. Problems are recorded in errs.
+func readSecretEnv(errs *ValidationError, lookupEnv func(string) (string, bool), key string) (string, bool) {
+	value, hasValue := lookupEnv(key)
+	hasValue = hasValue && value != ""
+	path, hasFile := lookupEnv(key + "_FILE")
+	hasFile = hasFile && path != ""
+
+	switch {
+	case hasValue && hasFile:
+		errs.add("secrets", key, fmt.Sprintf("both %s and %s_FILE are set; use only one", key, key))
+		return "", false
+	case hasFile:
+		secret, err := readSecretFile(path)
+		if err != nil {
+			errs.add("secrets", key+"_FILE", err.Error())
+			return "", false
+		}
+		return secret, true
+	default:
+		return value, hasValue
+	}
+}
+
+// readSecretFile reads a secret from path, trimming surrounding whitespace.
+// The file must be a regular file that is not writable by group or others.
+func readSecretFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("cannot stat %s: %v", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	if perm := info.Mode().Perm(); perm&0o022 != 0 {
+		return "", fmt.Errorf("%s has insecure permissions %#o (must not be group or world writable)", path, perm)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSecretFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	if len(data) > maxSecretFileSize {
+		return "", fmt.Errorf("%s is larger than %d bytes", path, maxSecretFileSize)
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return secret, nil
+}
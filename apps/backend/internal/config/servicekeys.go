@@ -0,0 +1,72 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// minHMACKeySize is the shortest accepted HS256 service key
+const minHMACKeySize = 32
+
+// ServiceKey is a key that internal services sign identity assertions with
+type ServiceKey struct {
+	// ID is matched against the assertion's "kid" header and names the
+	// calling service
+	ID string
+	// Algorithm is "HS256" (shared secret) or "EdDSA" (Ed25519 public key)
+	Algorithm string
+	// Key is the HMAC secret or the Ed25519 public key
+	Key []byte
+}
+
+// ParseServiceKeys parses SERVICE_AUTH_KEYS, a comma-separated list of
+// <id>=<hs256|ed25519>:<base64 key> entries
+func ParseServiceKeys(value string) ([]ServiceKey, error) {
+	var keys []ServiceKey
+	seen := make(map[string]bool)
+	for _, entry := range splitList(value) {
+		id, spec, ok := strings.Cut(entry, "=")
+		kind, encoded, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 || id == "" {
+			return nil, fmt.Errorf("entry %q is not <id>=<hs256|ed25519>:<base64 key>", redactEntry(entry))
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("key %q is listed twice", id)
+		}
+		seen[id] = true
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			key, err = base64.RawURLEncoding.DecodeString(encoded)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64", id)
+		}
+
+		switch strings.ToLower(kind) {
+		case "hs256":
+			if len(key) < minHMACKeySize {
+				return nil, fmt.Errorf("key %q must be at least %d bytes", id, minHMACKeySize)
+			}
+			keys = append(keys, ServiceKey{ID: id, Algorithm: "HS256", Key: key})
+		case "ed25519":
+			if len(key) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("key %q must be a %d byte Ed25519 public key", id, ed25519.PublicKeySize)
+			}
+			keys = append(keys, ServiceKey{ID: id, Algorithm: "EdDSA", Key: key})
+		default:
+			return nil, fmt.Errorf("key %q has unknown type %q (want hs256 or ed25519)", id, kind)
+		}
+	}
+	return keys, nil
+}
+
+// redactEntry keeps the key material of a malformed entry out of errors
+func redactEntry(entry string) string {
+	if id, _, ok := strings.Cut(entry, "="); ok {
+		return id + "=..."
+	}
+	return "..."
+}
@@ -0,0 +1,626 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting describes a single configuration key. Reloadable settings can
+// be changed at runtime through a Reloader without restarting the server.
+type Setting struct {
+	Env        string
+	Secret     bool
+	Reloadable bool
+	value      func(*Config) string
+}
+
+// Value returns the setting's current value in cfg
+func (s Setting) Value(cfg *Config) string {
+	return s.value(cfg)
+}
+
+// Subsystem groups the settings a feature needs. A mandatory subsystem must
+// be fully configured; an optional one must be either fully configured or
+// left out entirely.
+type Subsystem struct {
+	Name      string
+	Mandatory bool
+	Required  []Setting
+	Optional  []Setting
+	check     func(*Config, *ValidationError)
+}
+
+// settings returns the required settings followed by the optional ones
+func (s Subsystem) settings() []Setting {
+	settings := make([]Setting, 0, len(s.Required)+len(s.Optional))
+	settings = append(settings, s.Required...)
+	return append(settings, s.Optional...)
+}
+
+// Enabled reports whether any of the subsystem's required settings are set
+func (s Subsystem) Enabled(cfg *Config) bool {
+	for _, setting := range s.Required {
+		if setting.Value(cfg) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	SubsystemLiveKit = Subsystem{
+		Name:      "livekit",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "LIVEKIT_URL", value: func(c *Config) string { return c.LivekitHost }},
+			{Env: "LIVEKIT_API_KEY", value: func(c *Config) string { return c.LivekitAPIKey }},
+			{Env: "LIVEKIT_API_SECRET", Secret: true, value: func(c *Config) string { return c.LivekitSecret }},
+		},
+		Optional: []Setting{
+			{Env: "LIVEKIT_TOKEN_MAX_TTL", value: func(c *Config) string { return c.LivekitTokenMaxTTL.String() }},
+			{Env: "LIVEKIT_PUBLIC_ROLES", value: func(c *Config) string { return strings.Join(c.LivekitPublicRoles, ",") }},
+			{Env: "ROOM_ACL_REDIS_URL", Secret: true, value: func(c *Config) string { return c.RoomACLRedisURL }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			checkURL(errs, "livekit", "LIVEKIT_URL", c.LivekitHost, "http", "https", "ws", "wss")
+			checkURL(errs, "livekit", "ROOM_ACL_REDIS_URL", c.RoomACLRedisURL, "redis", "rediss")
+			if c.LivekitTokenMaxTTL < time.Minute && !errs.has("LIVEKIT_TOKEN_MAX_TTL") {
+				errs.add("livekit", "LIVEKIT_TOKEN_MAX_TTL", "must be at least 1m")
+			}
+			for _, role := range c.LivekitPublicRoles {
+				if _, ok := TokenRoles[role]; !ok {
+					errs.add("livekit", "LIVEKIT_PUBLIC_ROLES", fmt.Sprintf("%q is not one of host, speaker, viewer, recorder, observer", role))
+				}
+			}
+		},
+	}
+
+	SubsystemR2 = Subsystem{
+		Name: "r2",
+		Required: []Setting{
+			{Env: "R2_ACCESS_KEY_ID", value: func(c *Config) string { return c.R2AccessKeyID }},
+			{Env: "R2_SECRET_ACCESS_KEY", Secret: true, value: func(c *Config) string { return c.R2SecretAccessKey }},
+			{Env: "R2_BUCKET", value: func(c *Config) string { return c.R2Bucket }},
+			{Env: "R2_ENDPOINT", value: func(c *Config) string { return c.R2Endpoint }},
+		},
+		Optional: []Setting{
+			{Env: "R2_PUBLIC_BASE", value: func(c *Config) string { return c.R2PublicBase }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			checkURL(errs, "r2", "R2_ENDPOINT", c.R2Endpoint, "http", "https")
+			checkURL(errs, "r2", "R2_PUBLIC_BASE", c.R2PublicBase, "http", "https")
+		},
+	}
+
+	SubsystemUnsplash = Subsystem{
+		Name: "unsplash",
+		Required: []Setting{
+			{Env: "UNSPLASH_ACCESS_KEY", Secret: true, Reloadable: true, value: func(c *Config) string { return c.UnsplashAccessKey }},
+		},
+		Optional: []Setting{
+			{Env: "UNSPLASH_UTM_SOURCE", Reloadable: true, value: func(c *Config) string { return c.UnsplashUTMSource }},
+		},
+	}
+
+	SubsystemConvex = Subsystem{
+		Name: "convex",
+		Required: []Setting{
+			{Env: "CONVEX_URL", value: func(c *Config) string { return c.ConvexURL }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			checkURL(errs, "convex", "CONVEX_URL", c.ConvexURL, "http", "https")
+		},
+	}
+
+	SubsystemConvexAuth = Subsystem{
+		Name: "convexauth",
+		Required: []Setting{
+			{Env: "CONVEX_AUTH_ISSUER", value: func(c *Config) string { return c.ConvexAuthIssuer }},
+		},
+		Optional: []Setting{
+			{Env: "CONVEX_AUTH_AUDIENCE", value: func(c *Config) string { return c.ConvexAuthAudience }},
+			{Env: "CONVEX_AUTH_JWKS_URL", value: func(c *Config) string { return c.ConvexAuthJWKSURL }},
+			{Env: "CONVEX_AUTH_JWKS_REFRESH", value: func(c *Config) string { return c.ConvexAuthJWKSRefresh.String() }},
+			{Env: "CONVEX_AUTH_MAX_TOKEN_TTL", value: func(c *Config) string { return c.ConvexAuthMaxTokenTTL.String() }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			checkURL(errs, "convexauth", "CONVEX_AUTH_ISSUER", c.ConvexAuthIssuer, "http", "https")
+			checkURL(errs, "convexauth", "CONVEX_AUTH_JWKS_URL", c.ConvexAuthJWKSURL, "http", "https")
+			if c.ConvexAuthAudience == "" {
+				errs.add("convexauth", "CONVEX_AUTH_AUDIENCE", "must not be empty")
+			}
+			if c.ConvexAuthJWKSRefresh <= 0 && !errs.has("CONVEX_AUTH_JWKS_REFRESH") {
+				errs.add("convexauth", "CONVEX_AUTH_JWKS_REFRESH", "must be positive")
+			}
+			if c.ConvexAuthMaxTokenTTL <= 0 && !errs.has("CONVEX_AUTH_MAX_TOKEN_TTL") {
+				errs.add("convexauth", "CONVEX_AUTH_MAX_TOKEN_TTL", "must be positive")
+			}
+		},
+	}
+
+	SubsystemServer = Subsystem{
+		Name:      "server",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "PORT", value: func(c *Config) string { return c.Port }},
+		},
+		Optional: []Setting{
+			{Env: "CORS_ORIGINS", Reloadable: true, value: func(c *Config) string { return strings.Join(c.CORSOrigins, ",") }},
+			{Env: "LOG_LEVEL", Reloadable: true, value: func(c *Config) string { return c.LogLevel }},
+			{Env: "LOG_FORMAT", value: func(c *Config) string { return c.LogFormat }},
+			{Env: "DEV_TRUST_USER_ID_HEADER", value: func(c *Config) string { return strconv.FormatBool(c.DevTrustUserIDHeader) }},
+			{Env: "SHUTDOWN_TIMEOUT", value: func(c *Config) string { return c.ShutdownTimeout.String() }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if port, err := normalizePort(c.Port); err != nil {
+				errs.add("server", "PORT", err.Error())
+			} else {
+				c.Port = port
+			}
+			for _, origin := range c.CORSOrigins {
+				if err := validateOrigin(origin); err != nil {
+					errs.add("server", "CORS_ORIGINS", fmt.Sprintf("%q: %v", origin, err))
+				}
+			}
+			if c.ShutdownTimeout <= 0 && !errs.has("SHUTDOWN_TIMEOUT") {
+				errs.add("server", "SHUTDOWN_TIMEOUT", "must be positive")
+			}
+			if _, ok := LogLevels[c.LogLevel]; !ok {
+				errs.add("server", "LOG_LEVEL", fmt.Sprintf("%q is not one of debug, info, warn, error, off", c.LogLevel))
+			}
+			if c.LogFormat != "text" && c.LogFormat != "json" {
+				errs.add("server", "LOG_FORMAT", fmt.Sprintf("%q is not one of text, json", c.LogFormat))
+			}
+		},
+	}
+
+	SubsystemAdmin = Subsystem{
+		Name: "admin",
+		Required: []Setting{
+			{Env: "ADMIN_TOKEN", Secret: true, value: func(c *Config) string { return c.AdminToken }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if len(c.AdminToken) < 16 {
+				errs.add("admin", "ADMIN_TOKEN", "must be at least 16 characters")
+			}
+		},
+	}
+
+	SubsystemServiceAuth = Subsystem{
+		Name: "serviceauth",
+		Required: []Setting{
+			{Env: "SERVICE_AUTH_KEYS", Secret: true, value: func(c *Config) string { return c.ServiceAuthKeys }},
+		},
+		Optional: []Setting{
+			{Env: "SERVICE_AUTH_AUDIENCE", value: func(c *Config) string { return c.ServiceAuthAudience }},
+			{Env: "SERVICE_AUTH_TRUSTED_CIDRS", value: func(c *Config) string { return strings.Join(c.ServiceAuthTrustedCIDRs, ",") }},
+			{Env: "SERVICE_AUTH_MAX_TTL", value: func(c *Config) string { return c.ServiceAuthMaxTTL.String() }},
+			{Env: "SERVICE_AUTH_REDIS_URL", Secret: true, value: func(c *Config) string { return c.ServiceAuthRedisURL }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if _, err := ParseServiceKeys(c.ServiceAuthKeys); err != nil {
+				errs.add("serviceauth", "SERVICE_AUTH_KEYS", err.Error())
+			}
+			if c.ServiceAuthAudience == "" {
+				errs.add("serviceauth", "SERVICE_AUTH_AUDIENCE", "must not be empty")
+			}
+			for _, cidr := range c.ServiceAuthTrustedCIDRs {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					errs.add("serviceauth", "SERVICE_AUTH_TRUSTED_CIDRS", fmt.Sprintf("%q is not a CIDR (e.g. 10.0.0.0/8)", cidr))
+				}
+			}
+			if c.ServiceAuthMaxTTL <= 0 && !errs.has("SERVICE_AUTH_MAX_TTL") {
+				errs.add("serviceauth", "SERVICE_AUTH_MAX_TTL", "must be positive")
+			}
+			checkURL(errs, "serviceauth", "SERVICE_AUTH_REDIS_URL", c.ServiceAuthRedisURL, "redis", "rediss")
+		},
+	}
+
+	SubsystemTracing = Subsystem{
+		Name: "tracing",
+		Required: []Setting{
+			{Env: "TRACING_EXPORTER", value: func(c *Config) string { return c.TracingExporter }},
+		},
+		Optional: []Setting{
+			{Env: "TRACING_SAMPLE_RATIO", value: func(c *Config) string { return strconv.FormatFloat(c.TracingSampleRatio, 'g', -1, 64) }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if _, ok := TracingExporters[c.TracingExporter]; !ok {
+				errs.add("tracing", "TRACING_EXPORTER", fmt.Sprintf("%q is not one of otlp, stdout, memory", c.TracingExporter))
+			}
+			if (c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1) && !errs.has("TRACING_SAMPLE_RATIO") {
+				errs.add("tracing", "TRACING_SAMPLE_RATIO", "must be between 0 and 1")
+			}
+		},
+	}
+
+	SubsystemSessionCache = Subsystem{
+		Name:      "sessioncache",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "SESSION_CACHE_SIZE", value: func(c *Config) string { return strconv.Itoa(c.SessionCacheSize) }},
+			{Env: "SESSION_CACHE_TTL", value: func(c *Config) string { return c.SessionCacheTTL.String() }},
+			{Env: "SESSION_CACHE_NEGATIVE_TTL", value: func(c *Config) string { return c.SessionCacheNegativeTTL.String() }},
+			{Env: "SESSION_REVOCATION_TTL", value: func(c *Config) string { return c.SessionRevocationTTL.String() }},
+		},
+		Optional: []Setting{
+			{Env: "SESSION_CACHE_REDIS_URL", Secret: true, value: func(c *Config) string { return c.SessionCacheRedisURL }},
+			{Env: "SESSION_REVOKERS", value: func(c *Config) string { return strings.Join(c.SessionRevokers, ",") }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if c.SessionCacheSize <= 0 && !errs.has("SESSION_CACHE_SIZE") {
+				errs.add("sessioncache", "SESSION_CACHE_SIZE", "must be a positive number of entries")
+			}
+			if c.SessionCacheTTL <= 0 && !errs.has("SESSION_CACHE_TTL") {
+				errs.add("sessioncache", "SESSION_CACHE_TTL", "must be positive")
+			}
+			if c.SessionCacheNegativeTTL <= 0 && !errs.has("SESSION_CACHE_NEGATIVE_TTL") {
+				errs.add("sessioncache", "SESSION_CACHE_NEGATIVE_TTL", "must be positive")
+			}
+			// Revoked credentials must expire before their deny list entry
+			if !errs.has("SESSION_REVOCATION_TTL") {
+				switch {
+				case c.SessionRevocationTTL < c.SessionCacheTTL && !errs.has("SESSION_CACHE_TTL"):
+					errs.add("sessioncache", "SESSION_REVOCATION_TTL", "must be at least SESSION_CACHE_TTL")
+				case c.ConvexAuthEnabled() && c.SessionRevocationTTL < c.ConvexAuthMaxTokenTTL && !errs.has("CONVEX_AUTH_MAX_TOKEN_TTL"):
+					errs.add("sessioncache", "SESSION_REVOCATION_TTL", "must be at least CONVEX_AUTH_MAX_TOKEN_TTL")
+				case c.ServiceAuthEnabled() && c.SessionRevocationTTL < c.ServiceAuthMaxTTL && !errs.has("SERVICE_AUTH_MAX_TTL"):
+					errs.add("sessioncache", "SESSION_REVOCATION_TTL", "must be at least SERVICE_AUTH_MAX_TTL")
+				}
+			}
+			checkURL(errs, "sessioncache", "SESSION_CACHE_REDIS_URL", c.SessionCacheRedisURL, "redis", "rediss")
+			if len(c.SessionRevokers) > 0 {
+				keys, _ := ParseServiceKeys(c.ServiceAuthKeys)
+				for _, id := range c.SessionRevokers {
+					if !slices.ContainsFunc(keys, func(key ServiceKey) bool { return key.ID == id }) {
+						errs.add("sessioncache", "SESSION_REVOKERS", fmt.Sprintf("%q is not a key in SERVICE_AUTH_KEYS", id))
+					}
+				}
+				// The in-memory deny list only covers the replica a
+				// revocation reaches
+				if c.SessionCacheRedisURL == "" {
+					errs.add("sessioncache", "SESSION_REVOKERS", "requires SESSION_CACHE_REDIS_URL so revocations reach every replica")
+				}
+			}
+		},
+	}
+
+	SubsystemInvites = Subsystem{
+		Name: "invites",
+		Required: []Setting{
+			{Env: "INVITE_SIGNING_KEY", Secret: true, value: func(c *Config) string { return c.InviteSigningKey }},
+		},
+		Optional: []Setting{
+			{Env: "INVITE_MAX_TTL", value: func(c *Config) string { return c.InviteMaxTTL.String() }},
+			{Env: "INVITE_REDIS_URL", Secret: true, value: func(c *Config) string { return c.InviteRedisURL }},
+			{Env: "INVITE_LINK_BASE", value: func(c *Config) string { return c.InviteLinkBase }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if len(c.InviteSigningKey) < 32 {
+				errs.add("invites", "INVITE_SIGNING_KEY", "must be at least 32 characters")
+			}
+			if c.InviteMaxTTL <= 0 && !errs.has("INVITE_MAX_TTL") {
+				errs.add("invites", "INVITE_MAX_TTL", "must be positive")
+			}
+			checkURL(errs, "invites", "INVITE_REDIS_URL", c.InviteRedisURL, "redis", "rediss")
+			checkURL(errs, "invites", "INVITE_LINK_BASE", c.InviteLinkBase, "http", "https")
+		},
+	}
+
+	SubsystemLobby = Subsystem{
+		Name:      "lobby",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "LOBBY_TICKET_TTL", value: func(c *Config) string { return c.LobbyTicketTTL.String() }},
+		},
+		Optional: []Setting{
+			{Env: "LOBBY_REDIS_URL", Secret: true, value: func(c *Config) string { return c.LobbyRedisURL }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if c.LobbyTicketTTL < time.Minute && !errs.has("LOBBY_TICKET_TTL") {
+				errs.add("lobby", "LOBBY_TICKET_TTL", "must be at least 1m")
+			}
+			checkURL(errs, "lobby", "LOBBY_REDIS_URL", c.LobbyRedisURL, "redis", "rediss")
+		},
+	}
+
+	SubsystemEvents = Subsystem{
+		Name:      "events",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "EVENT_RETENTION", value: func(c *Config) string { return c.EventRetention.String() }},
+			{Env: "EVENT_STORE_MAX_EVENTS", value: func(c *Config) string { return strconv.Itoa(c.EventStoreMaxEvents) }},
+			{Env: "EVENT_STREAM_BUFFER", value: func(c *Config) string { return strconv.Itoa(c.EventStreamBuffer) }},
+			{Env: "EVENT_STREAM_HEARTBEAT", value: func(c *Config) string { return c.EventStreamHeartbeat.String() }},
+		},
+		Optional: []Setting{
+			{Env: "EVENT_STORE_PATH", value: func(c *Config) string { return c.EventStorePath }},
+			{Env: "EVENT_STORE_REDIS_URL", Secret: true, value: func(c *Config) string { return c.EventStoreRedisURL }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if c.EventRetention < time.Hour && !errs.has("EVENT_RETENTION") {
+				errs.add("events", "EVENT_RETENTION", "must be at least 1h")
+			}
+			if (c.EventStoreMaxEvents < 1000 || c.EventStoreMaxEvents > 10000000) && !errs.has("EVENT_STORE_MAX_EVENTS") {
+				errs.add("events", "EVENT_STORE_MAX_EVENTS", "must be between 1000 and 10000000 events")
+			}
+			if (c.EventStreamBuffer < 1 || c.EventStreamBuffer > 100000) && !errs.has("EVENT_STREAM_BUFFER") {
+				errs.add("events", "EVENT_STREAM_BUFFER", "must be between 1 and 100000 events")
+			}
+			if c.EventStreamHeartbeat < time.Second && !errs.has("EVENT_STREAM_HEARTBEAT") {
+				errs.add("events", "EVENT_STREAM_HEARTBEAT", "must be at least 1s")
+			}
+			checkURL(errs, "events", "EVENT_STORE_REDIS_URL", c.EventStoreRedisURL, "redis", "rediss")
+			if c.EventStorePath != "" && c.EventStoreRedisURL != "" {
+				errs.add("events", "EVENT_STORE_PATH", "cannot be combined with EVENT_STORE_REDIS_URL")
+			}
+		},
+	}
+
+	SubsystemWebhooks = Subsystem{
+		Name:      "webhooks",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "WEBHOOK_QUEUE_SIZE", value: func(c *Config) string { return strconv.Itoa(c.WebhookQueueSize) }},
+			{Env: "WEBHOOK_WORKERS", value: func(c *Config) string { return strconv.Itoa(c.WebhookWorkers) }},
+			{Env: "WEBHOOK_MAX_ATTEMPTS", value: func(c *Config) string { return strconv.Itoa(c.WebhookMaxAttempts) }},
+			{Env: "WEBHOOK_RETRY_BACKOFF", value: func(c *Config) string { return c.WebhookRetryBackoff.String() }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if c.WebhookQueueSize <= 0 && !errs.has("WEBHOOK_QUEUE_SIZE") {
+				errs.add("webhooks", "WEBHOOK_QUEUE_SIZE", "must be a positive number of jobs")
+			}
+			if (c.WebhookWorkers < 1 || c.WebhookWorkers > 64) && !errs.has("WEBHOOK_WORKERS") {
+				errs.add("webhooks", "WEBHOOK_WORKERS", "must be between 1 and 64")
+			}
+			if (c.WebhookMaxAttempts < 1 || c.WebhookMaxAttempts > 20) && !errs.has("WEBHOOK_MAX_ATTEMPTS") {
+				errs.add("webhooks", "WEBHOOK_MAX_ATTEMPTS", "must be between 1 and 20")
+			}
+			if c.WebhookRetryBackoff < 100*time.Millisecond && !errs.has("WEBHOOK_RETRY_BACKOFF") {
+				errs.add("webhooks", "WEBHOOK_RETRY_BACKOFF", "must be at least 100ms")
+			}
+		},
+	}
+
+	SubsystemWebhookForward = Subsystem{
+		Name: "webhookforward",
+		Required: []Setting{
+			{Env: "WEBHOOK_FORWARD_URLS", value: func(c *Config) string { return strings.Join(c.WebhookForwardURLs, ",") }},
+			{Env: "WEBHOOK_FORWARD_SECRET", Secret: true, value: func(c *Config) string { return c.WebhookForwardSecret }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			for _, target := range c.WebhookForwardURLs {
+				checkURL(errs, "webhookforward", "WEBHOOK_FORWARD_URLS", target, "http", "https")
+			}
+			if len(c.WebhookForwardSecret) < 32 {
+				errs.add("webhookforward", "WEBHOOK_FORWARD_SECRET", "must be at least 32 characters")
+			}
+		},
+	}
+
+	SubsystemRateLimit = Subsystem{
+		Name:      "ratelimit",
+		Mandatory: true,
+		Required: []Setting{
+			{Env: "RATE_LIMIT_DEFAULT", Reloadable: true, value: func(c *Config) string { return strconv.Itoa(c.RateLimitDefault) }},
+			{Env: "RATE_LIMIT_WINDOW", Reloadable: true, value: func(c *Config) string { return c.RateLimitWindow.String() }},
+		},
+		check: func(c *Config, errs *ValidationError) {
+			if c.RateLimitDefault <= 0 && !errs.has("RATE_LIMIT_DEFAULT") {
+				errs.add("ratelimit", "RATE_LIMIT_DEFAULT", "must be a positive number of requests")
+			}
+			if c.RateLimitWindow < time.Second && !errs.has("RATE_LIMIT_WINDOW") {
+				errs.add("ratelimit", "RATE_LIMIT_WINDOW", "must be at least 1s")
+			}
+		},
+	}
+)
+
+// Subsystems lists every subsystem in the order they are validated and reported
+var Subsystems = []Subsystem{
+	SubsystemServer,
+	SubsystemLiveKit,
+	SubsystemR2,
+	SubsystemUnsplash,
+	SubsystemConvex,
+	SubsystemConvexAuth,
+	SubsystemSessionCache,
+	SubsystemInvites,
+	SubsystemLobby,
+	SubsystemEvents,
+	SubsystemWebhooks,
+	SubsystemWebhookForward,
+	SubsystemRateLimit,
+	SubsystemAdmin,
+	SubsystemServiceAuth,
+	SubsystemTracing,
+}
+
+// TokenRoles lists the LiveKit token roles accepted in LIVEKIT_PUBLIC_ROLES
+var TokenRoles = map[string]struct{}{
+	"host":     {},
+	"speaker":  {},
+	"viewer":   {},
+	"recorder": {},
+	"observer": {},
+}
+
+// TracingExporters lists the accepted TRACING_EXPORTER values
+var TracingExporters = map[string]struct{}{
+	"otlp":   {},
+	"stdout": {},
+	"memory": {},
+}
+
+// Problem is a single invalid or missing setting
+type Problem struct {
+	Subsystem string
+	Key       string
+	Message   string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Key, p.Message, p.Subsystem)
+}
+
+// ValidationError lists every problem found while validating a Config
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("invalid configuration (%d problems):", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, "  - "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *ValidationError) add(subsystem, key, message string) {
+	e.Problems = append(e.Problems, Problem{Subsystem: subsystem, Key: key, Message: message})
+}
+
+func (e *ValidationError) has(key string) bool {
+	for _, p := range e.Problems {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks every subsystem and returns a *ValidationError if any
+// setting is missing or malformed. It normalizes PORT in place.
+func (c *Config) Validate() error {
+	errs := &ValidationError{}
+	c.validate(errs)
+	if len(errs.Problems) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c *Config) validate(errs *ValidationError) {
+	for _, sub := range Subsystems {
+		if !sub.Mandatory && !sub.Enabled(c) {
+			continue
+		}
+
+		var missing []string
+		for _, setting := range sub.Required {
+			// A secret that failed to load has already been reported
+			if setting.Value(c) == "" && !errs.has(setting.Env) && !errs.has(setting.Env+"_FILE") {
+				missing = append(missing, setting.Env)
+			}
+		}
+		for _, key := range missing {
+			if sub.Mandatory {
+				errs.add(sub.Name, key, "is required")
+			} else {
+				errs.add(sub.Name, key, fmt.Sprintf("is required when %s is partially configured", sub.Name))
+			}
+		}
+
+		if sub.check != nil {
+			sub.check(c, errs)
+		}
+	}
+}
+
+// Summary returns one line per subsystem describing its settings, with
+// secret values redacted
+func (c *Config) Summary() []string {
+	lines := make([]string, 0, len(Subsystems))
+	for _, sub := range Subsystems {
+		state := "disabled"
+		if sub.Mandatory || sub.Enabled(c) {
+			state = "enabled"
+		}
+
+		settings := sub.settings()
+		parts := make([]string, 0, len(settings))
+		for _, setting := range settings {
+			parts = append(parts, setting.Env+"="+redact(setting, c))
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s] %s", sub.Name, state, strings.Join(parts, " ")))
+	}
+	return lines
+}
+
+// redact masks secrets entirely and shows other values as-is
+func redact(s Setting, cfg *Config) string {
+	value := s.Value(cfg)
+	switch {
+	case value == "":
+		return "<unset>"
+	case s.Secret:
+		return "<redacted>"
+	default:
+		return value
+	}
+}
+
+func checkURL(errs *ValidationError, subsystem, key, value string, schemes ...string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		errs.add(subsystem, key, fmt.Sprintf("is not a valid URL: %v", err))
+		return
+	}
+	if u.Host == "" {
+		errs.add(subsystem, key, "must be an absolute URL with a host")
+		return
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return
+		}
+	}
+	errs.add(subsystem, key, fmt.Sprintf("scheme must be one of %s", strings.Join(schemes, ", ")))
+}
+
+// normalizePort accepts "8080", ":8080" or "host:8080" and returns an
+// address suitable for echo.Start
+func normalizePort(value string) (string, error) {
+	addr := value
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid port or address", value)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("%q is not a valid port (1-65535)", value)
+	}
+	return addr, nil
+}
+
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("origin must not contain a path, query or fragment")
+	}
+	return nil
+}
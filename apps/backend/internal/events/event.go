@@ -0,0 +1,67 @@
+package events
+
+import (
+	"time"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// Event is the part of a verified LiveKit webhook event we keep
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Room      string    `json:"room,omitempty"`
+	RoomSID   string    `json:"roomSid,omitempty"`
+	// Hosts are the room's owner and moderators when the event arrived,
+	// which the receiver fills in from the room's ACL
+	Hosts          []string `json:"hosts,omitempty"`
+	Identity       string   `json:"identity,omitempty"`
+	ParticipantSID string   `json:"participantSid,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	TrackSID       string   `json:"trackSid,omitempty"`
+	TrackType      string   `json:"trackType,omitempty"`
+	// Recording is set for egress events writing a file
+	Recording *Recording `json:"recording,omitempty"`
+	// Ingress is set for ingress events
+	Ingress *Ingress `json:"ingress,omitempty"`
+}
+
+// FromWebhook extracts an Event from a webhook event. Events without a
+// creation time are stamped with now.
+func FromWebhook(event *lkproto.WebhookEvent, now time.Time) *Event {
+	ev := &Event{
+		ID:        event.GetId(),
+		Type:      event.GetEvent(),
+		CreatedAt: time.Unix(event.GetCreatedAt(), 0).UTC(),
+	}
+	if event.GetCreatedAt() == 0 {
+		ev.CreatedAt = now.UTC().Truncate(time.Second)
+	}
+	if room := event.GetRoom(); room != nil {
+		ev.Room = room.Name
+		ev.RoomSID = room.Sid
+	}
+	if participant := event.GetParticipant(); participant != nil {
+		ev.Identity = participant.Identity
+		ev.ParticipantSID = participant.Sid
+		ev.Name = participant.Name
+	}
+	if track := event.GetTrack(); track != nil {
+		ev.TrackSID = track.Sid
+		ev.TrackType = track.Type.String()
+	}
+	if info := event.GetEgressInfo(); info != nil {
+		if ev.Room == "" {
+			ev.Room = info.RoomName
+		}
+		ev.Recording = RecordingFromEgress(info)
+	}
+	if info := event.GetIngressInfo(); info != nil {
+		if ev.Room == "" {
+			ev.Room = info.RoomName
+		}
+		ev.Ingress = IngressFromInfo(info)
+	}
+	return ev
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind before
+// it is dropped; it then reconnects and catches up from the feed's buffer
+const subscriberBuffer = 64
+
+// Message is an event published on a Feed, with its position in the feed
+type Message struct {
+	ID    string
+	Event *Event
+}
+
+// Feed fans live events out to subscribers and keeps the most recent ones
+// so a subscriber that reconnects with the last ID it saw can catch up.
+// IDs are "<epoch>-<sequence>", where the epoch changes with every process,
+// so IDs from before a restart are recognised as unknown.
+type Feed struct {
+	epoch string
+
+	mu     sync.Mutex
+	size   int
+	buffer []Message
+	seq    uint64
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives a Feed's messages on C until it is closed, the
+// feed closes, or it falls too far behind
+type Subscription struct {
+	C <-chan Message
+	// Position is the ID of the last message published before the
+	// subscription started
+	Position string
+
+	feed *Feed
+	ch   chan Message
+}
+
+// NewFeed creates a feed that keeps the last size messages for replay
+func NewFeed(size int) *Feed {
+	return &Feed{
+		epoch:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:   size,
+		buffer: make([]Message, 0, size),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns ev the next ID and delivers it to every subscriber.
+// Subscribers whose buffer is full are dropped.
+func (f *Feed) Publish(ev *Event) Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	msg := Message{ID: f.id(f.seq), Event: ev}
+	if len(f.buffer) == f.size {
+		copy(f.buffer, f.buffer[1:])
+		f.buffer = f.buffer[:f.size-1]
+	}
+	f.buffer = append(f.buffer, msg)
+
+	if f.closed {
+		return msg
+	}
+	for sub := range f.subs {
+		select {
+		case sub.ch <- msg:
+		default:
+			f.drop(sub)
+		}
+	}
+	return msg
+}
+
+// Subscribe starts receiving messages. With the ID of the last message a
+// previous subscription saw, the messages published since are returned for
+// replay; complete is false when some of them are no longer buffered, or
+// the ID is from another process, and the subscriber has to start over.
+func (f *Feed) Subscribe(lastID string) (sub *Subscription, replay []Message, complete bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan Message, subscriberBuffer)
+	sub = &Subscription{C: ch, Position: f.id(f.seq), feed: f, ch: ch}
+	if f.closed {
+		close(ch)
+	} else {
+		f.subs[sub] = struct{}{}
+	}
+
+	if lastID == "" {
+		return sub, nil, true
+	}
+	seq, ok := f.parse(lastID)
+	if !ok || seq > f.seq {
+		return sub, nil, false
+	}
+	oldest := f.seq - uint64(len(f.buffer)) + 1
+	if seq+1 < oldest {
+		return sub, nil, false
+	}
+	start := len(f.buffer) - int(f.seq-seq)
+	return sub, append([]Message(nil), f.buffer[start:]...), true
+}
+
+// Close ends every subscription and refuses new ones
+func (f *Feed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for sub := range f.subs {
+		f.drop(sub)
+	}
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	if _, ok := s.feed.subs[s]; ok {
+		s.feed.drop(s)
+	}
+}
+
+func (f *Feed) drop(sub *Subscription) {
+	delete(f.subs, sub)
+	close(sub.ch)
+}
+
+func (f *Feed) id(seq uint64) string {
+	return f.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+func (f *Feed) parse(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != f.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
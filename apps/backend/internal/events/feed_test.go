@@ -0,0 +1,101 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFeedReplay(t *testing.T) {
+	f := NewFeed(3)
+	var ids []string
+	for i := 1; i <= 5; i++ {
+		ids = append(ids, f.Publish(&Event{ID: fmt.Sprintf("EV_%d", i)}).ID)
+	}
+	other := NewFeed(3)
+	other.epoch = "restarted"
+
+	tests := []struct {
+		name     string
+		lastID   string
+		complete bool
+		// replay holds the IDs of the events replayed
+		replay []string
+	}{
+		{"new subscriber", "", true, nil},
+		{"up to date", ids[4], true, nil},
+		{"missed one", ids[3], true, []string{"EV_5"}},
+		{"missed every buffered event", ids[1], true, []string{"EV_3", "EV_4", "EV_5"}},
+		{"missed more than the buffer", ids[0], false, nil},
+		{"ID from another process", other.id(4), false, nil},
+		{"ID from the future", f.id(6), false, nil},
+		{"malformed ID", "garbage", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, complete := f.Subscribe(tt.lastID)
+			defer sub.Close()
+			if complete != tt.complete {
+				t.Fatalf("complete = %v, want %v", complete, tt.complete)
+			}
+			if sub.Position != ids[4] {
+				t.Errorf("position = %s, want %s", sub.Position, ids[4])
+			}
+			if len(replay) != len(tt.replay) {
+				t.Fatalf("replayed %d events, want %v", len(replay), tt.replay)
+			}
+			for i, msg := range replay {
+				if msg.Event.ID != tt.replay[i] {
+					t.Errorf("replay[%d] = %s, want %s", i, msg.Event.ID, tt.replay[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFeedDropsSlowSubscribers(t *testing.T) {
+	f := NewFeed(8)
+	slow, _, _ := f.Subscribe("")
+	fast, _, _ := f.Subscribe("")
+	defer fast.Close()
+
+	var last string
+	for i := range subscriberBuffer + 1 {
+		last = f.Publish(&Event{ID: fmt.Sprintf("EV_%d", i)}).ID
+		<-fast.C
+	}
+
+	// The slow subscriber gets what fit in its buffer, then is closed
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("slow subscriber received %d events, want %d", received, subscriberBuffer)
+	}
+	f.Publish(&Event{ID: "EV_next"})
+	if msg := <-fast.C; msg.Event.ID != "EV_next" {
+		t.Errorf("fast subscriber received %s, want EV_next", msg.Event.ID)
+	}
+
+	// Reconnecting from the last event it saw, it is replayed what the
+	// feed still buffers
+	_, replay, complete := f.Subscribe(f.id(uint64(subscriberBuffer)))
+	if !complete || len(replay) != 2 || replay[0].ID != last {
+		t.Errorf("reconnect replay = %d events, complete %v", len(replay), complete)
+	}
+}
+
+func TestFeedClose(t *testing.T) {
+	f := NewFeed(4)
+	sub, _, _ := f.Subscribe("")
+	f.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription open after Close")
+	}
+	late, _, _ := f.Subscribe("")
+	if _, ok := <-late.C; ok {
+		t.Error("subscription after Close is open")
+	}
+	sub.Close()
+	f.Publish(&Event{ID: "EV_1"})
+}
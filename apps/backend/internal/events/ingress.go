@@ -0,0 +1,51 @@
+package events
+
+import (
+	"strings"
+	"time"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// Ingress is an RTMP or WHIP ingress and the state of its stream. It never
+// carries the stream URL or key, so it can be shown to anyone in the room.
+type Ingress struct {
+	IngressID string `json:"ingressId"`
+	Name      string `json:"name,omitempty"`
+	// InputType is "rtmp" or "whip"
+	InputType           string `json:"inputType"`
+	Room                string `json:"room"`
+	ParticipantIdentity string `json:"participantIdentity"`
+	ParticipantName     string `json:"participantName,omitempty"`
+	// Status is inactive, buffering, publishing, error or complete
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+}
+
+// IngressFromInfo describes an ingress, leaving out its credentials
+func IngressFromInfo(info *lkproto.IngressInfo) *Ingress {
+	in := &Ingress{
+		IngressID:           info.IngressId,
+		Name:                info.Name,
+		InputType:           strings.ToLower(strings.TrimSuffix(info.InputType.String(), "_INPUT")),
+		Room:                info.RoomName,
+		ParticipantIdentity: info.ParticipantIdentity,
+		ParticipantName:     info.ParticipantName,
+		Status:              "inactive",
+	}
+	if state := info.State; state != nil {
+		in.Status = strings.ToLower(strings.TrimPrefix(state.Status.String(), "ENDPOINT_"))
+		in.Error = state.Error
+		if state.StartedAt > 0 {
+			startedAt := time.Unix(0, state.StartedAt).UTC().Truncate(time.Second)
+			in.StartedAt = &startedAt
+		}
+		if state.EndedAt > 0 {
+			endedAt := time.Unix(0, state.EndedAt).UTC().Truncate(time.Second)
+			in.EndedAt = &endedAt
+		}
+	}
+	return in
+}
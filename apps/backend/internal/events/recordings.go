@@ -0,0 +1,122 @@
+package events
+
+import (
+	"strings"
+	"time"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// Recording is an egress writing a room composite or a single track to a
+// file, as last reported by LiveKit
+type Recording struct {
+	// EgressID and Kind are empty for files found in storage that no
+	// kept webhook reports
+	EgressID string `json:"egressId,omitempty"`
+	Room     string `json:"room"`
+	// Kind is "room" for a room composite or "track" for a single track
+	Kind     string `json:"kind,omitempty"`
+	TrackSID string `json:"trackSid,omitempty"`
+	// Status is starting, active, ending, complete, failed, aborted or
+	// limit_reached
+	Status string `json:"status"`
+	// Key is the file's object key, final once the egress has ended
+	Key             string     `json:"key"`
+	Size            int64      `json:"size,omitempty"`
+	DurationSeconds int64      `json:"durationSeconds,omitempty"`
+	StartedAt       *time.Time `json:"startedAt,omitempty"`
+	EndedAt         *time.Time `json:"endedAt,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// RecordingFromEgress describes a room composite or track egress writing a
+// file, and returns nil for any other egress
+func RecordingFromEgress(info *lkproto.EgressInfo) *Recording {
+	r := &Recording{
+		EgressID: info.EgressId,
+		Room:     info.RoomName,
+		Status:   strings.ToLower(strings.TrimPrefix(info.Status.String(), "EGRESS_")),
+		Error:    info.Error,
+	}
+	switch req := info.Request.(type) {
+	case *lkproto.EgressInfo_RoomComposite:
+		r.Kind = "room"
+		if outputs := req.RoomComposite.GetFileOutputs(); len(outputs) > 0 {
+			r.Key = outputs[0].Filepath
+		} else {
+			r.Key = req.RoomComposite.GetFile().GetFilepath()
+		}
+	case *lkproto.EgressInfo_Track:
+		r.Kind = "track"
+		r.TrackSID = req.Track.TrackId
+		r.Key = req.Track.GetFile().GetFilepath()
+	default:
+		return nil
+	}
+
+	file := info.GetFile()
+	if len(info.FileResults) > 0 {
+		file = info.FileResults[0]
+	}
+	if file != nil {
+		if file.Filename != "" {
+			r.Key = file.Filename
+		}
+		r.Size = file.Size
+		r.DurationSeconds = int64(time.Duration(file.Duration).Seconds())
+	}
+	if r.Key == "" {
+		return nil
+	}
+	if info.StartedAt > 0 {
+		startedAt := time.Unix(0, info.StartedAt).UTC().Truncate(time.Second)
+		r.StartedAt = &startedAt
+	}
+	if info.EndedAt > 0 {
+		endedAt := time.Unix(0, info.EndedAt).UTC().Truncate(time.Second)
+		r.EndedAt = &endedAt
+	}
+	return r
+}
+
+// Ended reports whether the egress has stopped, successfully or not
+func (r *Recording) Ended() bool {
+	switch r.Status {
+	case "starting", "active", "ending":
+		return false
+	}
+	return true
+}
+
+// Recordings returns the recordings whose file is under prefix, most
+// recently started first
+func (s *Store) Recordings(prefix string) []*Recording {
+	s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []*Recording{}
+	for i := len(s.egress) - 1; i >= 0; i-- {
+		r := s.recordings[s.egress[i]]
+		if strings.HasPrefix(r.Key, prefix) {
+			copied := *r
+			out = append(out, &copied)
+		}
+	}
+	return out
+}
+
+// indexRecording keeps the latest state of an egress. Webhooks may arrive
+// out of order, so an ended egress is never reported as running again.
+func (s *Store) indexRecording(r *Recording) {
+	current, ok := s.recordings[r.EgressID]
+	if !ok {
+		s.egress = append(s.egress, r.EgressID)
+		s.recordings[r.EgressID] = r
+		return
+	}
+	if current.Ended() && !r.Ended() {
+		return
+	}
+	s.recordings[r.EgressID] = r
+}
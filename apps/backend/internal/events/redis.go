@@ -0,0 +1,154 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Keys of the shared event log. Event IDs are claimed under
+// redisSeenPrefix so a delivery reaching several replicas is kept once.
+const (
+	redisLogKey     = "events:log"
+	redisSeenPrefix = "events:seen:"
+)
+
+const (
+	// redisTimeout bounds each round trip to the shared log
+	redisTimeout = 5 * time.Second
+	// redisReadBatch is how many log entries are read per round trip
+	redisReadBatch = 1000
+)
+
+// redisAppend claims the event ID, unless it is empty, and appends the
+// event to the log in one step. The claim holds the log entry's ID so the
+// event can be removed again. It returns false when the ID was claimed
+// already.
+var redisAppend = redis.NewScript(`
+if KEYS[2] ~= "" and not redis.call("SET", KEYS[2], "", "NX", "PX", ARGV[2]) then
+	return false
+end
+local id = redis.call("XADD", KEYS[1], "*", "event", ARGV[1])
+if KEYS[2] ~= "" then
+	redis.call("SET", KEYS[2], id, "KEEPTTL")
+end
+return id
+`)
+
+// redisRemove drops the log entry of a claimed event ID and the claim
+var redisRemove = redis.NewScript(`
+local id = redis.call("GET", KEYS[2])
+if id then
+	if id ~= "" then
+		redis.call("XDEL", KEYS[1], id)
+	end
+	redis.call("DEL", KEYS[2])
+end
+return 0
+`)
+
+// redisLog is the event log shared by every replica: a Redis stream each
+// replica appends its webhooks to and reads the others' from
+type redisLog struct {
+	client *redis.Client
+	// last is the ID of the last entry read
+	last string
+}
+
+func newRedisLog(url string) (*redisLog, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &redisLog{client: redis.NewClient(opts)}, nil
+}
+
+// append adds ev to the log unless an event with the same ID was added by
+// any replica within retention, and reports whether it was added
+func (l *redisLog) append(ctx context.Context, ev *Event, retention time.Duration) (bool, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return false, err
+	}
+	seen := ""
+	if ev.ID != "" {
+		seen = redisSeenPrefix + ev.ID
+	}
+	err = redisAppend.Run(ctx, l.client, []string{redisLogKey, seen}, data, retention.Milliseconds()).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("write event: %w", err)
+	}
+	return true, nil
+}
+
+// remove drops the event with id from the log and releases its ID, so it
+// can be appended again
+func (l *redisLog) remove(ctx context.Context, id string) error {
+	if err := redisRemove.Run(ctx, l.client, []string{redisLogKey, redisSeenPrefix + id}).Err(); err != nil {
+		return fmt.Errorf("remove event: %w", err)
+	}
+	return nil
+}
+
+// seek makes the next read start at the newest n entries of the log
+func (l *redisLog) seek(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	entries, err := l.client.XRevRangeN(ctx, redisLogKey, "+", "-", int64(n)+1).Result()
+	if err != nil {
+		return fmt.Errorf("read event store: %w", err)
+	}
+	if len(entries) > n {
+		l.last = entries[n].ID
+	}
+	return nil
+}
+
+// read returns the events appended since the last read, in log order.
+// Entries that do not decode are skipped.
+func (l *redisLog) read(ctx context.Context) ([]*Event, error) {
+	var out []*Event
+	for {
+		start := "-"
+		if l.last != "" {
+			start = "(" + l.last
+		}
+		entries, err := l.client.XRangeN(ctx, redisLogKey, start, "+", redisReadBatch).Result()
+		if err != nil {
+			return out, fmt.Errorf("read event store: %w", err)
+		}
+		for _, entry := range entries {
+			l.last = entry.ID
+			data, _ := entry.Values["event"].(string)
+			var ev Event
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				continue
+			}
+			out = append(out, &ev)
+		}
+		if len(entries) < redisReadBatch {
+			return out, nil
+		}
+	}
+}
+
+// trim drops log entries appended before cutoff. Entry IDs start with the
+// time they were appended, which is close enough to when the event was
+// created.
+func (l *redisLog) trim(ctx context.Context, cutoff time.Time) error {
+	minID := strconv.FormatInt(cutoff.UnixMilli(), 10) + "-0"
+	return l.client.XTrimMinID(ctx, redisLogKey, minID).Err()
+}
+
+func (l *redisLog) close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,215 @@
+package events
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/protocol/webhook"
+)
+
+// Session is one run of a room, from room_started to room_finished.
+// LiveKit gives each run its own room SID.
+type Session struct {
+	SID       string     `json:"sid"`
+	Room      string     `json:"room"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// DurationSeconds runs until now while the session is live
+	DurationSeconds int64 `json:"durationSeconds"`
+	// Participants counts distinct identities that joined
+	Participants int `json:"participants"`
+	// Hosts are the room's owner and moderators as last reported
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Interval is one connection of a participant to a session
+type Interval struct {
+	ParticipantSID  string     `json:"participantSid"`
+	JoinedAt        time.Time  `json:"joinedAt"`
+	LeftAt          *time.Time `json:"leftAt,omitempty"`
+	DurationSeconds int64      `json:"durationSeconds"`
+}
+
+// Attendance is an identity's time in a session across reconnects
+type Attendance struct {
+	Identity        string     `json:"identity"`
+	Name            string     `json:"name,omitempty"`
+	FirstJoinedAt   time.Time  `json:"firstJoinedAt"`
+	LastLeftAt      *time.Time `json:"lastLeftAt,omitempty"`
+	DurationSeconds int64      `json:"durationSeconds"`
+	Intervals       []Interval `json:"intervals"`
+}
+
+// Participation is one connection of an identity to any room
+type Participation struct {
+	Room       string `json:"room"`
+	SessionSID string `json:"sessionSid"`
+	Interval
+}
+
+// CanView reports whether userID hosted the session
+func (s *Session) CanView(userID string) bool {
+	return userID != "" && contains(s.Hosts, userID)
+}
+
+// Sessions returns the recorded sessions of room, newest first
+func (s *Store) Sessions(room string) []*Session {
+	s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sessions := make([]*Session, 0, len(s.rooms[room]))
+	for _, sid := range s.rooms[room] {
+		session, _ := replay(s.sessions[sid], now)
+		sessions = append(sessions, session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].StartedAt.After(sessions[j].StartedAt) })
+	return sessions
+}
+
+// Attendance returns the session with sid and who attended it, longest
+// attendance first, or ErrNotFound
+func (s *Store) Attendance(sid string) (*Session, []*Attendance, error) {
+	s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evs, ok := s.sessions[sid]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	session, intervals := replay(evs, time.Now())
+
+	byIdentity := make(map[string]*Attendance)
+	var attendance []*Attendance
+	for _, iv := range intervals {
+		a, ok := byIdentity[iv.identity]
+		if !ok {
+			a = &Attendance{Identity: iv.identity, FirstJoinedAt: iv.JoinedAt}
+			byIdentity[iv.identity] = a
+			attendance = append(attendance, a)
+		}
+		if iv.name != "" {
+			a.Name = iv.name
+		}
+		a.Intervals = append(a.Intervals, iv.Interval)
+		a.DurationSeconds += iv.DurationSeconds
+		if iv.JoinedAt.Before(a.FirstJoinedAt) {
+			a.FirstJoinedAt = iv.JoinedAt
+		}
+	}
+	for _, a := range attendance {
+		// No last leave while any connection is still open
+		for _, iv := range a.Intervals {
+			if iv.LeftAt == nil {
+				a.LastLeftAt = nil
+				break
+			}
+			if a.LastLeftAt == nil || iv.LeftAt.After(*a.LastLeftAt) {
+				a.LastLeftAt = iv.LeftAt
+			}
+		}
+	}
+	sort.SliceStable(attendance, func(i, j int) bool { return attendance[i].DurationSeconds > attendance[j].DurationSeconds })
+	return session, attendance, nil
+}
+
+// Participation returns identity's connections that started within
+// [from, to), newest first. Zero times leave that end open.
+func (s *Store) Participation(identity string, from, to time.Time) []*Participation {
+	s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var out []*Participation
+	for _, sid := range s.identities[identity] {
+		session, intervals := replay(s.sessions[sid], now)
+		for _, iv := range intervals {
+			if iv.identity != identity {
+				continue
+			}
+			if (!from.IsZero() && iv.JoinedAt.Before(from)) || (!to.IsZero() && !iv.JoinedAt.Before(to)) {
+				continue
+			}
+			out = append(out, &Participation{Room: session.Room, SessionSID: session.SID, Interval: iv.Interval})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].JoinedAt.After(out[j].JoinedAt) })
+	return out
+}
+
+// interval is an Interval along with whose it is
+type interval struct {
+	Interval
+	identity string
+	name     string
+}
+
+// replay derives a session and its participants' connections from the
+// session's events, in the order they arrived. Connections still open
+// when the room finished end with it; those of a live session run until
+// now.
+func replay(evs []*Event, now time.Time) (*Session, []*interval) {
+	session := &Session{SID: evs[0].RoomSID, Room: evs[0].Room, StartedAt: evs[0].CreatedAt}
+	var intervals []*interval
+	open := make(map[string]*interval)
+	identities := make(map[string]bool)
+
+	for _, ev := range evs {
+		if ev.Hosts != nil {
+			session.Hosts = ev.Hosts
+		}
+		if ev.CreatedAt.Before(session.StartedAt) {
+			session.StartedAt = ev.CreatedAt
+		}
+		switch ev.Type {
+		case webhook.EventRoomStarted:
+			session.StartedAt = ev.CreatedAt
+		case webhook.EventRoomFinished:
+			endedAt := ev.CreatedAt
+			session.EndedAt = &endedAt
+		case webhook.EventParticipantJoined:
+			if _, ok := open[ev.ParticipantSID]; ok {
+				continue
+			}
+			iv := &interval{Interval: Interval{ParticipantSID: ev.ParticipantSID, JoinedAt: ev.CreatedAt}, identity: ev.Identity, name: ev.Name}
+			open[ev.ParticipantSID] = iv
+			intervals = append(intervals, iv)
+			identities[ev.Identity] = true
+		case webhook.EventParticipantLeft:
+			if iv, ok := open[ev.ParticipantSID]; ok {
+				leftAt := ev.CreatedAt
+				iv.LeftAt = &leftAt
+				delete(open, ev.ParticipantSID)
+			}
+		}
+	}
+
+	end := now
+	if session.EndedAt != nil {
+		end = *session.EndedAt
+		for _, iv := range open {
+			leftAt := end
+			iv.LeftAt = &leftAt
+		}
+	}
+	session.DurationSeconds = seconds(end.Sub(session.StartedAt))
+	session.Participants = len(identities)
+	for _, iv := range intervals {
+		until := end
+		if iv.LeftAt != nil {
+			until = *iv.LeftAt
+		}
+		iv.DurationSeconds = seconds(until.Sub(iv.JoinedAt))
+	}
+	return session, intervals
+}
+
+func seconds(d time.Duration) int64 {
+	if d < 0 {
+		return 0
+	}
+	return int64(d / time.Second)
+}
@@ -0,0 +1,406 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"myapp/internal/config"
+)
+
+// ErrNotFound is returned for room sessions with no recorded events
+var ErrNotFound = errors.New("session not found")
+
+// pruneInterval is how often events past the retention are dropped
+const pruneInterval = time.Hour
+
+// Store keeps webhook events, de-duplicated by ID, for the retention
+// period. Events are appended to a JSON lines file when a path is set, and
+// the file is compacted as old events are dropped; without a path they
+// only live in memory. Either way each replica has its own store, fed by
+// the webhooks LiveKit delivers to it, unless the store is backed by a
+// Redis log shared by every replica. Queries are answered from the newest
+// maxEvents events, held in memory.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	shared    *redisLog
+	retention time.Duration
+	maxEvents int
+	lastPrune time.Time
+	// readMu serializes reads of the shared log, which mu is not held
+	// across
+	readMu sync.Mutex
+
+	events []*Event
+	seen   map[string]bool
+	// sessions indexes events by room session SID, and rooms and
+	// identities index session SIDs in the order they were first seen
+	sessions   map[string][]*Event
+	rooms      map[string][]string
+	identities map[string][]string
+	// recordings holds the latest state of each egress, and egress their
+	// IDs in the order they were first seen
+	recordings map[string]*Recording
+	egress     []string
+}
+
+// Open loads the newest maxEvents events kept at path, dropping those past
+// retention, and appends new ones to it. An empty path keeps events in
+// memory only.
+func Open(path string, retention time.Duration, maxEvents int) (*Store, error) {
+	s := &Store{path: path, retention: retention, maxEvents: maxEvents, lastPrune: time.Now()}
+	if path != "" {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+	s.dropExpired(time.Now())
+	s.dropOverflow()
+	s.index()
+	if path != "" {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// OpenRedis loads the newest maxEvents events kept in the Redis log at
+// url, dropping those past retention, and appends new ones to it. Every
+// replica opening the same log sees the events the others add.
+func OpenRedis(url string, retention time.Duration, maxEvents int) (*Store, error) {
+	shared, err := newRedisLog(url)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{shared: shared, retention: retention, maxEvents: maxEvents, lastPrune: time.Now()}
+	err = shared.seek(context.Background(), maxEvents)
+	if err == nil {
+		s.events, err = shared.read(context.Background())
+	}
+	if err != nil {
+		shared.close()
+		return nil, err
+	}
+	s.dropExpired(time.Now())
+	s.index()
+	return s, nil
+}
+
+// New opens the store described by the event settings, shared through
+// Redis when EVENT_STORE_REDIS_URL is set
+func New(cfg *config.Config) (*Store, error) {
+	if cfg.EventStoreRedisURL != "" {
+		return OpenRedis(cfg.EventStoreRedisURL, cfg.EventRetention, cfg.EventStoreMaxEvents)
+	}
+	return Open(cfg.EventStorePath, cfg.EventRetention, cfg.EventStoreMaxEvents)
+}
+
+// Add stores ev unless an event with the same ID was already stored, and
+// reports whether it was added. Events without an ID are always added.
+func (s *Store) Add(ev *Event) (bool, error) {
+	if s.shared != nil {
+		return s.addShared(ev)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ev.ID != "" && s.seen[ev.ID] {
+		return false, nil
+	}
+	if s.file != nil {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return false, err
+		}
+		if _, err := s.file.Write(append(line, '\n')); err != nil {
+			return false, fmt.Errorf("write event: %w", err)
+		}
+	}
+	s.events = append(s.events, ev)
+	s.indexEvent(ev)
+
+	dropped := s.dropOverflow()
+	if now := time.Now(); now.Sub(s.lastPrune) >= pruneInterval {
+		s.lastPrune = now
+		dropped = s.dropExpired(now) || dropped
+	}
+	if dropped {
+		s.index()
+		if s.file != nil {
+			return true, s.compact()
+		}
+	}
+	return true, nil
+}
+
+// Remove drops the event with id, so a later Add of it is stored again.
+// It undoes an Add whose event could not be processed.
+func (s *Store) Remove(id string) error {
+	if id == "" {
+		return nil
+	}
+	if s.shared != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+		defer cancel()
+		if err := s.shared.remove(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen[id] {
+		return nil
+	}
+	s.events = slices.DeleteFunc(s.events, func(ev *Event) bool { return ev.ID == id })
+	s.index()
+	if s.file != nil {
+		return s.compact()
+	}
+	return nil
+}
+
+// Seen reports whether an event with id was already stored
+func (s *Store) Seen(id string) bool {
+	if id == "" {
+		return false
+	}
+	s.refresh()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seen[id]
+}
+
+// addShared appends ev to the shared log, unless this or another replica
+// added it already, and reads it back along with the events added since
+// the last read
+func (s *Store) addShared(ev *Event) (bool, error) {
+	s.mu.Lock()
+	seen := ev.ID != "" && s.seen[ev.ID]
+	s.mu.Unlock()
+	if seen {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	added, err := s.shared.append(ctx, ev, s.retention)
+	if err != nil || !added {
+		return false, err
+	}
+	return true, s.pull(ctx)
+}
+
+// refresh reads the events other replicas added to a shared store before
+// it is queried. When Redis is unreachable the events already read are
+// used.
+func (s *Store) refresh() {
+	if s.shared == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	s.pull(ctx)
+}
+
+// pull indexes the events added to the shared log since the last read and
+// drops those past retention, both from memory and from the log. Redis is
+// read without holding mu, so queries answered from memory do not wait on
+// it.
+func (s *Store) pull(ctx context.Context) error {
+	s.readMu.Lock()
+	defer s.readMu.Unlock()
+	evs, err := s.shared.read(ctx)
+
+	s.mu.Lock()
+	for _, ev := range evs {
+		// An event removed and added again is in the log twice
+		if ev.ID != "" && s.seen[ev.ID] {
+			continue
+		}
+		s.events = append(s.events, ev)
+		s.indexEvent(ev)
+	}
+	dropped := s.dropOverflow()
+	now := time.Now()
+	due := err == nil && now.Sub(s.lastPrune) >= pruneInterval
+	if due {
+		s.lastPrune = now
+		dropped = s.dropExpired(now) || dropped
+	}
+	if dropped {
+		s.index()
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if due {
+		return s.shared.trim(ctx, now.Add(-s.retention))
+	}
+	return nil
+}
+
+// Close closes the event file or the connection to the shared log
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shared != nil {
+		return s.shared.close()
+	}
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// load reads the event file. Lines that do not decode, such as one cut
+// short by a crash, are skipped.
+func (s *Store) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open event store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		s.events = append(s.events, &ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read event store: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the event file with the events in memory and reopens
+// it for appending
+func (s *Store) compact() error {
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("compact event store: %w", err)
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, ev := range s.events {
+		if err = enc.Encode(ev); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("compact event store: %w", err)
+	}
+
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open event store: %w", err)
+	}
+	return nil
+}
+
+// dropExpired drops events created before the retention period and
+// reports whether any were dropped
+func (s *Store) dropExpired(now time.Time) bool {
+	cutoff := now.Add(-s.retention)
+	kept := s.events[:0]
+	for _, ev := range s.events {
+		if !ev.CreatedAt.Before(cutoff) {
+			kept = append(kept, ev)
+		}
+	}
+	dropped := len(kept) < len(s.events)
+	clear(s.events[len(kept):])
+	s.events = kept
+	return dropped
+}
+
+// dropOverflow drops the oldest events beyond maxEvents, and a tenth of
+// maxEvents more so the next adds do not drop again, and reports whether
+// any were dropped
+func (s *Store) dropOverflow() bool {
+	if s.maxEvents <= 0 || len(s.events) <= s.maxEvents {
+		return false
+	}
+	n := len(s.events) - s.maxEvents + s.maxEvents/10
+	s.events = slices.Clone(s.events[n:])
+	return true
+}
+
+// index rebuilds the indexes from the events in memory
+func (s *Store) index() {
+	s.seen = make(map[string]bool, len(s.events))
+	s.sessions = make(map[string][]*Event)
+	s.rooms = make(map[string][]string)
+	s.identities = make(map[string][]string)
+	s.recordings = make(map[string]*Recording)
+	s.egress = nil
+	for _, ev := range s.events {
+		s.indexEvent(ev)
+	}
+}
+
+func (s *Store) indexEvent(ev *Event) {
+	if ev.ID != "" {
+		s.seen[ev.ID] = true
+	}
+	if ev.Recording != nil {
+		s.indexRecording(ev.Recording)
+	}
+	if ev.RoomSID == "" {
+		return
+	}
+	if _, ok := s.sessions[ev.RoomSID]; !ok {
+		s.rooms[ev.Room] = append(s.rooms[ev.Room], ev.RoomSID)
+	}
+	s.sessions[ev.RoomSID] = append(s.sessions[ev.RoomSID], ev)
+	if ev.Identity != "" && !contains(s.identities[ev.Identity], ev.RoomSID) {
+		s.identities[ev.Identity] = append(s.identities[ev.Identity], ev.RoomSID)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
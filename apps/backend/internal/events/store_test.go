@@ -0,0 +1,87 @@
+package events
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sessionEvent(i int) *Event {
+	return &Event{
+		ID:        fmt.Sprintf("EV_%d", i),
+		Type:      "room_started",
+		Room:      "standup",
+		RoomSID:   fmt.Sprintf("RM_%d", i),
+		CreatedAt: time.Now(),
+	}
+}
+
+func TestStoreKeepsNewestEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := Open(path, time.Hour, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 250 {
+		if _, err := s.Add(sessionEvent(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions := s.Sessions("standup")
+	if n := len(sessions); n > 100 || n < 90 {
+		t.Fatalf("%d sessions held, want the newest 90 to 100", n)
+	}
+	if sid := sessions[0].SID; sid != "RM_249" {
+		t.Errorf("newest session = %s, want RM_249", sid)
+	}
+	if s.Seen("EV_0") {
+		t.Error("oldest event still held")
+	}
+	s.Close()
+
+	// The file is compacted to what is held in memory
+	reopened, err := Open(path, time.Hour, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got, want := len(reopened.Sessions("standup")), len(sessions); got != want {
+		t.Errorf("%d sessions after reopening, want %d", got, want)
+	}
+}
+
+func TestStoreRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	s, err := Open(path, time.Hour, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := sessionEvent(1)
+	if added, err := s.Add(ev); !added || err != nil {
+		t.Fatalf("Add = %v, %v", added, err)
+	}
+	if added, _ := s.Add(ev); added {
+		t.Fatal("duplicate added")
+	}
+	if err := s.Remove(ev.ID); err != nil {
+		t.Fatal(err)
+	}
+	if s.Seen(ev.ID) || len(s.Sessions("standup")) != 0 {
+		t.Fatal("removed event still held")
+	}
+	s.Close()
+
+	reopened, err := Open(path, time.Hour, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Seen(ev.ID) {
+		t.Error("removed event reloaded from the file")
+	}
+	if added, err := reopened.Add(ev); !added || err != nil {
+		t.Errorf("Add after Remove = %v, %v, want added", added, err)
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"myapp/internal/config"
+	"myapp/internal/logging"
+	"myapp/internal/tracing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminHandler exposes operational endpoints protected by the admin token
+type AdminHandler struct {
+	settings *config.Reloader
+	// traces is nil unless the memory trace exporter is selected
+	traces *tracing.Recorder
+	logger *slog.Logger
+}
+
+func NewAdminHandler(settings *config.Reloader, traces *tracing.Recorder, logger *slog.Logger) *AdminHandler {
+	return &AdminHandler{settings: settings, traces: traces, logger: logger}
+}
+
+// ReloadConfig handles POST /admin/reload
+func (h *AdminHandler) ReloadConfig(c echo.Context) error {
+	result, err := h.settings.Reload()
+	if err != nil {
+		resp := map[string]interface{}{
+			"error": "reload failed, keeping current settings: " + err.Error(),
+		}
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			problems := make([]string, 0, len(verr.Problems))
+			for _, p := range verr.Problems {
+				problems = append(problems, p.String())
+			}
+			resp["error"] = "invalid configuration, keeping current settings"
+			resp["problems"] = problems
+		}
+		logging.For(c, h.logger).Warn("configuration reload rejected", "error", err)
+		return c.JSON(http.StatusUnprocessableEntity, resp)
+	}
+
+	logging.For(c, h.logger).Info("configuration reloaded",
+		"changed", result.Changed,
+		"requires_restart", result.RequiresRestart,
+	)
+	return c.JSON(http.StatusOK, result)
+}
+
+// ListTraces handles GET /admin/traces. It returns the spans kept by the
+// memory trace exporter, optionally limited to one trace with ?trace=<id>.
+func (h *AdminHandler) ListTraces(c echo.Context) error {
+	if h.traces == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "memory trace exporter is not enabled"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"spans": h.traces.Spans(c.QueryParam("trace")),
+	})
+}
@@ -1,18 +1,29 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"myapp/internal/background"
+	"myapp/internal/config"
+	"myapp/internal/health"
+	"myapp/internal/logging"
+	"myapp/internal/metrics"
+	"myapp/internal/tracing"
+
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // APIHubHandler handles API Hub related endpoints
@@ -20,6 +31,11 @@ type APIHubHandler struct {
 	// In-memory rate limiter (in production, use Redis)
 	rateLimiter map[string]*RateLimitEntry
 	mu          sync.RWMutex
+	// Rate limit defaults for keys that don't carry their own limit
+	settings *config.Reloader
+	// Tracks fire-and-forget usage logging
+	tasks  *background.Tracker
+	logger *slog.Logger
 }
 
 type RateLimitEntry struct {
@@ -83,10 +99,30 @@ type Pagination struct {
 	HasMore bool `json:"hasMore"`
 }
 
+// convexClient calls the Convex HTTP actions, labelled by path in metrics
+// and traces
+var convexClient = &http.Client{
+	Transport: tracing.Transport("convex", nil, metrics.Transport("convex", nil, nil)),
+}
+
+// postConvex POSTs a JSON body to a Convex HTTP action. ctx carries the
+// trace that is propagated to Convex.
+func postConvex(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return convexClient.Do(req)
+}
+
 // NewAPIHubHandler creates a new API Hub handler
-func NewAPIHubHandler() *APIHubHandler {
+func NewAPIHubHandler(settings *config.Reloader, tasks *background.Tracker, logger *slog.Logger) *APIHubHandler {
 	return &APIHubHandler{
 		rateLimiter: make(map[string]*RateLimitEntry),
+		settings:    settings,
+		tasks:       tasks,
+		logger:      logger,
 	}
 }
 
@@ -101,8 +137,7 @@ func (h *APIHubHandler) APIKeyMiddleware(convexURL string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			startTime := time.Now()
-
-			fmt.Printf("API Request: %s %s\n", c.Request().Method, c.Path())
+			logger := logging.For(c, h.logger)
 
 			// Store convexURL in context for handlers
 			c.Set("convexURL", convexURL)
@@ -110,7 +145,7 @@ func (h *APIHubHandler) APIKeyMiddleware(convexURL string) echo.MiddlewareFunc {
 			// Extract API key from Authorization header
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
-				fmt.Println("Missing Authorization header")
+				logger.Debug("missing Authorization header")
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "Missing Authorization header",
 				})
@@ -128,15 +163,22 @@ func (h *APIHubHandler) APIKeyMiddleware(convexURL string) echo.MiddlewareFunc {
 			keyHash := HashAPIKey(apiKey)
 
 			// Validate key against Convex (in production, cache this)
-			validatedKey, err := h.validateKeyWithConvex(convexURL, keyHash)
+			validatedKey, err := h.validateKeyWithConvex(c.Request().Context(), logger, convexURL, keyHash)
+			if err != nil {
+				logger.Warn("API key validation failed", "error", err)
+			}
 			if err != nil || validatedKey == nil {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
 					"error": "Invalid or expired API key",
 				})
 			}
 
+			logging.With(c, "api_key_id", validatedKey.KeyID, "user_id", validatedKey.UserID)
+			logger = logging.For(c, h.logger)
+
 			// Check rate limit
 			if !h.checkRateLimit(validatedKey.KeyID, validatedKey.RateLimit) {
+				logger.Info("rate limit exceeded", "limit", validatedKey.RateLimit)
 				return c.JSON(http.StatusTooManyRequests, map[string]string{
 					"error": "Rate limit exceeded",
 				})
@@ -149,8 +191,14 @@ func (h *APIHubHandler) APIKeyMiddleware(convexURL string) echo.MiddlewareFunc {
 			// Execute the handler
 			err = next(c)
 
-			// Log usage (async in production)
-			go h.logUsage(convexURL, validatedKey, c, startTime)
+			// Log usage in the background, in the request's trace;
+			// shutdown waits for it
+			record := newUsageRecord(validatedKey, c, startTime)
+			spanCtx := trace.SpanContextFromContext(c.Request().Context())
+			h.tasks.Go("usage log "+record.KeyID, func(ctx context.Context) {
+				ctx = trace.ContextWithSpanContext(ctx, spanCtx)
+				h.logUsage(ctx, logger, convexURL, record)
+			})
 
 			return err
 		}
@@ -158,37 +206,32 @@ func (h *APIHubHandler) APIKeyMiddleware(convexURL string) echo.MiddlewareFunc {
 }
 
 // validateKeyWithConvex validates an API key hash against Convex
-func (h *APIHubHandler) validateKeyWithConvex(convexURL, keyHash string) (*ValidatedKey, error) {
+func (h *APIHubHandler) validateKeyWithConvex(ctx context.Context, logger *slog.Logger, convexURL, keyHash string) (*ValidatedKey, error) {
 	if convexURL == "" {
 		// For development without Convex configured, return mock
-		fmt.Println("Warning: CONVEX_URL not configured, using mock validation")
+		logger.Warn("CONVEX_URL not configured, using mock API key validation")
 		return &ValidatedKey{
 			KeyID:       "mock_key_id",
 			UserID:      "mock_user_id",
 			Permissions: []string{"blogs:read", "leads:write", "leads:read"},
-			RateLimit:   100,
+			RateLimit:   h.settings.Current().RateLimitDefault,
 		}, nil
 	}
 
 	// Call Convex HTTP endpoint
 	reqBody, _ := json.Marshal(map[string]string{"keyHash": keyHash})
 	validateURL := convexURL + "/api/validate-key"
-	fmt.Printf("Validating API key against: %s\n", validateURL)
-	fmt.Printf("Full key hash: %s\n", keyHash)
-	
-	resp, err := http.Post(validateURL, "application/json", strings.NewReader(string(reqBody)))
+
+	resp, err := postConvex(ctx, validateURL, reqBody)
 	if err != nil {
-		fmt.Printf("Convex validation request failed: %v\n", err)
-		return nil, err
+		return nil, fmt.Errorf("convex key validation request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read the full response body for debugging
 	bodyBytes, _ := io.ReadAll(resp.Body)
-	fmt.Printf("Convex response status: %d, body: %s\n", resp.StatusCode, string(bodyBytes))
+	logger.Debug("convex key validation response", "status", resp.StatusCode, "bytes", len(bodyBytes))
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Convex validation returned status: %d\n", resp.StatusCode)
 		return nil, fmt.Errorf("validation failed with status %d", resp.StatusCode)
 	}
 
@@ -201,11 +244,10 @@ func (h *APIHubHandler) validateKeyWithConvex(convexURL, keyHash string) (*Valid
 	}
 
 	if err := json.Unmarshal(bodyBytes, &result); err != nil {
-		fmt.Printf("Failed to decode Convex response: %v\n", err)
-		return nil, err
+		return nil, fmt.Errorf("decode convex key validation response: %w", err)
 	}
 
-	fmt.Printf("Convex validation result: valid=%v, keyId=%s\n", result.Valid, result.KeyID)
+	logger.Debug("convex key validation result", "valid", result.Valid, "api_key_id", result.KeyID)
 
 	if !result.Valid {
 		return nil, nil
@@ -224,18 +266,24 @@ func (h *APIHubHandler) checkRateLimit(keyID string, limit int) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	settings := h.settings.Current()
+	if limit <= 0 {
+		limit = settings.RateLimitDefault
+	}
+
 	now := time.Now()
 	entry, exists := h.rateLimiter[keyID]
 
 	if !exists || now.After(entry.ResetTime) {
 		h.rateLimiter[keyID] = &RateLimitEntry{
 			Count:     1,
-			ResetTime: now.Add(time.Minute),
+			ResetTime: now.Add(settings.RateLimitWindow),
 		}
 		return true
 	}
 
 	if entry.Count >= limit {
+		metrics.RateLimitRejected()
 		return false
 	}
 
@@ -243,33 +291,50 @@ func (h *APIHubHandler) checkRateLimit(keyID string, limit int) bool {
 	return true
 }
 
+// usageRecord captures a request for usage logging. The echo.Context is
+// recycled once the request completes, so it can't be read from the
+// background goroutine.
+type usageRecord struct {
+	KeyID          string `json:"apiKeyId"`
+	UserID         string `json:"userId"`
+	Endpoint       string `json:"endpoint"`
+	Method         string `json:"method"`
+	StatusCode     int    `json:"statusCode"`
+	ResponseTimeMs int64  `json:"responseTimeMs"`
+	IPAddress      string `json:"ipAddress"`
+	UserAgent      string `json:"userAgent"`
+}
+
+func newUsageRecord(key *ValidatedKey, c echo.Context, startTime time.Time) usageRecord {
+	return usageRecord{
+		KeyID:          key.KeyID,
+		UserID:         key.UserID,
+		Endpoint:       c.Path(),
+		Method:         c.Request().Method,
+		StatusCode:     c.Response().Status,
+		ResponseTimeMs: time.Since(startTime).Milliseconds(),
+		IPAddress:      c.RealIP(),
+		UserAgent:      c.Request().UserAgent(),
+	}
+}
+
 // logUsage logs API usage to Convex
-func (h *APIHubHandler) logUsage(convexURL string, key *ValidatedKey, c echo.Context, startTime time.Time) {
-	responseTime := time.Since(startTime).Milliseconds()
-	
-	// Log format for debugging
-	fmt.Printf("API Usage: key=%s endpoint=%s method=%s time=%dms\n",
-		key.KeyID, c.Path(), c.Request().Method, responseTime)
+func (h *APIHubHandler) logUsage(ctx context.Context, logger *slog.Logger, convexURL string, record usageRecord) {
+	logger.DebugContext(ctx, "API usage",
+		"status", record.StatusCode,
+		"response_time_ms", record.ResponseTimeMs,
+	)
 
 	if convexURL == "" {
 		return
 	}
 
 	// Call Convex HTTP endpoint to log usage
-	reqBody, _ := json.Marshal(map[string]interface{}{
-		"apiKeyId":       key.KeyID,
-		"userId":         key.UserID,
-		"endpoint":       c.Path(),
-		"method":         c.Request().Method,
-		"statusCode":     c.Response().Status,
-		"responseTimeMs": responseTime,
-		"ipAddress":      c.RealIP(),
-		"userAgent":      c.Request().UserAgent(),
-	})
+	reqBody, _ := json.Marshal(record)
 
-	resp, err := http.Post(convexURL+"/api/log-usage", "application/json", strings.NewReader(string(reqBody)))
+	resp, err := postConvex(ctx, convexURL+"/api/log-usage", reqBody)
 	if err != nil {
-		fmt.Printf("Failed to log API usage: %v\n", err)
+		logger.ErrorContext(ctx, "failed to log API usage", "error", err)
 		return
 	}
 	resp.Body.Close()
@@ -342,9 +407,9 @@ func (h *APIHubHandler) CreateLead(c echo.Context) error {
 	
 	reqBody, _ := json.Marshal(reqData)
 
-	resp, err := http.Post(convexURL+"/api/leads", "application/json", strings.NewReader(string(reqBody)))
+	resp, err := postConvex(c.Request().Context(), convexURL+"/api/leads", reqBody)
 	if err != nil {
-		fmt.Printf("Convex lead creation error: %v\n", err)
+		logging.For(c, h.logger).Error("convex lead creation failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to create lead",
 		})
@@ -473,9 +538,9 @@ func (h *APIHubHandler) ListBlogs(c echo.Context) error {
 		"page":  page,
 	})
 
-	resp, err := http.Post(convexURL+"/api/blogs", "application/json", strings.NewReader(string(reqBody)))
+	resp, err := postConvex(c.Request().Context(), convexURL+"/api/blogs", reqBody)
 	if err != nil {
-		fmt.Printf("Convex blogs fetch error: %v\n", err)
+		logging.For(c, h.logger).Error("convex blogs fetch failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to fetch blogs",
 		})
@@ -521,9 +586,9 @@ func (h *APIHubHandler) GetBlog(c echo.Context) error {
 	// Call Convex HTTP endpoint
 	reqBody, _ := json.Marshal(map[string]string{"slug": slug})
 
-	resp, err := http.Post(convexURL+"/api/blogs/by-slug", "application/json", strings.NewReader(string(reqBody)))
+	resp, err := postConvex(c.Request().Context(), convexURL+"/api/blogs/by-slug", reqBody)
 	if err != nil {
-		fmt.Printf("Convex blog fetch error: %v\n", err)
+		logging.For(c, h.logger).Error("convex blog fetch failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to fetch blog",
 		})
@@ -628,7 +693,7 @@ func (h *APIHubHandler) GetOpenAPISpec(c echo.Context) error {
 func (h *APIHubHandler) HealthCheck(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"status":  "healthy",
-		"version": "1.0.0",
+		"version": health.Build().Version,
 		"time":    time.Now().Format(time.RFC3339),
 	})
 }
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
-	"runtime"
 	"time"
 
+	"myapp/internal/health"
+	"myapp/internal/logging"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -17,17 +20,79 @@ type HealthResponse struct {
 	Timestamp string `json:"timestamp"`
 	Uptime    string `json:"uptime"`
 	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
 	GoVersion string `json:"go_version,omitempty"`
 }
 
+// ReadinessResponse represents the readiness probe response
+type ReadinessResponse struct {
+	*health.Report
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
 // HealthCheck returns the server health status
 func HealthCheck(c echo.Context) error {
+	build := health.Build()
 	return c.JSON(http.StatusOK, HealthResponse{
 		Status:    "healthy",
 		Service:   "backend",
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		Uptime:    time.Since(startTime).Round(time.Second).String(),
-		Version:   "1.0.0",
-		GoVersion: runtime.Version(),
+		Version:   build.Version,
+		Commit:    build.Commit,
+		GoVersion: build.GoVersion,
+	})
+}
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	checker *health.Checker
+	logger  *slog.Logger
+}
+
+func NewHealthHandler(checker *health.Checker, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{checker: checker, logger: logger}
+}
+
+// Livez handles GET /livez. It only reports that the process is serving
+// requests and never checks dependencies.
+func (h *HealthHandler) Livez(c echo.Context) error {
+	return c.JSON(http.StatusOK, HealthResponse{
+		Status:    "alive",
+		Service:   "backend",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Uptime:    time.Since(startTime).Round(time.Second).String(),
+	})
+}
+
+// Readyz handles GET /readyz. It probes the dependencies and responds 503
+// when a required one is down.
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	report := h.checker.Check(c.Request().Context())
+	build := health.Build()
+
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	for _, check := range report.Checks {
+		if check.Error != "" {
+			logging.For(c, h.logger).Warn("readiness check failed",
+				"check", check.Name,
+				"required", check.Required,
+				"error", check.Error,
+			)
+		}
+	}
+	return c.JSON(status, ReadinessResponse{
+		Report:  report,
+		Version: build.Version,
+		Commit:  build.Commit,
 	})
 }
+
+// BuildInfo handles GET /version
+func BuildInfo(c echo.Context) error {
+	return c.JSON(http.StatusOK, health.Build())
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"myapp/internal/events"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HistoryHandler reports on past and live room sessions from the webhook
+// event store
+type HistoryHandler struct {
+	store  *events.Store
+	logger *slog.Logger
+}
+
+func NewHistoryHandler(store *events.Store, logger *slog.Logger) *HistoryHandler {
+	return &HistoryHandler{store: store, logger: logger}
+}
+
+// ListSessions lists a room's sessions, newest first. Only sessions the
+// authenticated user owned or moderated are included, so this works for
+// rooms that have since been closed.
+func (h *HistoryHandler) ListSessions(c echo.Context) error {
+	userID, _ := c.Get("userId").(string)
+
+	sessions := []*events.Session{}
+	for _, session := range h.store.Sessions(c.Param("room")) {
+		if session.CanView(userID) {
+			sessions = append(sessions, session)
+		}
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// GetAttendance returns who attended a room session and for how long. Only
+// the session's owner or moderators may see it.
+func (h *HistoryHandler) GetAttendance(c echo.Context) error {
+	session, attendance, err := h.store.Attendance(c.Param("session"))
+	if errors.Is(err, events.ErrNotFound) || (err == nil && session.Room != c.Param("room")) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "session not found"})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	userID, _ := c.Get("userId").(string)
+	if !session.CanView(userID) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: "not allowed to view this session"})
+	}
+
+	if attendance == nil {
+		attendance = []*events.Attendance{}
+	}
+	return c.JSON(http.StatusOK, AttendanceResponse{Session: session, Attendance: attendance})
+}
+
+// MyParticipation returns the authenticated user's time in rooms, with
+// optional ?from= and ?to= RFC 3339 bounds on when they joined
+func (h *HistoryHandler) MyParticipation(c echo.Context) error {
+	userID, _ := c.Get("userId").(string)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "authentication required"})
+	}
+	return h.participation(c, userID)
+}
+
+// Participation handles GET /admin/participation/:identity, returning any
+// identity's time in rooms for billing and reporting
+func (h *HistoryHandler) Participation(c echo.Context) error {
+	return h.participation(c, c.Param("identity"))
+}
+
+func (h *HistoryHandler) participation(c echo.Context, identity string) error {
+	var from, to time.Time
+	for name, bound := range map[string]*time.Time{"from": &from, "to": &to} {
+		raw := c.QueryParam(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: name + " must be an RFC 3339 time"})
+		}
+		*bound = t
+	}
+
+	res := ParticipationResponse{Identity: identity, Participation: h.store.Participation(identity, from, to)}
+	if res.Participation == nil {
+		res.Participation = []*events.Participation{}
+	}
+	for _, p := range res.Participation {
+		res.TotalSeconds += p.DurationSeconds
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"myapp/internal/events"
+	"myapp/internal/livekit"
+	"myapp/internal/logging"
+
+	"github.com/labstack/echo/v4"
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// ingressIdentityPrefix starts the identity of every ingress participant,
+// so an ingress can never publish as a user
+const ingressIdentityPrefix = "ingress-"
+
+// ingressInputs maps the input types accepted by CreateIngress
+var ingressInputs = map[string]lkproto.IngressInput{
+	"rtmp": lkproto.IngressInput_RTMP_INPUT,
+	"whip": lkproto.IngressInput_WHIP_INPUT,
+}
+
+// IngressHandler manages RTMP and WHIP ingress, which stream from software
+// such as OBS into a room as a participant. Only the room's owner may
+// create, change or delete them, or see their stream URL and key;
+// moderators may list them.
+type IngressHandler struct {
+	client *livekit.Client
+	logger *slog.Logger
+}
+
+func NewIngressHandler(client *livekit.Client, logger *slog.Logger) *IngressHandler {
+	return &IngressHandler{client: client, logger: logger}
+}
+
+// CreateIngress creates an ingress publishing into the room as the given
+// participant, whose identity always starts with "ingress-", and returns
+// its stream URL and key
+func (h *IngressHandler) CreateIngress(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req CreateIngressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	if req.InputType == "" {
+		req.InputType = "rtmp"
+	}
+	input, ok := ingressInputs[strings.ToLower(req.InputType)]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "inputType must be rtmp or whip"})
+	}
+	if strings.TrimSpace(req.ParticipantIdentity) == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "participantIdentity is required"})
+	}
+	req.ParticipantIdentity = ingressIdentity(req.ParticipantIdentity)
+
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, true)
+	if !ok {
+		return err
+	}
+	if acl.IsBanned(req.ParticipantIdentity) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: "participant identity is banned from this room"})
+	}
+
+	info, err := h.client.CreateIngress(c.Request().Context(), input, roomName, livekit.IngressOptions{
+		Name:                req.Name,
+		ParticipantIdentity: req.ParticipantIdentity,
+		ParticipantName:     req.ParticipantName,
+		EnableTranscoding:   req.EnableTranscoding,
+	})
+	if err != nil {
+		logging.For(c, h.logger).Error("create ingress failed", "room", roomName, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("ingress created", "room", roomName, "ingress_id", info.IngressId, "participant", info.ParticipantIdentity)
+	return c.JSON(http.StatusCreated, ingressResponse(info, true))
+}
+
+// ListIngress lists the room's ingress and the state of their streams.
+// Only the room's owner or moderators may, and only the owner gets the
+// stream URLs and keys.
+func (h *IngressHandler) ListIngress(c echo.Context) error {
+	roomName := c.Param("room")
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	infos, err := h.client.ListIngress(c.Request().Context(), roomName)
+	if err != nil {
+		logging.For(c, h.logger).Error("list ingress failed", "room", roomName, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	userID, _ := c.Get("userId").(string)
+	owner := acl.Owner == userID
+	res := make([]IngressResponse, 0, len(infos))
+	for _, info := range infos {
+		res = append(res, ingressResponse(info, owner))
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// UpdateIngress renames an ingress or changes the participant it publishes
+// as. Empty fields are left unchanged.
+func (h *IngressHandler) UpdateIngress(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req UpdateIngressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+
+	if strings.TrimSpace(req.ParticipantIdentity) != "" {
+		req.ParticipantIdentity = ingressIdentity(req.ParticipantIdentity)
+	}
+
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, true)
+	if !ok {
+		return err
+	}
+	if req.ParticipantIdentity != "" && acl.IsBanned(req.ParticipantIdentity) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: "participant identity is banned from this room"})
+	}
+	if ok, err := h.inRoom(c, roomName); !ok {
+		return err
+	}
+
+	info, err := h.client.UpdateIngress(c.Request().Context(), c.Param("id"), livekit.IngressOptions{
+		Name:                req.Name,
+		ParticipantIdentity: req.ParticipantIdentity,
+		ParticipantName:     req.ParticipantName,
+		EnableTranscoding:   req.EnableTranscoding,
+	})
+	if errors.Is(err, livekit.ErrIngressNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "ingress not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("update ingress failed", "room", roomName, "ingress_id", c.Param("id"), "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("ingress updated", "room", roomName, "ingress_id", info.IngressId, "participant", info.ParticipantIdentity)
+	return c.JSON(http.StatusOK, ingressResponse(info, true))
+}
+
+// DeleteIngress deletes an ingress, ending its stream if it is live
+func (h *IngressHandler) DeleteIngress(c echo.Context) error {
+	roomName := c.Param("room")
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, true); !ok {
+		return err
+	}
+	if ok, err := h.inRoom(c, roomName); !ok {
+		return err
+	}
+
+	err := h.client.DeleteIngress(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, livekit.ErrIngressNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "ingress not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("delete ingress failed", "room", roomName, "ingress_id", c.Param("id"), "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("ingress deleted", "room", roomName, "ingress_id", c.Param("id"))
+	return c.JSON(http.StatusOK, StatusResponse{Status: "deleted"})
+}
+
+// inRoom checks that the ingress named in the path publishes into room,
+// writing the error response when it does not
+func (h *IngressHandler) inRoom(c echo.Context, room string) (bool, error) {
+	info, err := h.client.GetIngress(c.Request().Context(), c.Param("id"))
+	if errors.Is(err, livekit.ErrIngressNotFound) || (err == nil && info.RoomName != room) {
+		return false, c.JSON(http.StatusNotFound, ErrorResponse{Error: "ingress not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("ingress lookup failed", "ingress_id", c.Param("id"), "error", err)
+		return false, c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	return true, nil
+}
+
+// ingressIdentity returns identity under ingressIdentityPrefix, adding the
+// prefix when it is missing
+func ingressIdentity(identity string) string {
+	identity = strings.TrimSpace(identity)
+	if strings.HasPrefix(identity, ingressIdentityPrefix) {
+		return identity
+	}
+	return ingressIdentityPrefix + identity
+}
+
+// ingressResponse describes an ingress, with its stream URL and key only
+// when withCredentials is set
+func ingressResponse(info *lkproto.IngressInfo, withCredentials bool) IngressResponse {
+	res := IngressResponse{Ingress: events.IngressFromInfo(info)}
+	if withCredentials {
+		res.URL = info.Url
+		res.StreamKey = info.StreamKey
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"myapp/internal/livekit"
+)
+
+func newTestIngressHandler(t *testing.T) (*IngressHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}, Banned: []string{"ingress-mallory"}})
+	fake.addRoom(t, "retro", &livekit.RoomACL{Owner: "other"})
+	return NewIngressHandler(fake.client(), testLogger), fake
+}
+
+func TestCreateIngressIsOwnerOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+		room string
+		body string
+		want int
+	}{
+		{"owner", "owner", "standup", `{"participantIdentity":"obs"}`, http.StatusCreated},
+		{"owner over WHIP", "owner", "standup", `{"inputType":"whip","participantIdentity":"ingress-obs"}`, http.StatusCreated},
+		{"moderator", "mod", "standup", `{"participantIdentity":"obs"}`, http.StatusForbidden},
+		{"stranger", "stranger", "standup", `{"participantIdentity":"obs"}`, http.StatusForbidden},
+		{"owner of another room", "other", "standup", `{"participantIdentity":"obs"}`, http.StatusForbidden},
+		{"banned identity", "owner", "standup", `{"participantIdentity":"mallory"}`, http.StatusForbidden},
+		{"missing room", "owner", "planning", `{"participantIdentity":"obs"}`, http.StatusNotFound},
+		{"no identity", "owner", "standup", `{}`, http.StatusBadRequest},
+		{"unknown input", "owner", "standup", `{"inputType":"srt","participantIdentity":"obs"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, fake := newTestIngressHandler(t)
+			var res IngressResponse
+			if code := call(t, h.CreateIngress, tt.user, map[string]string{"room": tt.room}, tt.body, &res); code != tt.want {
+				t.Fatalf("create = %d, want %d", code, tt.want)
+			}
+			if tt.want != http.StatusCreated {
+				if len(fake.ingress) != 0 {
+					t.Errorf("%d ingress created, want none", len(fake.ingress))
+				}
+				return
+			}
+			if res.ParticipantIdentity != "ingress-obs" || res.Room != tt.room {
+				t.Errorf("ingress publishes as %q into %q, want ingress-obs into %s", res.ParticipantIdentity, res.Room, tt.room)
+			}
+			if res.URL == "" || res.StreamKey == "" {
+				t.Error("owner did not get the stream URL and key")
+			}
+		})
+	}
+}
+
+func TestListIngressHidesCredentials(t *testing.T) {
+	h, _ := newTestIngressHandler(t)
+	var created IngressResponse
+	call(t, h.CreateIngress, "owner", map[string]string{"room": "standup"}, `{"participantIdentity":"obs"}`, &created)
+	call(t, h.CreateIngress, "other", map[string]string{"room": "retro"}, `{"participantIdentity":"obs"}`, nil)
+
+	tests := []struct {
+		user        string
+		want        int
+		credentials bool
+	}{
+		{"owner", http.StatusOK, true},
+		{"mod", http.StatusOK, false},
+		{"stranger", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			var listed []IngressResponse
+			if code := call(t, h.ListIngress, tt.user, map[string]string{"room": "standup"}, "", &listed); code != tt.want {
+				t.Fatalf("list = %d, want %d", code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if len(listed) != 1 || listed[0].IngressID != created.IngressID {
+				t.Fatalf("listed %+v, want only %s", listed, created.IngressID)
+			}
+			if got := listed[0].StreamKey != "" && listed[0].URL != ""; got != tt.credentials {
+				t.Errorf("credentials listed = %v, want %v", got, tt.credentials)
+			}
+		})
+	}
+}
+
+func TestUpdateAndDeleteIngressStayInRoom(t *testing.T) {
+	h, fake := newTestIngressHandler(t)
+	var ours, theirs IngressResponse
+	call(t, h.CreateIngress, "owner", map[string]string{"room": "standup"}, `{"participantIdentity":"obs"}`, &ours)
+	call(t, h.CreateIngress, "other", map[string]string{"room": "retro"}, `{"participantIdentity":"obs"}`, &theirs)
+	in := func(id string) map[string]string { return map[string]string{"room": "standup", "id": id} }
+
+	tests := []struct {
+		name string
+		user string
+		id   string
+		want int
+	}{
+		{"moderator", "mod", ours.IngressID, http.StatusForbidden},
+		{"stranger", "stranger", ours.IngressID, http.StatusForbidden},
+		{"ingress of another room", "owner", theirs.IngressID, http.StatusNotFound},
+		{"unknown ingress", "owner", "IN_missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, h.UpdateIngress, tt.user, in(tt.id), `{"name":"renamed"}`, nil); code != tt.want {
+				t.Errorf("update = %d, want %d", code, tt.want)
+			}
+			if code := call(t, h.DeleteIngress, tt.user, in(tt.id), "", nil); code != tt.want {
+				t.Errorf("delete = %d, want %d", code, tt.want)
+			}
+		})
+	}
+	if code := call(t, h.UpdateIngress, "owner", in(ours.IngressID), `{"participantIdentity":"mallory"}`, nil); code != http.StatusForbidden {
+		t.Errorf("update to a banned identity = %d, want 403", code)
+	}
+	fake.mu.Lock()
+	for _, info := range fake.ingress {
+		if info.Name != "" || info.ParticipantIdentity != "ingress-obs" {
+			t.Errorf("refused update changed %s: %+v", info.IngressId, info)
+		}
+	}
+	if len(fake.ingress) != 2 {
+		t.Errorf("%d ingress left after refused deletes, want 2", len(fake.ingress))
+	}
+	fake.mu.Unlock()
+
+	var updated IngressResponse
+	if code := call(t, h.UpdateIngress, "owner", in(ours.IngressID), `{"name":"stage","participantIdentity":"camera"}`, &updated); code != http.StatusOK {
+		t.Fatalf("update by owner = %d, want 200", code)
+	}
+	if updated.Name != "stage" || updated.ParticipantIdentity != "ingress-camera" {
+		t.Errorf("updated ingress = %+v, want it named stage publishing as ingress-camera", updated.Ingress)
+	}
+	if code := call(t, h.DeleteIngress, "owner", in(ours.IngressID), "", nil); code != http.StatusOK {
+		t.Fatalf("delete by owner = %d, want 200", code)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if _, ok := fake.ingress[theirs.IngressID]; !ok || len(fake.ingress) != 1 {
+		t.Errorf("%d ingress left, want only the other room's", len(fake.ingress))
+	}
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"myapp/internal/invite"
+	"myapp/internal/livekit"
+	"myapp/internal/logging"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultInviteTTL is how long invites created without a TTL stay valid
+const defaultInviteTTL = 24 * time.Hour
+
+type InviteHandler struct {
+	client  *livekit.Client
+	invites *invite.Manager
+	tokens  *TokenHandler
+	logger  *slog.Logger
+}
+
+func NewInviteHandler(client *livekit.Client, invites *invite.Manager, tokens *TokenHandler, logger *slog.Logger) *InviteHandler {
+	return &InviteHandler{client: client, invites: invites, tokens: tokens, logger: logger}
+}
+
+// CreateInvite creates an invite to a room. Only its owner or moderators may.
+func (h *InviteHandler) CreateInvite(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req CreateInviteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+
+	role, err := livekit.ParseRole(req.Role)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+	ttl := min(defaultInviteTTL, h.invites.MaxTTL())
+	if req.TTL != 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+	}
+	if ttl <= 0 || ttl > h.invites.MaxTTL() {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "ttl must be positive and at most " + h.invites.MaxTTL().String()})
+	}
+	if req.MaxUses < 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "maxUses must not be negative"})
+	}
+
+	room, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	userID, _ := c.Get("userId").(string)
+	inv, err := h.invites.Create(c.Request().Context(), roomName, room.Sid, string(role), userID, ttl, req.MaxUses)
+	if err != nil {
+		logging.For(c, h.logger).Error("create invite failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("invite created", "room", roomName, "invite_id", inv.ID, "role", inv.Role)
+	return c.JSON(http.StatusOK, h.response(inv))
+}
+
+// ListInvites lists a room's unexpired invites, leaving out those of
+// earlier rooms with the same name. Only its owner or moderators may.
+func (h *InviteHandler) ListInvites(c echo.Context) error {
+	roomName := c.Param("room")
+	room, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	invites, err := h.invites.List(c.Request().Context(), roomName)
+	if err != nil {
+		logging.For(c, h.logger).Error("list invites failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	out := make([]InviteResponse, 0, len(invites))
+	for _, inv := range invites {
+		if inv.RoomSID == room.Sid {
+			out = append(out, h.response(inv))
+		}
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// RevokeInvite revokes one of a room's invites. Only its owner or
+// moderators may.
+func (h *InviteHandler) RevokeInvite(c echo.Context) error {
+	roomName := c.Param("room")
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	err := h.invites.Revoke(c.Request().Context(), roomName, c.Param("id"))
+	if errors.Is(err, invite.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "invite not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("revoke invite failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("invite revoked", "room", roomName, "invite_id", c.Param("id"))
+	return c.JSON(http.StatusOK, StatusResponse{Status: "revoked"})
+}
+
+// RedeemInvite exchanges an invite code for a token to the invite's room
+// and role, issued to the authenticated user. The invite's role is granted
+// regardless of the token policy's public roles, but not to identities
+// banned from the room. Invites end with their room: one deleted and
+// created again has a new SID, and its earlier invites are refused.
+func (h *InviteHandler) RedeemInvite(c echo.Context) error {
+	var req RedeemInviteRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	if req.Code == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "code is required"})
+	}
+
+	identity, _ := c.Get("userId").(string)
+	if identity == "" {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "authentication required"})
+	}
+	if err := validateParticipantInfo(req.Metadata, req.Attributes); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	// Check the room and bans before counting a use
+	if roomName, roomSID, err := h.invites.Room(req.Code); err == nil {
+		room, ok, err := h.tokens.checkBan(c, roomName, identity)
+		if !ok {
+			return err
+		}
+		if room.Sid != roomSID {
+			logging.For(c, h.logger).Info("invite refused, its room was replaced", "room", roomName)
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: "invite not found or expired"})
+		}
+	}
+
+	inv, err := h.invites.Redeem(c.Request().Context(), req.Code)
+	switch {
+	case errors.Is(err, invite.ErrInvalidCode), errors.Is(err, invite.ErrNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "invite not found or expired"})
+	case errors.Is(err, invite.ErrRevoked), errors.Is(err, invite.ErrExhausted):
+		return c.JSON(http.StatusGone, ErrorResponse{Error: err.Error()})
+	case err != nil:
+		logging.For(c, h.logger).Error("redeem invite failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	role, err := livekit.ParseRole(inv.Role)
+	if err != nil {
+		logging.For(c, h.logger).Error("invite has an unknown role", "invite_id", inv.ID, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("invite redeemed", "room", inv.Room, "invite_id", inv.ID, "uses", inv.Uses)
+	return h.tokens.issue(c, livekit.TokenOptions{
+		Room:       inv.Room,
+		Identity:   identity,
+		Name:       req.Name,
+		Role:       role,
+		Metadata:   req.Metadata,
+		Attributes: req.Attributes,
+	})
+}
+
+func (h *InviteHandler) response(inv *invite.Invite) InviteResponse {
+	return InviteResponse{Invite: inv, Code: h.invites.Code(inv), Link: h.invites.Link(inv)}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"myapp/internal/invite"
+	"myapp/internal/livekit"
+)
+
+func newTestInviteHandler(t *testing.T) (*InviteHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	client := fake.client()
+	policy := &livekit.TokenPolicy{MaxTTL: time.Hour, PublicRoles: map[livekit.Role]bool{livekit.RoleSpeaker: true}}
+	invites := invite.NewManager(invite.NewMemoryStore(), []byte("invite-signing-key-invite-signing-key"), 24*time.Hour, "")
+	tokens := NewTokenHandler(client, policy, testLogger)
+	return NewInviteHandler(client, invites, tokens, testLogger), fake
+}
+
+func TestInviteDoesNotOutliveItsRoom(t *testing.T) {
+	h, fake := newTestInviteHandler(t)
+	rooms := NewRoomHandler(fake.client(), testLogger)
+	room := map[string]string{"room": "standup"}
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "alice"})
+
+	var host, guest InviteResponse
+	if code := call(t, h.CreateInvite, "alice", room, `{"role":"host"}`, &host); code != http.StatusOK {
+		t.Fatalf("create host invite = %d, want 200", code)
+	}
+	call(t, h.CreateInvite, "alice", room, `{"role":"viewer","maxUses":1}`, &guest)
+	if host.RoomSID == "" {
+		t.Fatal("invite has no room SID")
+	}
+
+	var token TokenResponse
+	if code := call(t, h.RedeemInvite, "bob", nil, `{"code":"`+host.Code+`"}`, &token); code != http.StatusOK {
+		t.Fatalf("redeem in its room = %d, want 200", code)
+	}
+	if token.Room != "standup" || token.Role != "host" {
+		t.Errorf("token for %s as %s, want standup as host", token.Room, token.Role)
+	}
+
+	// The room is deleted and someone else creates one with its name
+	if code := call(t, rooms.DeleteRoom, "alice", room, "", nil); code != http.StatusOK {
+		t.Fatalf("delete room = %d, want 200", code)
+	}
+	if code := call(t, rooms.CreateRoom, "mallory", nil, `{"name":"standup"}`, nil); code != http.StatusOK {
+		t.Fatalf("recreate room = %d, want 200", code)
+	}
+
+	for name, code := range map[string]string{"host": host.Code, "guest": guest.Code} {
+		if got := call(t, h.RedeemInvite, "bob", nil, `{"code":"`+code+`"}`, nil); got != http.StatusNotFound {
+			t.Errorf("redeem %s invite in the new room = %d, want 404", name, got)
+		}
+	}
+	var listed []InviteResponse
+	if code := call(t, h.ListInvites, "mallory", room, "", &listed); code != http.StatusOK || len(listed) != 0 {
+		t.Errorf("new room's invites = %d %+v, want 200 and none", code, listed)
+	}
+
+	// Refused invites were not used up
+	inv, err := h.invites.Redeem(context.Background(), guest.Code)
+	if err != nil || inv.Uses != 1 {
+		t.Errorf("guest invite after refusal = %+v, %v; want it still unused", inv, err)
+	}
+}
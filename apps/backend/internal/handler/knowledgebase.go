@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
+	"myapp/internal/logging"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -36,15 +39,17 @@ type KnowledgeBaseHandler struct {
 	collections map[int]*KnowledgeBaseCollection
 	nextKBID    int
 	nextCollID  int
+	logger      *slog.Logger
 }
 
 // NewKnowledgeBaseHandler creates a new knowledge base handler
-func NewKnowledgeBaseHandler() *KnowledgeBaseHandler {
+func NewKnowledgeBaseHandler(logger *slog.Logger) *KnowledgeBaseHandler {
 	return &KnowledgeBaseHandler{
 		kbs:         make(map[int]*KnowledgeBase),
 		collections: make(map[int]*KnowledgeBaseCollection),
 		nextKBID:    1,
 		nextCollID:  1,
+		logger:      logger,
 	}
 }
 
@@ -96,6 +101,7 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c echo.Context) error {
 	}
 	h.kbs[kb.ID] = kb
 	h.nextKBID++
+	logging.For(c, h.logger).Info("knowledge base created", "knowledge_base_id", kb.ID)
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"knowledge_base": kb,
@@ -129,6 +135,7 @@ func (h *KnowledgeBaseHandler) DeleteKnowledgeBase(c echo.Context) error {
 	}
 
 	delete(h.kbs, id)
+	logging.For(c, h.logger).Info("knowledge base deleted", "knowledge_base_id", id)
 
 	return c.JSON(http.StatusOK, map[string]string{
 		"message": "deleted successfully",
@@ -182,6 +189,7 @@ func (h *KnowledgeBaseHandler) CreateCollection(c echo.Context) error {
 	}
 	h.collections[coll.ID] = coll
 	h.nextCollID++
+	logging.For(c, h.logger).Info("collection created", "collection_id", coll.ID, "knowledge_base_id", coll.KnowledgeBaseID)
 
 	return c.JSON(http.StatusCreated, map[string]interface{}{
 		"collection": coll,
@@ -0,0 +1,358 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"myapp/internal/config"
+	"myapp/internal/livekit"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"google.golang.org/protobuf/proto"
+)
+
+// testLogger discards what handlers log
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeLiveKit stands in for LiveKit's RoomService, Egress and Ingress Twirp
+// APIs, keeping rooms, participants, egress and ingress in memory. acls holds the ACLs of
+// its rooms, as the backend's ACL store would.
+type fakeLiveKit struct {
+	*httptest.Server
+	acls         *livekit.MemoryACLStore
+	mu           sync.Mutex
+	rooms        map[string]*lkproto.Room
+	participants map[string][]*lkproto.ParticipantInfo
+	egress       map[string]*lkproto.EgressInfo
+	ingress      map[string]*lkproto.IngressInfo
+	// started holds the egress start requests, in order
+	started []proto.Message
+	// muted holds the tracks muted through MutePublishedTrack, by SID
+	muted []string
+	seq   int
+}
+
+func newFakeLiveKit(t *testing.T) *fakeLiveKit {
+	f := &fakeLiveKit{
+		acls:         livekit.NewMemoryACLStore(),
+		rooms:        make(map[string]*lkproto.Room),
+		participants: make(map[string][]*lkproto.ParticipantInfo),
+		egress:       make(map[string]*lkproto.EgressInfo),
+		ingress:      make(map[string]*lkproto.IngressInfo),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// client returns a LiveKit client talking to f and keeping ACLs in f.acls
+func (f *fakeLiveKit) client() *livekit.Client {
+	cfg := config.Config{LivekitHost: f.URL, LivekitAPIKey: "key", LivekitSecret: "secretsecretsecretsecretsecretsecret"}
+	return livekit.NewClient(&cfg, f.acls)
+}
+
+// addRoom creates the named room with acl, or gives an existing room acl
+func (f *fakeLiveKit) addRoom(t *testing.T, name string, acl *livekit.RoomACL) *lkproto.Room {
+	f.mu.Lock()
+	room, ok := f.rooms[name]
+	if !ok {
+		room = f.newRoom(name)
+	}
+	f.mu.Unlock()
+
+	f.acls.Delete(context.Background(), room.Sid)
+	if acl != nil {
+		if err := f.acls.Create(context.Background(), room.Sid, acl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return room
+}
+
+// join puts identity in room, publishing one audio track
+func (f *fakeLiveKit) join(room, identity string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.participants[room] = append(f.participants[room], &lkproto.ParticipantInfo{
+		Sid:      "PA_" + identity,
+		Identity: identity,
+		Tracks:   []*lkproto.TrackInfo{{Sid: "TR_" + identity, Type: lkproto.TrackType_AUDIO}},
+	})
+}
+
+// identities returns who is in room
+func (f *fakeLiveKit) identities(room string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, p := range f.participants[room] {
+		out = append(out, p.Identity)
+	}
+	return out
+}
+
+func (f *fakeLiveKit) newRoom(name string) *lkproto.Room {
+	f.seq++
+	room := &lkproto.Room{Sid: fmt.Sprintf("RM_%s_%d", name, f.seq), Name: name}
+	f.rooms[name] = room
+	return room
+}
+
+func (f *fakeLiveKit) participant(room, identity string) (int, *lkproto.ParticipantInfo) {
+	i := slices.IndexFunc(f.participants[room], func(p *lkproto.ParticipantInfo) bool { return p.Identity == identity })
+	if i < 0 {
+		return i, nil
+	}
+	return i, f.participants[room][i]
+}
+
+func (f *fakeLiveKit) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var res proto.Message
+	switch r.URL.Path {
+	case "/twirp/livekit.RoomService/CreateRoom":
+		req := &lkproto.CreateRoomRequest{}
+		proto.Unmarshal(body, req)
+		// Like LiveKit, creating an existing room returns it unchanged
+		room, ok := f.rooms[req.Name]
+		if !ok {
+			room = f.newRoom(req.Name)
+			room.Metadata = req.Metadata
+			room.EmptyTimeout = req.EmptyTimeout
+			room.MaxParticipants = req.MaxParticipants
+		}
+		res = room
+	case "/twirp/livekit.RoomService/ListRooms":
+		req := &lkproto.ListRoomsRequest{}
+		proto.Unmarshal(body, req)
+		out := &lkproto.ListRoomsResponse{}
+		for name, room := range f.rooms {
+			if len(req.Names) == 0 || slices.Contains(req.Names, name) {
+				out.Rooms = append(out.Rooms, room)
+			}
+		}
+		res = out
+	case "/twirp/livekit.RoomService/DeleteRoom":
+		req := &lkproto.DeleteRoomRequest{}
+		proto.Unmarshal(body, req)
+		delete(f.rooms, req.Room)
+		delete(f.participants, req.Room)
+		res = &lkproto.DeleteRoomResponse{}
+	case "/twirp/livekit.RoomService/UpdateRoomMetadata":
+		req := &lkproto.UpdateRoomMetadataRequest{}
+		proto.Unmarshal(body, req)
+		room, ok := f.rooms[req.Room]
+		if !ok {
+			twirpError(w, http.StatusNotFound, "not_found", "room not found")
+			return
+		}
+		room.Metadata = req.Metadata
+		res = room
+	case "/twirp/livekit.RoomService/ListParticipants":
+		req := &lkproto.ListParticipantsRequest{}
+		proto.Unmarshal(body, req)
+		res = &lkproto.ListParticipantsResponse{Participants: f.participants[req.Room]}
+	case "/twirp/livekit.RoomService/GetParticipant", "/twirp/livekit.RoomService/RemoveParticipant":
+		req := &lkproto.RoomParticipantIdentity{}
+		proto.Unmarshal(body, req)
+		i, participant := f.participant(req.Room, req.Identity)
+		if participant == nil {
+			twirpError(w, http.StatusNotFound, "not_found", "participant not found")
+			return
+		}
+		res = participant
+		if r.URL.Path == "/twirp/livekit.RoomService/RemoveParticipant" {
+			f.participants[req.Room] = slices.Delete(f.participants[req.Room], i, i+1)
+			res = &lkproto.RemoveParticipantResponse{}
+		}
+	case "/twirp/livekit.RoomService/UpdateParticipant":
+		req := &lkproto.UpdateParticipantRequest{}
+		proto.Unmarshal(body, req)
+		_, participant := f.participant(req.Room, req.Identity)
+		if participant == nil {
+			twirpError(w, http.StatusNotFound, "not_found", "participant not found")
+			return
+		}
+		if req.Name != "" {
+			participant.Name = req.Name
+		}
+		if req.Metadata != "" {
+			participant.Metadata = req.Metadata
+		}
+		if req.Permission != nil {
+			participant.Permission = req.Permission
+		}
+		res = participant
+	case "/twirp/livekit.RoomService/MutePublishedTrack":
+		req := &lkproto.MuteRoomTrackRequest{}
+		proto.Unmarshal(body, req)
+		_, participant := f.participant(req.Room, req.Identity)
+		if participant == nil {
+			twirpError(w, http.StatusNotFound, "not_found", "participant not found")
+			return
+		}
+		var track *lkproto.TrackInfo
+		for _, t := range participant.Tracks {
+			if t.Sid == req.TrackSid {
+				track = t
+			}
+		}
+		if track == nil {
+			twirpError(w, http.StatusNotFound, "not_found", "track not found")
+			return
+		}
+		track.Muted = req.Muted
+		if req.Muted {
+			f.muted = append(f.muted, track.Sid)
+		}
+		res = &lkproto.MuteRoomTrackResponse{Track: track}
+	case "/twirp/livekit.RoomService/SendData":
+		res = &lkproto.SendDataResponse{}
+	case "/twirp/livekit.Egress/StartRoomCompositeEgress":
+		req := &lkproto.RoomCompositeEgressRequest{}
+		proto.Unmarshal(body, req)
+		f.started = append(f.started, req)
+		res = f.start(req.RoomName, &lkproto.EgressInfo{Request: &lkproto.EgressInfo_RoomComposite{RoomComposite: req}})
+	case "/twirp/livekit.Egress/StartTrackEgress":
+		req := &lkproto.TrackEgressRequest{}
+		proto.Unmarshal(body, req)
+		f.started = append(f.started, req)
+		res = f.start(req.RoomName, &lkproto.EgressInfo{Request: &lkproto.EgressInfo_Track{Track: req}})
+	case "/twirp/livekit.Egress/ListEgress":
+		req := &lkproto.ListEgressRequest{}
+		proto.Unmarshal(body, req)
+		out := &lkproto.ListEgressResponse{}
+		for _, info := range f.egress {
+			switch {
+			case req.EgressId != "" && info.EgressId != req.EgressId,
+				req.RoomName != "" && info.RoomName != req.RoomName,
+				req.Active && info.Status > lkproto.EgressStatus_EGRESS_ENDING:
+				continue
+			}
+			out.Items = append(out.Items, info)
+		}
+		res = out
+	case "/twirp/livekit.Egress/StopEgress":
+		req := &lkproto.StopEgressRequest{}
+		proto.Unmarshal(body, req)
+		info, ok := f.egress[req.EgressId]
+		if !ok {
+			twirpError(w, http.StatusNotFound, "not_found", "egress not found")
+			return
+		}
+		if info.Status > lkproto.EgressStatus_EGRESS_ENDING {
+			twirpError(w, http.StatusPreconditionFailed, "failed_precondition", "egress already ended")
+			return
+		}
+		info.Status = lkproto.EgressStatus_EGRESS_ENDING
+		res = info
+	case "/twirp/livekit.Ingress/CreateIngress":
+		req := &lkproto.CreateIngressRequest{}
+		proto.Unmarshal(body, req)
+		f.seq++
+		info := &lkproto.IngressInfo{
+			IngressId:           fmt.Sprintf("IN_%d", f.seq),
+			Name:                req.Name,
+			StreamKey:           fmt.Sprintf("key_%d", f.seq),
+			Url:                 "rtmp://ingress.example/live",
+			InputType:           req.InputType,
+			RoomName:            req.RoomName,
+			ParticipantIdentity: req.ParticipantIdentity,
+			ParticipantName:     req.ParticipantName,
+			State:               &lkproto.IngressState{Status: lkproto.IngressState_ENDPOINT_INACTIVE},
+		}
+		f.ingress[info.IngressId] = info
+		res = info
+	case "/twirp/livekit.Ingress/ListIngress":
+		req := &lkproto.ListIngressRequest{}
+		proto.Unmarshal(body, req)
+		out := &lkproto.ListIngressResponse{}
+		for _, info := range f.ingress {
+			if (req.IngressId == "" || info.IngressId == req.IngressId) && (req.RoomName == "" || info.RoomName == req.RoomName) {
+				out.Items = append(out.Items, info)
+			}
+		}
+		res = out
+	case "/twirp/livekit.Ingress/UpdateIngress":
+		req := &lkproto.UpdateIngressRequest{}
+		proto.Unmarshal(body, req)
+		info, ok := f.ingress[req.IngressId]
+		if !ok {
+			twirpError(w, http.StatusNotFound, "not_found", "ingress not found")
+			return
+		}
+		if req.Name != "" {
+			info.Name = req.Name
+		}
+		if req.ParticipantIdentity != "" {
+			info.ParticipantIdentity = req.ParticipantIdentity
+		}
+		if req.ParticipantName != "" {
+			info.ParticipantName = req.ParticipantName
+		}
+		res = info
+	case "/twirp/livekit.Ingress/DeleteIngress":
+		req := &lkproto.DeleteIngressRequest{}
+		proto.Unmarshal(body, req)
+		info, ok := f.ingress[req.IngressId]
+		if !ok {
+			twirpError(w, http.StatusNotFound, "not_found", "ingress not found")
+			return
+		}
+		delete(f.ingress, req.IngressId)
+		res = info
+	default:
+		twirpError(w, http.StatusNotFound, "bad_route", "no handler for "+r.URL.Path)
+		return
+	}
+
+	data, _ := proto.Marshal(res)
+	w.Header().Set("Content-Type", "application/protobuf")
+	w.Write(data)
+}
+
+func (f *fakeLiveKit) start(room string, info *lkproto.EgressInfo) *lkproto.EgressInfo {
+	if _, ok := f.rooms[room]; !ok {
+		return nil
+	}
+	info.EgressId = fmt.Sprintf("EG_%d", len(f.egress)+1)
+	info.RoomName = room
+	info.Status = lkproto.EgressStatus_EGRESS_STARTING
+	f.egress[info.EgressId] = info
+	return info
+}
+
+// finish marks an egress complete, as LiveKit does once the file is
+// uploaded
+func (f *fakeLiveKit) finish(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.egress[id].Status = lkproto.EgressStatus_EGRESS_COMPLETE
+}
+
+func (f *fakeLiveKit) lastStart(t *testing.T) proto.Message {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.started) == 0 {
+		t.Fatal("no egress started")
+	}
+	return f.started[len(f.started)-1]
+}
+
+func twirpError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"code": code, "msg": msg})
+}
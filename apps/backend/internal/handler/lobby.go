@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"myapp/internal/livekit"
+	"myapp/internal/lobby"
+	"myapp/internal/logging"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Limits on lobby requests
+const (
+	maxLobbyWait     = 30 * time.Second
+	maxLobbyNameSize = 256
+)
+
+type LobbyHandler struct {
+	client *livekit.Client
+	lobby  *lobby.Lobby
+	tokens *TokenHandler
+	logger *slog.Logger
+}
+
+func NewLobbyHandler(client *livekit.Client, l *lobby.Lobby, tokens *TokenHandler, logger *slog.Logger) *LobbyHandler {
+	return &LobbyHandler{client: client, lobby: l, tokens: tokens, logger: logger}
+}
+
+// RequestEntry puts the authenticated user in a room's lobby and returns
+// their pending ticket. Asking again while waiting returns the same ticket.
+func (h *LobbyHandler) RequestEntry(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req LobbyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	if len(req.Name) > maxLobbyNameSize {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "name must be at most " + strconv.Itoa(maxLobbyNameSize) + " bytes"})
+	}
+
+	userID, _ := c.Get("userId").(string)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "authentication required"})
+	}
+
+	_, acl, err := h.client.RoomACL(c.Request().Context(), roomName)
+	if errors.Is(err, livekit.ErrRoomNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("room lookup failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	if !acl.Lobby {
+		return c.JSON(http.StatusConflict, ErrorResponse{Error: "room has no lobby"})
+	}
+	if acl.IsBanned(userID) {
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: "banned from this room"})
+	}
+
+	ticket, err := h.lobby.Request(c.Request().Context(), roomName, userID, req.Name)
+	if err != nil {
+		logging.For(c, h.logger).Error("lobby request failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("guest waiting in lobby", "room", roomName, "ticket_id", ticket.ID)
+	return c.JSON(http.StatusOK, LobbyTicketResponse{Ticket: ticket})
+}
+
+// GetTicket returns the authenticated user's lobby ticket. With ?wait=N it
+// long-polls for up to N seconds (at most 30) while the ticket is pending.
+// Once admitted the response carries a token for the granted role, unless
+// the user has since been banned from the room.
+func (h *LobbyHandler) GetTicket(c echo.Context) error {
+	var wait time.Duration
+	if raw := c.QueryParam("wait"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxLobbyWait {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "wait must be between 0 and " + strconv.Itoa(int(maxLobbyWait.Seconds())) + " seconds"})
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	userID, _ := c.Get("userId").(string)
+	ticket, err := h.lobby.Get(c.Request().Context(), c.Param("ticket"))
+	if err == nil && ticket.UserID != userID {
+		err = lobby.ErrNotFound
+	}
+	if err == nil {
+		ticket, err = h.lobby.Wait(c.Request().Context(), ticket.ID, wait)
+	}
+	if errors.Is(err, lobby.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "ticket not found or expired"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("lobby ticket lookup failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	res := LobbyTicketResponse{Ticket: ticket}
+	if ticket.Status == lobby.StatusAdmitted {
+		if _, ok, err := h.tokens.checkBan(c, ticket.Room, ticket.UserID); !ok {
+			return err
+		}
+		role, err := livekit.ParseRole(ticket.Role)
+		if err == nil {
+			res.Token, err = h.tokens.mint(livekit.TokenOptions{
+				Room:     ticket.Room,
+				Identity: ticket.UserID,
+				Name:     ticket.Name,
+				Role:     role,
+			})
+		}
+		if err != nil {
+			logging.For(c, h.logger).Error("token generation failed", "ticket_id", ticket.ID, "error", err)
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ListLobby lists the guests waiting for a room, oldest first. Only its
+// owner or moderators may.
+func (h *LobbyHandler) ListLobby(c echo.Context) error {
+	roomName := c.Param("room")
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	tickets, err := h.lobby.List(c.Request().Context(), roomName)
+	if err != nil {
+		logging.For(c, h.logger).Error("list lobby failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, tickets)
+}
+
+// Admit lets a waiting guest into the room with a role. Only the room's
+// owner or moderators may.
+func (h *LobbyHandler) Admit(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req AdmitRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	role, err := livekit.ParseRole(req.Role)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	userID, _ := c.Get("userId").(string)
+	ticket, err := h.lobby.Admit(c.Request().Context(), roomName, c.Param("ticket"), string(role), userID)
+	if err != nil {
+		return h.decisionError(c, err)
+	}
+
+	logging.For(c, h.logger).Info("guest admitted", "room", roomName, "ticket_id", ticket.ID, "role", ticket.Role)
+	return c.JSON(http.StatusOK, ticket)
+}
+
+// Deny turns a waiting guest away. Only the room's owner or moderators may.
+func (h *LobbyHandler) Deny(c echo.Context) error {
+	roomName := c.Param("room")
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	userID, _ := c.Get("userId").(string)
+	ticket, err := h.lobby.Deny(c.Request().Context(), roomName, c.Param("ticket"), userID)
+	if err != nil {
+		return h.decisionError(c, err)
+	}
+
+	logging.For(c, h.logger).Info("guest denied", "room", roomName, "ticket_id", ticket.ID)
+	return c.JSON(http.StatusOK, ticket)
+}
+
+func (h *LobbyHandler) decisionError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, lobby.ErrNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "ticket not found or expired"})
+	case errors.Is(err, lobby.ErrDecided):
+		return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+	}
+	logging.For(c, h.logger).Error("lobby decision failed", "error", err)
+	return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+}
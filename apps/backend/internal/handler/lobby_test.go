@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"myapp/internal/livekit"
+	"myapp/internal/lobby"
+)
+
+func newTestLobbyHandler(t *testing.T) (*LobbyHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "interview", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}, Lobby: true, Banned: []string{"banned"}})
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner"})
+	client := fake.client()
+	policy := &livekit.TokenPolicy{MaxTTL: time.Hour, PublicRoles: map[livekit.Role]bool{livekit.RoleSpeaker: true}}
+	tokens := NewTokenHandler(client, policy, testLogger)
+	return NewLobbyHandler(client, lobby.NewLobby(lobby.NewMemoryStore(), time.Minute), tokens, testLogger), fake
+}
+
+func TestLobbyAuthorization(t *testing.T) {
+	h, fake := newTestLobbyHandler(t)
+	room := map[string]string{"room": "interview"}
+
+	if code := call(t, h.RequestEntry, "guest", map[string]string{"room": "standup"}, `{}`, nil); code != http.StatusConflict {
+		t.Errorf("request entry without a lobby = %d, want 409", code)
+	}
+	if code := call(t, h.RequestEntry, "banned", room, `{}`, nil); code != http.StatusForbidden {
+		t.Errorf("request entry while banned = %d, want 403", code)
+	}
+	var ticket LobbyTicketResponse
+	if code := call(t, h.RequestEntry, "guest", room, `{"name":"Guest"}`, &ticket); code != http.StatusOK {
+		t.Fatalf("request entry = %d, want 200", code)
+	}
+	decision := map[string]string{"room": "interview", "ticket": ticket.ID}
+
+	// Only hosts see and decide on the lobby
+	for _, user := range []string{"guest", "stranger"} {
+		if code := call(t, h.ListLobby, user, room, "", nil); code != http.StatusForbidden {
+			t.Errorf("list lobby as %s = %d, want 403", user, code)
+		}
+		if code := call(t, h.Admit, user, decision, `{"role":"speaker"}`, nil); code != http.StatusForbidden {
+			t.Errorf("admit as %s = %d, want 403", user, code)
+		}
+	}
+	if code := call(t, h.GetTicket, "stranger", map[string]string{"ticket": ticket.ID}, "", nil); code != http.StatusNotFound {
+		t.Errorf("someone else's ticket = %d, want 404", code)
+	}
+	var pending LobbyTicketResponse
+	call(t, h.GetTicket, "guest", map[string]string{"ticket": ticket.ID}, "", &pending)
+	if pending.Status != lobby.StatusPending || pending.Token != nil {
+		t.Fatalf("pending ticket = %+v, want no token", pending)
+	}
+
+	if code := call(t, h.Admit, "mod", decision, `{"role":"speaker"}`, nil); code != http.StatusOK {
+		t.Fatalf("admit as moderator = %d, want 200", code)
+	}
+	if code := call(t, h.Deny, "owner", decision, "", nil); code != http.StatusConflict {
+		t.Errorf("deny after admission = %d, want 409", code)
+	}
+	var admitted LobbyTicketResponse
+	if code := call(t, h.GetTicket, "guest", map[string]string{"ticket": ticket.ID}, "", &admitted); code != http.StatusOK {
+		t.Fatalf("admitted ticket = %d, want 200", code)
+	}
+	if admitted.Token == nil || admitted.Token.Room != "interview" || admitted.Token.Role != "speaker" {
+		t.Fatalf("admitted ticket token = %+v, want a speaker token for interview", admitted.Token)
+	}
+
+	// A guest banned after admission no longer gets a token
+	acl := roomACL(t, fake, "interview")
+	acl.Banned = append(acl.Banned, "guest")
+	fake.addRoom(t, "interview", acl)
+	if code := call(t, h.GetTicket, "guest", map[string]string{"ticket": ticket.ID}, "", nil); code != http.StatusForbidden {
+		t.Errorf("admitted ticket after a ban = %d, want 403", code)
+	}
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"myapp/internal/livekit"
+	"myapp/internal/logging"
+
+	"github.com/labstack/echo/v4"
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// maxBannedIdentities bounds a room's ban list, which lives in its metadata
+const maxBannedIdentities = 1000
+
+// Ban list changes refused against the room's current ACL
+var (
+	errBanHost     = errors.New("the room owner and moderators cannot be banned")
+	errBanListFull = fmt.Errorf("at most %d identities can be banned", maxBannedIdentities)
+	errNotBanned   = errors.New("identity is not banned")
+)
+
+// ModerationHandler applies moderation actions to whole rooms. Hosts (the
+// room's owner and moderators) are never affected, nor are recorders,
+// agents and ingress streams.
+type ModerationHandler struct {
+	client *livekit.Client
+	logger *slog.Logger
+}
+
+func NewModerationHandler(client *livekit.Client, logger *slog.Logger) *ModerationHandler {
+	return &ModerationHandler{client: client, logger: logger}
+}
+
+// MuteAll mutes the published audio and/or video tracks of everyone but
+// the hosts. Only the room's owner or moderators may.
+func (h *ModerationHandler) MuteAll(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req MuteAllRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	kinds := map[lkproto.TrackType]bool{lkproto.TrackType_AUDIO: true, lkproto.TrackType_VIDEO: true}
+	if len(req.Kinds) > 0 {
+		kinds = make(map[lkproto.TrackType]bool)
+		for _, kind := range req.Kinds {
+			switch strings.ToLower(kind) {
+			case "audio":
+				kinds[lkproto.TrackType_AUDIO] = true
+			case "video":
+				kinds[lkproto.TrackType_VIDEO] = true
+			default:
+				return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("unknown track kind %q", kind)})
+			}
+		}
+	}
+
+	room, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+	participants, err := h.moderated(c, room, acl)
+	if err != nil {
+		logging.For(c, h.logger).Error("list participants failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	res := ModerationResponse{}
+	for _, participant := range participants {
+		failed := false
+		for _, track := range participant.Tracks {
+			if track.Muted || !kinds[track.Type] {
+				continue
+			}
+			_, err := h.client.RoomService().MutePublishedTrack(c.Request().Context(), &lkproto.MuteRoomTrackRequest{
+				Room:     roomName,
+				Identity: participant.Identity,
+				TrackSid: track.Sid,
+				Muted:    true,
+			})
+			if err != nil {
+				logging.For(c, h.logger).Warn("mute track failed", "identity", participant.Identity, "track", track.Sid, "error", err)
+				failed = true
+				continue
+			}
+			res.Affected++
+		}
+		if failed {
+			res.Failed = append(res.Failed, participant.Identity)
+		}
+	}
+
+	logging.For(c, h.logger).Info("room muted", "room", roomName, "tracks", res.Affected, "failed", len(res.Failed))
+	return c.JSON(http.StatusOK, res)
+}
+
+// KickAll removes everyone but the hosts from the room. Only its owner or
+// moderators may.
+func (h *ModerationHandler) KickAll(c echo.Context) error {
+	roomName := c.Param("room")
+
+	room, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+	participants, err := h.moderated(c, room, acl)
+	if err != nil {
+		logging.For(c, h.logger).Error("list participants failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	res := ModerationResponse{}
+	for _, participant := range participants {
+		err := h.client.RemoveParticipant(c.Request().Context(), roomName, participant.Identity)
+		if errors.Is(err, livekit.ErrParticipantNotFound) {
+			continue
+		}
+		if err != nil {
+			logging.For(c, h.logger).Warn("remove participant failed", "identity", participant.Identity, "error", err)
+			res.Failed = append(res.Failed, participant.Identity)
+			continue
+		}
+		res.Affected++
+	}
+
+	logging.For(c, h.logger).Info("room cleared", "room", roomName, "removed", res.Affected, "failed", len(res.Failed))
+	return c.JSON(http.StatusOK, res)
+}
+
+// ListBans returns the room's ban list. Only its owner or moderators may.
+func (h *ModerationHandler) ListBans(c echo.Context) error {
+	roomName := c.Param("room")
+
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	banned := acl.Banned
+	if banned == nil {
+		banned = []string{}
+	}
+	return c.JSON(http.StatusOK, BanListResponse{Room: roomName, Banned: banned})
+}
+
+// Ban adds an identity to the room's ban list and removes them from the
+// room if they are in it. Hosts cannot be banned. Only the room's owner or
+// moderators may.
+func (h *ModerationHandler) Ban(c echo.Context) error {
+	roomName := c.Param("room")
+	identity := strings.TrimSpace(c.Param("identity"))
+	if identity == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "identity is required"})
+	}
+
+	room, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	acl, err := h.client.UpdateRoomACL(c.Request().Context(), room, func(acl *livekit.RoomACL) error {
+		switch {
+		case acl.CanModerate(identity):
+			return errBanHost
+		case acl.IsBanned(identity):
+			return nil
+		case len(acl.Banned) >= maxBannedIdentities:
+			return errBanListFull
+		}
+		acl.Banned = append(acl.Banned, identity)
+		return nil
+	})
+	switch {
+	case errors.Is(err, errBanHost), errors.Is(err, errBanListFull):
+		return c.JSON(http.StatusConflict, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, livekit.ErrRoomNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	case err != nil:
+		logging.For(c, h.logger).Error("ban failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	err = h.client.RemoveParticipant(c.Request().Context(), roomName, identity)
+	if err != nil && !errors.Is(err, livekit.ErrParticipantNotFound) {
+		logging.For(c, h.logger).Error("remove banned participant failed", "identity", identity, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("participant banned", "room", roomName, "identity", identity)
+	return c.JSON(http.StatusOK, BanListResponse{Room: roomName, Banned: acl.Banned})
+}
+
+// Unban removes an identity from the room's ban list. Only the room's
+// owner or moderators may.
+func (h *ModerationHandler) Unban(c echo.Context) error {
+	roomName := c.Param("room")
+	identity := strings.TrimSpace(c.Param("identity"))
+
+	room, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	acl, err := h.client.UpdateRoomACL(c.Request().Context(), room, func(acl *livekit.RoomACL) error {
+		if !acl.IsBanned(identity) {
+			return errNotBanned
+		}
+		banned := make([]string, 0, len(acl.Banned)-1)
+		for _, id := range acl.Banned {
+			if id != identity {
+				banned = append(banned, id)
+			}
+		}
+		acl.Banned = banned
+		return nil
+	})
+	switch {
+	case errors.Is(err, errNotBanned):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, livekit.ErrRoomNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	case err != nil:
+		logging.For(c, h.logger).Error("unban failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("participant unbanned", "room", roomName, "identity", identity)
+	return c.JSON(http.StatusOK, BanListResponse{Room: roomName, Banned: acl.Banned})
+}
+
+// moderated lists the room's participants that moderation actions apply
+// to: everyone but hosts, recorders, agents and ingress streams
+func (h *ModerationHandler) moderated(c echo.Context, room *lkproto.Room, acl *livekit.RoomACL) ([]*lkproto.ParticipantInfo, error) {
+	res, err := h.client.RoomService().ListParticipants(c.Request().Context(), &lkproto.ListParticipantsRequest{
+		Room: room.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	participants := make([]*lkproto.ParticipantInfo, 0, len(res.Participants))
+	for _, participant := range res.Participants {
+		switch participant.Kind {
+		case lkproto.ParticipantInfo_EGRESS, lkproto.ParticipantInfo_AGENT, lkproto.ParticipantInfo_INGRESS:
+			continue
+		}
+		if acl.CanModerate(participant.Identity) {
+			continue
+		}
+		participants = append(participants, participant)
+	}
+	return participants, nil
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"myapp/internal/livekit"
+	"myapp/internal/webhooks"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+)
+
+func newTestModerationHandler(t *testing.T) (*ModerationHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}})
+	for _, identity := range []string{"owner", "mod", "guest"} {
+		fake.join("standup", identity)
+	}
+	return NewModerationHandler(fake.client(), testLogger), fake
+}
+
+func TestBanAuthorization(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		identity string
+		want     int
+	}{
+		{"guest bans guest", "guest", "guest", http.StatusForbidden},
+		{"stranger bans guest", "stranger", "guest", http.StatusForbidden},
+		{"moderator bans owner", "mod", "owner", http.StatusConflict},
+		{"owner bans moderator", "owner", "mod", http.StatusConflict},
+		{"moderator bans guest", "mod", "guest", http.StatusOK},
+		{"owner bans someone absent", "owner", "stranger", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, fake := newTestModerationHandler(t)
+			params := map[string]string{"room": "standup", "identity": tt.identity}
+			if code := call(t, h.Ban, tt.user, params, "", nil); code != tt.want {
+				t.Fatalf("ban = %d, want %d", code, tt.want)
+			}
+			banned := tt.want == http.StatusOK
+			if got := roomACL(t, fake, "standup").IsBanned(tt.identity); got != banned {
+				t.Errorf("banned = %v, want %v", got, banned)
+			}
+			if present := slices.Contains(fake.identities("standup"), tt.identity); banned && present {
+				t.Error("banned participant still in the room")
+			}
+		})
+	}
+}
+
+func TestBannedParticipantRejoiningIsRemoved(t *testing.T) {
+	h, fake := newTestModerationHandler(t)
+	params := map[string]string{"room": "standup", "identity": "guest"}
+	if code := call(t, h.Ban, "mod", params, "", nil); code != http.StatusOK {
+		t.Fatalf("ban = %d, want 200", code)
+	}
+
+	// The guest's token predates the ban, so LiveKit lets them back in;
+	// the participant_joined webhook removes them again
+	fake.join("standup", "guest")
+	wh, d, tasks := newTestWebhookHandler(t, fake, webhooks.Options{QueueSize: 4, Workers: 1, MaxAttempts: 1})
+	wh.Subscribe(d)
+	fake.mu.Lock()
+	room := fake.rooms["standup"]
+	fake.mu.Unlock()
+	if code, body := deliver(t, wh, &lkproto.WebhookEvent{
+		Id:          "EV_joined",
+		Event:       webhook.EventParticipantJoined,
+		Room:        room,
+		Participant: &lkproto.ParticipantInfo{Identity: "guest"},
+		CreatedAt:   time.Now().Unix(),
+	}); code != http.StatusOK {
+		t.Fatalf("participant_joined = %d %s", code, body)
+	}
+	tasks.Drain(context.Background())
+	if slices.Contains(fake.identities("standup"), "guest") {
+		t.Error("banned participant was not removed on rejoining")
+	}
+
+	// Unbanned, they may stay
+	if code := call(t, h.Unban, "owner", params, "", nil); code != http.StatusOK {
+		t.Fatalf("unban = %d, want 200", code)
+	}
+	fake.join("standup", "guest")
+	deliver(t, wh, &lkproto.WebhookEvent{
+		Id:          "EV_rejoined",
+		Event:       webhook.EventParticipantJoined,
+		Room:        room,
+		Participant: &lkproto.ParticipantInfo{Identity: "guest"},
+		CreatedAt:   time.Now().Unix(),
+	})
+	tasks.Drain(context.Background())
+	if !slices.Contains(fake.identities("standup"), "guest") {
+		t.Error("unbanned participant was removed")
+	}
+}
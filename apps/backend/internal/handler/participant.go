@@ -2,40 +2,151 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 
 	"myapp/internal/livekit"
+	"myapp/internal/logging"
 
 	"github.com/labstack/echo/v4"
 	lkproto "github.com/livekit/protocol/livekit"
 )
 
+// maxParticipantNameSize bounds participant display names
+const maxParticipantNameSize = 256
+
 type ParticipantHandler struct {
 	client *livekit.Client
+	logger *slog.Logger
 }
 
-func NewParticipantHandler(client *livekit.Client) *ParticipantHandler {
-	return &ParticipantHandler{client: client}
+func NewParticipantHandler(client *livekit.Client, logger *slog.Logger) *ParticipantHandler {
+	return &ParticipantHandler{client: client, logger: logger}
 }
 
-// ListParticipants lists all participants in a room
+// ListParticipants lists all participants in a room. Only the room's
+// owner, moderators and current participants may.
 func (h *ParticipantHandler) ListParticipants(c echo.Context) error {
 	roomName := c.Param("room")
 	if roomName == "" {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room name is required"})
 	}
 
+	if _, ok, err := authorizeRoomMember(c, h.client, h.logger, roomName); !ok {
+		return err
+	}
+
 	res, err := h.client.RoomService().ListParticipants(context.Background(), &lkproto.ListParticipantsRequest{
 		Room: roomName,
 	})
 	if err != nil {
+		logging.For(c, h.logger).Error("list participants failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, res.Participants)
 }
 
-// RemoveParticipant removes a participant from a room
+// GetParticipant returns a participant in a room. Only the room's owner,
+// moderators and current participants may.
+func (h *ParticipantHandler) GetParticipant(c echo.Context) error {
+	roomName := c.Param("room")
+	identity := c.Param("identity")
+
+	if roomName == "" || identity == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room and identity are required"})
+	}
+
+	if _, ok, err := authorizeRoomMember(c, h.client, h.logger, roomName); !ok {
+		return err
+	}
+
+	participant, err := h.client.GetParticipant(c.Request().Context(), roomName, identity)
+	if errors.Is(err, livekit.ErrParticipantNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "participant not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("get participant failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, participant)
+}
+
+// UpdateParticipant changes a participant's name, metadata, attributes or
+// permissions. Only the room's owner or moderators may, and only the owner
+// may change the owner.
+func (h *ParticipantHandler) UpdateParticipant(c echo.Context) error {
+	roomName := c.Param("room")
+	identity := c.Param("identity")
+
+	var req UpdateParticipantRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+
+	if roomName == "" || identity == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room and identity are required"})
+	}
+	if req.Name == "" && req.Metadata == "" && len(req.Attributes) == 0 && req.Permission == nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "nothing to update"})
+	}
+	if len(req.Name) > maxParticipantNameSize {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("name must be at most %d bytes", maxParticipantNameSize)})
+	}
+	if err := validateParticipantInfo(req.Metadata, req.Attributes); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+	var sources []lkproto.TrackSource
+	if req.Permission != nil && req.Permission.CanPublishSources != nil {
+		var err error
+		if sources, err = livekit.ParseTrackSources(req.Permission.CanPublishSources); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+	if ok, err := protectOwner(c, acl, identity); !ok {
+		return err
+	}
+
+	update := &lkproto.UpdateParticipantRequest{
+		Room:       roomName,
+		Identity:   identity,
+		Name:       req.Name,
+		Metadata:   req.Metadata,
+		Attributes: req.Attributes,
+	}
+	if req.Permission != nil {
+		// LiveKit replaces the whole permission, so start from the current one
+		participant, err := h.client.GetParticipant(c.Request().Context(), roomName, identity)
+		if errors.Is(err, livekit.ErrParticipantNotFound) {
+			return c.JSON(http.StatusNotFound, ErrorResponse{Error: "participant not found"})
+		}
+		if err != nil {
+			logging.For(c, h.logger).Error("get participant failed", "error", err)
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		}
+		update.Permission = applyPermissionUpdate(participant.Permission, req.Permission, sources)
+	}
+
+	participant, err := h.client.RoomService().UpdateParticipant(c.Request().Context(), update)
+	if err != nil {
+		logging.For(c, h.logger).Error("update participant failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("participant updated", "room", roomName, "identity", identity)
+	return c.JSON(http.StatusOK, participant)
+}
+
+// RemoveParticipant removes a participant from a room. Only the room's
+// owner or moderators may, and moderators cannot remove the owner.
 func (h *ParticipantHandler) RemoveParticipant(c echo.Context) error {
 	roomName := c.Param("room")
 	identity := c.Param("identity")
@@ -44,18 +155,28 @@ func (h *ParticipantHandler) RemoveParticipant(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room and identity are required"})
 	}
 
-	_, err := h.client.RoomService().RemoveParticipant(context.Background(), &lkproto.RoomParticipantIdentity{
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+	if ok, err := protectOwner(c, acl, identity); !ok {
+		return err
+	}
+
+	_, err = h.client.RoomService().RemoveParticipant(c.Request().Context(), &lkproto.RoomParticipantIdentity{
 		Room:     roomName,
 		Identity: identity,
 	})
 	if err != nil {
+		logging.For(c, h.logger).Error("remove participant failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, StatusResponse{Status: "removed"})
 }
 
-// MuteTrack mutes or unmutes a participant's track
+// MuteTrack mutes or unmutes a participant's track. Only the room's owner
+// or moderators may, and moderators cannot mute the owner.
 func (h *ParticipantHandler) MuteTrack(c echo.Context) error {
 	roomName := c.Param("room")
 	identity := c.Param("identity")
@@ -69,15 +190,56 @@ func (h *ParticipantHandler) MuteTrack(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room, identity, and trackSid are required"})
 	}
 
-	res, err := h.client.RoomService().MutePublishedTrack(context.Background(), &lkproto.MuteRoomTrackRequest{
+	_, acl, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+	if ok, err := protectOwner(c, acl, identity); !ok {
+		return err
+	}
+
+	res, err := h.client.RoomService().MutePublishedTrack(c.Request().Context(), &lkproto.MuteRoomTrackRequest{
 		Room:     roomName,
 		Identity: identity,
 		TrackSid: req.TrackSid,
 		Muted:    req.Muted,
 	})
 	if err != nil {
+		logging.For(c, h.logger).Error("mute track failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
 
 	return c.JSON(http.StatusOK, res)
 }
+
+// applyPermissionUpdate returns current with the fields set in update
+// changed. sources are update's parsed CanPublishSources.
+func applyPermissionUpdate(current *lkproto.ParticipantPermission, update *PermissionUpdate, sources []lkproto.TrackSource) *lkproto.ParticipantPermission {
+	perm := &lkproto.ParticipantPermission{}
+	if current != nil {
+		perm.CanSubscribe = current.CanSubscribe
+		perm.CanPublish = current.CanPublish
+		perm.CanPublishData = current.CanPublishData
+		perm.CanPublishSources = current.CanPublishSources
+		perm.Hidden = current.Hidden
+		perm.Recorder = current.Recorder
+		perm.CanUpdateMetadata = current.CanUpdateMetadata
+		perm.Agent = current.Agent
+	}
+	if update.CanSubscribe != nil {
+		perm.CanSubscribe = *update.CanSubscribe
+	}
+	if update.CanPublish != nil {
+		perm.CanPublish = *update.CanPublish
+	}
+	if update.CanPublishData != nil {
+		perm.CanPublishData = *update.CanPublishData
+	}
+	if update.CanPublishSources != nil {
+		perm.CanPublishSources = sources
+	}
+	if update.CanUpdateMetadata != nil {
+		perm.CanUpdateMetadata = *update.CanUpdateMetadata
+	}
+	return perm
+}
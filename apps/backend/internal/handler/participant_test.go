@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"myapp/internal/livekit"
+)
+
+func newTestParticipantHandler(t *testing.T) (*ParticipantHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}})
+	for _, identity := range []string{"owner", "mod", "guest"} {
+		fake.join("standup", identity)
+	}
+	return NewParticipantHandler(fake.client(), testLogger), fake
+}
+
+func TestModeratorsCannotActOnOwner(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		identity string
+		want     int
+	}{
+		{"moderator on owner", "mod", "owner", http.StatusForbidden},
+		{"moderator on guest", "mod", "guest", http.StatusOK},
+		{"owner on moderator", "owner", "mod", http.StatusOK},
+		{"owner on self", "owner", "owner", http.StatusOK},
+		{"guest on guest", "guest", "guest", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]string{"room": "standup", "identity": tt.identity}
+
+			t.Run("mute", func(t *testing.T) {
+				h, fake := newTestParticipantHandler(t)
+				body := `{"trackSid":"TR_` + tt.identity + `","muted":true}`
+				if code := call(t, h.MuteTrack, tt.user, params, body, nil); code != tt.want {
+					t.Fatalf("mute = %d, want %d", code, tt.want)
+				}
+				if muted := slices.Contains(fake.muted, "TR_"+tt.identity); muted != (tt.want == http.StatusOK) {
+					t.Errorf("track muted = %v", muted)
+				}
+			})
+			t.Run("update", func(t *testing.T) {
+				h, fake := newTestParticipantHandler(t)
+				if code := call(t, h.UpdateParticipant, tt.user, params, `{"name":"renamed"}`, nil); code != tt.want {
+					t.Fatalf("update = %d, want %d", code, tt.want)
+				}
+				fake.mu.Lock()
+				_, p := fake.participant("standup", tt.identity)
+				fake.mu.Unlock()
+				if renamed := p.Name == "renamed"; renamed != (tt.want == http.StatusOK) {
+					t.Errorf("participant renamed = %v", renamed)
+				}
+			})
+			t.Run("remove", func(t *testing.T) {
+				h, fake := newTestParticipantHandler(t)
+				if code := call(t, h.RemoveParticipant, tt.user, params, "", nil); code != tt.want {
+					t.Fatalf("remove = %d, want %d", code, tt.want)
+				}
+				if present := slices.Contains(fake.identities("standup"), tt.identity); present != (tt.want != http.StatusOK) {
+					t.Errorf("participant still in room = %v", present)
+				}
+			})
+		})
+	}
+}
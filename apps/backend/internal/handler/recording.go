@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/livekit"
+	"myapp/internal/logging"
+	"myapp/internal/storage"
+
+	"github.com/labstack/echo/v4"
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// playbackURLTTL is how long the presigned URLs of listed recordings last
+const playbackURLTTL = time.Hour
+
+// recordingTimeFormat names recording files after the time they started
+const recordingTimeFormat = "20060102T150405Z"
+
+// RecordingHandler records rooms and tracks through LiveKit Egress into
+// the requesting user's storage, under users/{id}/recordings/
+type RecordingHandler struct {
+	client *livekit.Client
+	r2     *storage.R2Client
+	store  *events.Store
+	logger *slog.Logger
+}
+
+func NewRecordingHandler(client *livekit.Client, r2 *storage.R2Client, store *events.Store, logger *slog.Logger) *RecordingHandler {
+	return &RecordingHandler{client: client, r2: r2, store: store, logger: logger}
+}
+
+// StartRecording starts recording the room's composite, or one of its
+// tracks. Only the room's owner or moderators may; the file is written to
+// the caller's storage.
+func (h *RecordingHandler) StartRecording(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req StartRecordingRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	if req.Type == "" {
+		req.Type = "room"
+	}
+	switch {
+	case req.Type != "room" && req.Type != "track":
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "type must be room or track"})
+	case req.Type == "track" && req.TrackSID == "":
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "trackSid is required for track recordings"})
+	case req.AudioOnly && req.VideoOnly:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "audioOnly and videoOnly are exclusive"})
+	}
+
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	userID, _ := c.Get("userId").(string)
+	key := recordingKey(userID, roomName, time.Now())
+	var info *lkproto.EgressInfo
+	var err error
+	if req.Type == "track" {
+		// No extension: LiveKit adds the one matching the track's codec
+		info, err = h.client.StartTrackRecording(c.Request().Context(), roomName, req.TrackSID, key+"-"+req.TrackSID)
+	} else {
+		ext := ".mp4"
+		if req.AudioOnly {
+			ext = ".ogg"
+		}
+		info, err = h.client.StartRoomRecording(c.Request().Context(), roomName, key+ext, livekit.RecordingOptions{
+			Layout:    req.Layout,
+			AudioOnly: req.AudioOnly,
+			VideoOnly: req.VideoOnly,
+		})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("start recording failed", "room", roomName, "type", req.Type, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("recording started", "room", roomName, "type", req.Type, "egress_id", info.EgressId)
+	return c.JSON(http.StatusCreated, RecordingResponse{Recording: events.RecordingFromEgress(info)})
+}
+
+// ListRoomRecordings lists the recordings running in a room. Only the
+// room's owner or moderators may see them.
+func (h *RecordingHandler) ListRoomRecordings(c echo.Context) error {
+	roomName := c.Param("room")
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	active, err := h.client.ListActiveEgress(c.Request().Context(), roomName)
+	if err != nil {
+		logging.For(c, h.logger).Error("list recordings failed", "room", roomName, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	recordings := []RecordingResponse{}
+	for _, info := range active {
+		if recording := events.RecordingFromEgress(info); recording != nil {
+			recordings = append(recordings, RecordingResponse{Recording: recording})
+		}
+	}
+	return c.JSON(http.StatusOK, recordings)
+}
+
+// StopRecording stops a recording. The user it is recorded for may stop
+// it, as may the owner or moderators of its room while the room is open.
+func (h *RecordingHandler) StopRecording(c echo.Context) error {
+	egressID := c.Param("egress")
+	userID, _ := c.Get("userId").(string)
+
+	info, err := h.client.GetEgress(c.Request().Context(), egressID)
+	if errors.Is(err, livekit.ErrEgressNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "recording not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("recording lookup failed", "egress_id", egressID, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	recording := events.RecordingFromEgress(info)
+	if recording == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "recording not found"})
+	}
+	if userID == "" || !strings.HasPrefix(recording.Key, storage.UserPrefix(userID)) {
+		if _, _, ok, err := authorizeRoom(c, h.client, h.logger, recording.Room, false); !ok {
+			return err
+		}
+	}
+
+	info, err = h.client.StopEgress(c.Request().Context(), egressID)
+	switch {
+	case errors.Is(err, livekit.ErrEgressNotFound):
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "recording not found"})
+	case errors.Is(err, livekit.ErrEgressEnded):
+		return c.JSON(http.StatusConflict, ErrorResponse{Error: "recording already ended"})
+	case err != nil:
+		logging.For(c, h.logger).Error("stop recording failed", "egress_id", egressID, "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	logging.For(c, h.logger).Info("recording stopped", "room", recording.Room, "egress_id", egressID)
+	if stopped := events.RecordingFromEgress(info); stopped != nil {
+		recording = stopped
+	}
+	return c.JSON(http.StatusOK, RecordingResponse{Recording: recording})
+}
+
+// ListRecordings lists the authenticated user's recordings, newest first:
+// those reported by egress webhooks, and the files in the user's
+// recordings folder that no kept webhook reports. Completed recordings
+// carry a presigned playback URL.
+func (h *RecordingHandler) ListRecordings(c echo.Context) error {
+	userID, _ := c.Get("userId").(string)
+	if userID == "" {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "authentication required"})
+	}
+	ctx := c.Request().Context()
+	logger := logging.For(c, h.logger)
+
+	prefix := storage.UserPrefix(userID) + "recordings/"
+	reported := h.store.Recordings(prefix)
+	files, err := h.r2.ListFiles(ctx, prefix)
+	if err != nil {
+		logger.Error("list recording files failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	all := append(reported, unreportedRecordings(reported, files)...)
+	// Recordings still starting have no start time yet
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].StartedAt == nil || all[j].StartedAt == nil {
+			return all[i].StartedAt == nil && all[j].StartedAt != nil
+		}
+		return all[i].StartedAt.After(*all[j].StartedAt)
+	})
+
+	recordings := []RecordingResponse{}
+	for _, recording := range all {
+		res := RecordingResponse{Recording: recording}
+		if recording.Status == "complete" {
+			url, err := h.r2.GetPresignedURL(ctx, recording.Key, playbackURLTTL)
+			if err != nil {
+				logger.Error("presign recording failed", "key", recording.Key, "error", err)
+				return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			}
+			res.URL = url
+		}
+		recordings = append(recordings, res)
+	}
+	return c.JSON(http.StatusOK, recordings)
+}
+
+// unreportedRecordings describes the files, listed relative to a user's
+// recordings folder, that none of the reported recordings wrote. Such
+// files were recorded before the event retention or while webhooks went
+// elsewhere, so they are complete; their room is the folder name, as
+// sanitized by recordingKey, and their start time the file name.
+func unreportedRecordings(reported []*events.Recording, files []storage.StorageEntry) []*events.Recording {
+	known := make(map[string]bool, len(reported))
+	for _, r := range reported {
+		known[r.Key] = true
+	}
+
+	var out []*events.Recording
+	for _, f := range files {
+		// Running recordings are reported under their key without the
+		// extension the file gets
+		if known[f.Key] || known[strings.TrimSuffix(f.Key, path.Ext(f.Key))] {
+			continue
+		}
+		room, name, ok := strings.Cut(f.Name, "/")
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		r := &events.Recording{Room: room, Status: "complete", Key: f.Key}
+		if f.Size != nil {
+			r.Size = *f.Size
+		}
+		if t, err := time.Parse(recordingTimeFormat, strings.TrimSuffix(name, path.Ext(name))); err == nil {
+			r.StartedAt = &t
+		}
+		if f.UpdatedAt != nil {
+			if t, err := time.Parse(time.RFC3339, *f.UpdatedAt); err == nil {
+				r.EndedAt = &t
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// recordingKey returns the key a recording of room started at t is
+// written to, without an extension
+func recordingKey(userID, room string, t time.Time) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, room)
+	return storage.UserPrefix(userID) + "recordings/" + safe + "/" + t.UTC().Format(recordingTimeFormat)
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/internal/config"
+	"myapp/internal/events"
+	"myapp/internal/livekit"
+	"myapp/internal/storage"
+
+	"github.com/labstack/echo/v4"
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+var testR2 = config.Config{
+	R2AccessKeyID:     "r2-key",
+	R2SecretAccessKey: "r2-secret",
+	R2Bucket:          "media",
+	R2Endpoint:        "https://account.r2.example",
+}
+
+func newTestRecordingHandler(t *testing.T) (*RecordingHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}})
+	cfg := testR2
+	cfg.LivekitHost = fake.URL
+	cfg.LivekitAPIKey = "key"
+	cfg.LivekitSecret = "secretsecretsecretsecretsecretsecret"
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewRecordingHandler(livekit.NewClient(&cfg, fake.acls), nil, nil, logger), fake
+}
+
+// call runs h for userID with the given path parameters and JSON body,
+// and decodes the response into out when it is not nil
+func call(t *testing.T, h echo.HandlerFunc, userID string, params map[string]string, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	var names, values []string
+	for name, value := range params {
+		names = append(names, name)
+		values = append(values, value)
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	c.Set("userId", userID)
+	if err := h(c); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	if out != nil && rec.Code < 300 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("decode %s: %v", rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestStartRecordingWritesToCallerStorage(t *testing.T) {
+	h, fake := newTestRecordingHandler(t)
+	room := map[string]string{"room": "standup"}
+	prefix := storage.UserPrefix("mod") + "recordings/standup/"
+
+	var res RecordingResponse
+	if code := call(t, h.StartRecording, "mod", room, `{"layout":"speaker"}`, &res); code != http.StatusCreated {
+		t.Fatalf("start room recording = %d, want 201", code)
+	}
+	req, ok := fake.lastStart(t).(*lkproto.RoomCompositeEgressRequest)
+	if !ok || len(req.FileOutputs) != 1 {
+		t.Fatalf("start request = %v, want one room composite file output", req)
+	}
+	output := req.FileOutputs[0]
+	if !strings.HasPrefix(output.Filepath, prefix) || !strings.HasSuffix(output.Filepath, ".mp4") {
+		t.Errorf("file path = %q, want an .mp4 under %s", output.Filepath, prefix)
+	}
+	if output.FileType != lkproto.EncodedFileType_MP4 || req.Layout != "speaker" {
+		t.Errorf("file type = %v, layout = %q, want MP4 and speaker", output.FileType, req.Layout)
+	}
+	upload := output.GetS3()
+	if upload == nil || upload.Bucket != testR2.R2Bucket || upload.Endpoint != testR2.R2Endpoint ||
+		upload.AccessKey != testR2.R2AccessKeyID || upload.Secret != testR2.R2SecretAccessKey || !upload.ForcePathStyle {
+		t.Errorf("upload = %v, want the R2 bucket and credentials", upload)
+	}
+	if res.EgressID == "" || res.Key != output.Filepath || res.Status != "starting" || res.Kind != "room" {
+		t.Errorf("response = %+v, want the starting room recording at %s", res.Recording, output.Filepath)
+	}
+
+	// Audio only recordings are OGG
+	if code := call(t, h.StartRecording, "owner", room, `{"audioOnly":true}`, nil); code != http.StatusCreated {
+		t.Fatalf("start audio recording = %d, want 201", code)
+	}
+	output = fake.lastStart(t).(*lkproto.RoomCompositeEgressRequest).FileOutputs[0]
+	if !strings.HasPrefix(output.Filepath, storage.UserPrefix("owner")+"recordings/standup/") || !strings.HasSuffix(output.Filepath, ".ogg") {
+		t.Errorf("audio file path = %q, want an .ogg in the owner's recordings", output.Filepath)
+	}
+
+	// Track recordings get no extension, so LiveKit picks the codec's
+	if code := call(t, h.StartRecording, "mod", room, `{"type":"track","trackSid":"TR_1"}`, nil); code != http.StatusCreated {
+		t.Fatalf("start track recording = %d, want 201", code)
+	}
+	track, ok := fake.lastStart(t).(*lkproto.TrackEgressRequest)
+	if !ok || track.TrackId != "TR_1" {
+		t.Fatalf("start request = %v, want a track egress for TR_1", track)
+	}
+	file := track.GetFile()
+	if !strings.HasPrefix(file.GetFilepath(), prefix) || !strings.HasSuffix(file.GetFilepath(), "-TR_1") {
+		t.Errorf("track file path = %q, want one ending in -TR_1 under %s", file.GetFilepath(), prefix)
+	}
+	if file.GetS3().GetBucket() != testR2.R2Bucket {
+		t.Errorf("track upload bucket = %q, want %q", file.GetS3().GetBucket(), testR2.R2Bucket)
+	}
+}
+
+func TestStartRecordingRequiresHost(t *testing.T) {
+	h, fake := newTestRecordingHandler(t)
+
+	tests := []struct {
+		name string
+		user string
+		room string
+		body string
+		want int
+	}{
+		{"stranger", "stranger", "standup", `{}`, http.StatusForbidden},
+		{"missing room", "mod", "nowhere", `{}`, http.StatusNotFound},
+		{"unknown type", "mod", "standup", `{"type":"screen"}`, http.StatusBadRequest},
+		{"track without sid", "mod", "standup", `{"type":"track"}`, http.StatusBadRequest},
+		{"audio and video only", "mod", "standup", `{"audioOnly":true,"videoOnly":true}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, h.StartRecording, tt.user, map[string]string{"room": tt.room}, tt.body, nil); code != tt.want {
+				t.Errorf("start = %d, want %d", code, tt.want)
+			}
+		})
+	}
+	if len(fake.started) != 0 {
+		t.Errorf("%d egress started, want none", len(fake.started))
+	}
+}
+
+func TestListRoomRecordings(t *testing.T) {
+	h, fake := newTestRecordingHandler(t)
+	fake.addRoom(t, "other", &livekit.RoomACL{Owner: "mod"})
+	room := map[string]string{"room": "standup"}
+
+	var first, second RecordingResponse
+	call(t, h.StartRecording, "mod", room, `{}`, &first)
+	call(t, h.StartRecording, "owner", room, `{}`, &second)
+	call(t, h.StartRecording, "mod", map[string]string{"room": "other"}, `{}`, nil)
+	fake.finish(first.EgressID)
+
+	var listed []RecordingResponse
+	if code := call(t, h.ListRoomRecordings, "owner", room, "", &listed); code != http.StatusOK {
+		t.Fatalf("list = %d, want 200", code)
+	}
+	if len(listed) != 1 || listed[0].EgressID != second.EgressID {
+		t.Errorf("listed %+v, want only the running recording %s", listed, second.EgressID)
+	}
+
+	if code := call(t, h.ListRoomRecordings, "stranger", room, "", nil); code != http.StatusForbidden {
+		t.Errorf("list by stranger = %d, want 403", code)
+	}
+}
+
+func TestStopRecording(t *testing.T) {
+	h, fake := newTestRecordingHandler(t)
+	room := map[string]string{"room": "standup"}
+
+	var byMod, byOwner RecordingResponse
+	call(t, h.StartRecording, "mod", room, `{}`, &byMod)
+	call(t, h.StartRecording, "owner", room, `{}`, &byOwner)
+
+	// Someone else's recording in a room they don't host
+	if code := call(t, h.StopRecording, "stranger", map[string]string{"egress": byMod.EgressID}, "", nil); code != http.StatusForbidden {
+		t.Errorf("stop by stranger = %d, want 403", code)
+	}
+
+	// The user it is recorded for may stop it even without hosting the
+	// room any more
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner"})
+	var stopped RecordingResponse
+	if code := call(t, h.StopRecording, "mod", map[string]string{"egress": byMod.EgressID}, "", &stopped); code != http.StatusOK {
+		t.Fatalf("stop own recording = %d, want 200", code)
+	}
+	if stopped.Status != "ending" {
+		t.Errorf("stopped status = %q, want ending", stopped.Status)
+	}
+	if code := call(t, h.StopRecording, "mod", map[string]string{"egress": byOwner.EgressID}, "", nil); code != http.StatusForbidden {
+		t.Errorf("stop by former moderator = %d, want 403", code)
+	}
+
+	// Room hosts may stop any recording of the room
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}})
+	if code := call(t, h.StopRecording, "mod", map[string]string{"egress": byOwner.EgressID}, "", nil); code != http.StatusOK {
+		t.Errorf("stop by moderator = %d, want 200", code)
+	}
+
+	fake.finish(byMod.EgressID)
+	if code := call(t, h.StopRecording, "mod", map[string]string{"egress": byMod.EgressID}, "", nil); code != http.StatusConflict {
+		t.Errorf("stop ended recording = %d, want 409", code)
+	}
+	if code := call(t, h.StopRecording, "mod", map[string]string{"egress": "EG_missing"}, "", nil); code != http.StatusNotFound {
+		t.Errorf("stop unknown recording = %d, want 404", code)
+	}
+}
+
+func TestRecordingKeyStaysInUserFolder(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		room string
+		want string
+	}{
+		{"standup", "users/u1/recordings/standup/20250102T020405Z"},
+		{"../../u2/recordings/x", "users/u1/recordings/.._.._u2_recordings_x/20250102T020405Z"},
+		{"team room ☕", "users/u1/recordings/team_room__/20250102T020405Z"},
+	}
+	for _, tt := range tests {
+		if got := recordingKey("u1", tt.room, at); got != tt.want {
+			t.Errorf("recordingKey(%q) = %q, want %q", tt.room, got, tt.want)
+		}
+	}
+}
+
+func TestUnreportedRecordings(t *testing.T) {
+	prefix := storage.UserPrefix("u1") + "recordings/"
+	size := int64(42)
+	updated := "2025-01-01T11:00:00Z"
+	file := func(name string) storage.StorageEntry {
+		return storage.StorageEntry{Key: prefix + name, Name: name, Size: &size, UpdatedAt: &updated}
+	}
+	reported := []*events.Recording{
+		{Key: prefix + "standup/20250101T100000Z.mp4"},
+		// Still running: reported without the extension its file gets
+		{Key: prefix + "standup/20250101T103000Z"},
+	}
+
+	got := unreportedRecordings(reported, []storage.StorageEntry{
+		file("standup/20250101T100000Z.mp4"),
+		file("standup/20250101T103000Z.mp4"),
+		file("old_room/20240101T100000Z.ogg"),
+		file("stray.txt"),
+		file("a/b/c.mp4"),
+	})
+	if len(got) != 1 {
+		t.Fatalf("got %d recordings, want only old_room's", len(got))
+	}
+	r := got[0]
+	if r.Room != "old_room" || r.Status != "complete" || r.Key != prefix+"old_room/20240101T100000Z.ogg" || r.Size != size || r.EgressID != "" {
+		t.Errorf("recording = %+v", r)
+	}
+	if r.StartedAt == nil || !r.StartedAt.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("startedAt = %v, want the time in the file name", r.StartedAt)
+	}
+	if r.EndedAt == nil || r.EndedAt.Format(time.RFC3339) != updated {
+		t.Errorf("endedAt = %v, want the file's last modification", r.EndedAt)
+	}
+}
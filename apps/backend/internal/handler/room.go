@@ -2,23 +2,40 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"myapp/internal/livekit"
+	"myapp/internal/logging"
 
 	"github.com/labstack/echo/v4"
 	lkproto "github.com/livekit/protocol/livekit"
 )
 
+// Limits on room metadata and data messages. Reliable messages are kept
+// within one SCTP message and lossy ones within one packet.
+const (
+	maxRoomMetadataSize = 64000
+	maxReliableDataSize = 15 * 1024
+	maxLossyDataSize    = 1300
+	maxDataDestinations = 100
+	maxDataTopicSize    = 256
+)
+
 type RoomHandler struct {
 	client *livekit.Client
+	logger *slog.Logger
 }
 
-func NewRoomHandler(client *livekit.Client) *RoomHandler {
-	return &RoomHandler{client: client}
+func NewRoomHandler(client *livekit.Client, logger *slog.Logger) *RoomHandler {
+	return &RoomHandler{client: client, logger: logger}
 }
 
-// CreateRoom creates a new LiveKit room
+// CreateRoom creates a new LiveKit room owned by the authenticated user
 func (h *RoomHandler) CreateRoom(c echo.Context) error {
 	var req CreateRoomRequest
 	if err := c.Bind(&req); err != nil {
@@ -29,12 +46,41 @@ func (h *RoomHandler) CreateRoom(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room name is required"})
 	}
 
-	room, err := h.client.RoomService().CreateRoom(context.Background(), &lkproto.CreateRoomRequest{
+	// LiveKit returns an existing room from CreateRoom rather than failing,
+	// so rooms are looked up first: whoever created one, it is never
+	// handed to another user
+	_, err := h.client.GetRoom(c.Request().Context(), req.Name)
+	if err == nil {
+		return c.JSON(http.StatusConflict, ErrorResponse{Error: "room already exists"})
+	}
+	if !errors.Is(err, livekit.ErrRoomNotFound) {
+		logging.For(c, h.logger).Error("room lookup failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	room, err := h.client.RoomService().CreateRoom(c.Request().Context(), &lkproto.CreateRoomRequest{
 		Name:            req.Name,
 		EmptyTimeout:    req.EmptyTimeout,
 		MaxParticipants: req.MaxParticipants,
 	})
 	if err != nil {
+		logging.For(c, h.logger).Error("create room failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	// Of concurrent requests for the same new room, only the first to
+	// write its ACL gets it
+	userID, _ := c.Get("userId").(string)
+	err = h.client.CreateRoomACL(c.Request().Context(), room, &livekit.RoomACL{
+		Owner:      userID,
+		Moderators: normalizeModerators(req.Moderators, userID),
+		Lobby:      req.Lobby,
+	})
+	if errors.Is(err, livekit.ErrACLExists) {
+		return c.JSON(http.StatusConflict, ErrorResponse{Error: "room already exists"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("create room failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
 
@@ -45,25 +91,232 @@ func (h *RoomHandler) CreateRoom(c echo.Context) error {
 func (h *RoomHandler) ListRooms(c echo.Context) error {
 	res, err := h.client.RoomService().ListRooms(context.Background(), &lkproto.ListRoomsRequest{})
 	if err != nil {
+		logging.For(c, h.logger).Error("list rooms failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
-
 	return c.JSON(http.StatusOK, res.Rooms)
 }
 
-// DeleteRoom deletes a LiveKit room
+// DeleteRoom deletes a LiveKit room. Only its owner or moderators may.
 func (h *RoomHandler) DeleteRoom(c echo.Context) error {
 	roomName := c.Param("room")
 	if roomName == "" {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room name is required"})
 	}
 
-	_, err := h.client.RoomService().DeleteRoom(context.Background(), &lkproto.DeleteRoomRequest{
+	room, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false)
+	if !ok {
+		return err
+	}
+
+	_, err = h.client.RoomService().DeleteRoom(c.Request().Context(), &lkproto.DeleteRoomRequest{
 		Room: roomName,
 	})
 	if err != nil {
+		logging.For(c, h.logger).Error("delete room failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
+	// Also dropped on room_finished; this covers a missed webhook
+	if err := h.client.ForgetRoomACL(c.Request().Context(), room.Sid); err != nil {
+		logging.For(c, h.logger).Warn("forget room ACL failed", "room", roomName, "error", err)
+	}
 
 	return c.JSON(http.StatusOK, StatusResponse{Status: "deleted"})
 }
+
+// UpdateRoomMetadata replaces a room's metadata. Only its owner or
+// moderators may.
+func (h *RoomHandler) UpdateRoomMetadata(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req UpdateRoomMetadataRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+	if req.Metadata == nil {
+		req.Metadata = make(map[string]json.RawMessage)
+	}
+	metadata, err := json.Marshal(req.Metadata)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid metadata"})
+	}
+	if len(metadata) > maxRoomMetadataSize {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("metadata must be at most %d bytes", maxRoomMetadataSize)})
+	}
+
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	_, err = h.client.RoomService().UpdateRoomMetadata(c.Request().Context(), &lkproto.UpdateRoomMetadataRequest{
+		Room:     roomName,
+		Metadata: string(metadata),
+	})
+	if err != nil {
+		logging.For(c, h.logger).Error("update room metadata failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, RoomMetadataResponse{Room: roomName, Metadata: req.Metadata})
+}
+
+// SendData sends a data message to everyone in a room, or only to the
+// given identities. Only its owner or moderators may.
+func (h *RoomHandler) SendData(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req SendDataRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+
+	if req.Data == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "data is required"})
+	}
+	limit, kind := maxReliableDataSize, lkproto.DataPacket_RELIABLE
+	if req.Lossy {
+		limit, kind = maxLossyDataSize, lkproto.DataPacket_LOSSY
+	}
+	if len(req.Data) > limit {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("data must be at most %d bytes", limit)})
+	}
+	if len(req.Topic) > maxDataTopicSize {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("topic must be at most %d bytes", maxDataTopicSize)})
+	}
+	if len(req.DestinationIdentities) > maxDataDestinations {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("at most %d destination identities are allowed", maxDataDestinations)})
+	}
+	for _, identity := range req.DestinationIdentities {
+		if identity == "" {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "destination identities must not be empty"})
+		}
+	}
+
+	if _, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, false); !ok {
+		return err
+	}
+
+	send := &lkproto.SendDataRequest{
+		Room:                  roomName,
+		Data:                  []byte(req.Data),
+		Kind:                  kind,
+		DestinationIdentities: req.DestinationIdentities,
+	}
+	if req.Topic != "" {
+		send.Topic = &req.Topic
+	}
+	if _, err := h.client.RoomService().SendData(c.Request().Context(), send); err != nil {
+		logging.For(c, h.logger).Error("send data failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, StatusResponse{Status: "sent"})
+}
+
+// SetModerators replaces a room's moderators. Only its owner may.
+func (h *RoomHandler) SetModerators(c echo.Context) error {
+	roomName := c.Param("room")
+
+	var req SetModeratorsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+	}
+
+	room, _, ok, err := authorizeRoom(c, h.client, h.logger, roomName, true)
+	if !ok {
+		return err
+	}
+
+	acl, err := h.client.UpdateRoomACL(c.Request().Context(), room, func(acl *livekit.RoomACL) error {
+		acl.Moderators = normalizeModerators(req.Moderators, acl.Owner)
+		return nil
+	})
+	if errors.Is(err, livekit.ErrRoomNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("set moderators failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, acl)
+}
+
+// authorizeRoom loads room and its ACL and checks that the authenticated
+// user owns it or, unless ownerOnly, moderates it. When the check fails it
+// writes the response and returns false along with the result of writing
+// it.
+func authorizeRoom(c echo.Context, client *livekit.Client, logger *slog.Logger, roomName string, ownerOnly bool) (*lkproto.Room, *livekit.RoomACL, bool, error) {
+	room, acl, err := client.RoomACL(c.Request().Context(), roomName)
+	if errors.Is(err, livekit.ErrRoomNotFound) {
+		return nil, nil, false, c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	}
+	if err != nil {
+		logging.For(c, logger).Error("room lookup failed", "error", err)
+		return nil, nil, false, c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	userID, _ := c.Get("userId").(string)
+	allowed := acl.CanModerate(userID)
+	if ownerOnly {
+		allowed = userID != "" && acl.Owner == userID
+	}
+	if !allowed {
+		logging.For(c, logger).Info("room action denied", "room", roomName)
+		return nil, nil, false, c.JSON(http.StatusForbidden, ErrorResponse{Error: "not allowed to manage this room"})
+	}
+	return room, acl, true, nil
+}
+
+// authorizeRoomMember is authorizeRoom for reads that are also open to
+// the users currently connected to the room
+func authorizeRoomMember(c echo.Context, client *livekit.Client, logger *slog.Logger, roomName string) (*lkproto.Room, bool, error) {
+	room, acl, err := client.RoomACL(c.Request().Context(), roomName)
+	if errors.Is(err, livekit.ErrRoomNotFound) {
+		return nil, false, c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	}
+	if err != nil {
+		logging.For(c, logger).Error("room lookup failed", "error", err)
+		return nil, false, c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+
+	userID, _ := c.Get("userId").(string)
+	if acl.CanModerate(userID) {
+		return room, true, nil
+	}
+	if userID != "" {
+		_, err := client.GetParticipant(c.Request().Context(), roomName, userID)
+		if err == nil {
+			return room, true, nil
+		}
+		if !errors.Is(err, livekit.ErrParticipantNotFound) {
+			logging.For(c, logger).Error("participant lookup failed", "error", err)
+			return nil, false, c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		}
+	}
+	logging.For(c, logger).Info("room read denied", "room", roomName)
+	return nil, false, c.JSON(http.StatusForbidden, ErrorResponse{Error: "not a participant of this room"})
+}
+
+// protectOwner writes a 403 and returns false when identity is the room's
+// owner and the authenticated user is not: moderators cannot act on them
+func protectOwner(c echo.Context, acl *livekit.RoomACL, identity string) (bool, error) {
+	userID, _ := c.Get("userId").(string)
+	if owner := acl.Owner; owner != "" && identity == owner && userID != owner {
+		return false, c.JSON(http.StatusForbidden, ErrorResponse{Error: "only the owner can act on the room owner"})
+	}
+	return true, nil
+}
+
+// normalizeModerators drops blanks, duplicates and the owner
+func normalizeModerators(moderators []string, owner string) []string {
+	seen := map[string]bool{owner: true}
+	out := make([]string, 0, len(moderators))
+	for _, moderator := range moderators {
+		if moderator = strings.TrimSpace(moderator); moderator != "" && !seen[moderator] {
+			seen[moderator] = true
+			out = append(out, moderator)
+		}
+	}
+	return out
+}
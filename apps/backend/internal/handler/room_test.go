@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"myapp/internal/livekit"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+func newTestRoomHandler(t *testing.T) (*RoomHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	return NewRoomHandler(fake.client(), testLogger), fake
+}
+
+// roomACL returns the stored ACL of the named room in fake
+func roomACL(t *testing.T, fake *fakeLiveKit, name string) *livekit.RoomACL {
+	t.Helper()
+	fake.mu.Lock()
+	room, ok := fake.rooms[name]
+	fake.mu.Unlock()
+	if !ok {
+		t.Fatalf("room %s does not exist", name)
+	}
+	acl, err := fake.acls.Get(context.Background(), room.Sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return acl
+}
+
+func TestCreateRoomCannotTakeOverExistingRoom(t *testing.T) {
+	h, fake := newTestRoomHandler(t)
+
+	var room lkproto.Room
+	if code := call(t, h.CreateRoom, "alice", nil, `{"name":"standup","moderators":["bob"]}`, &room); code != http.StatusOK {
+		t.Fatalf("create = %d, want 200", code)
+	}
+	want := &livekit.RoomACL{Owner: "alice", Moderators: []string{"bob"}}
+	if got := roomACL(t, fake, "standup"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ACL = %+v, want %+v", got, want)
+	}
+
+	for _, user := range []string{"mallory", "alice"} {
+		body := `{"name":"standup","moderators":["mallory"],"lobby":true}`
+		if code := call(t, h.CreateRoom, user, nil, body, nil); code != http.StatusConflict {
+			t.Errorf("create existing room as %s = %d, want 409", user, code)
+		}
+	}
+	if got := roomACL(t, fake, "standup"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ACL after takeover attempts = %+v, want %+v", got, want)
+	}
+
+	// Rooms created outside the API stay without an owner
+	fake.addRoom(t, "lobby", nil)
+	if code := call(t, h.CreateRoom, "mallory", nil, `{"name":"lobby"}`, nil); code != http.StatusConflict {
+		t.Errorf("create unowned room = %d, want 409", code)
+	}
+	if got := roomACL(t, fake, "lobby"); got != nil {
+		t.Errorf("unowned room ACL = %+v, want none", got)
+	}
+}
+
+func TestSetModeratorsIsOwnerOnly(t *testing.T) {
+	h, fake := newTestRoomHandler(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}, Banned: []string{"mallory"}})
+	room := map[string]string{"room": "standup"}
+
+	for _, user := range []string{"mod", "guest", ""} {
+		if code := call(t, h.SetModerators, user, room, `{"moderators":["`+user+`","mallory"]}`, nil); code != http.StatusForbidden {
+			t.Errorf("set moderators as %q = %d, want 403", user, code)
+		}
+	}
+
+	body := `{"moderators":[" carol ","owner","","carol","dave"]}`
+	if code := call(t, h.SetModerators, "owner", room, body, nil); code != http.StatusOK {
+		t.Fatalf("set moderators as owner = %d, want 200", code)
+	}
+	want := &livekit.RoomACL{Owner: "owner", Moderators: []string{"carol", "dave"}, Banned: []string{"mallory"}}
+	if got := roomACL(t, fake, "standup"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ACL = %+v, want %+v", got, want)
+	}
+
+	// The change takes effect at once
+	if code := call(t, h.UpdateRoomMetadata, "mod", room, `{"metadata":{}}`, nil); code != http.StatusForbidden {
+		t.Errorf("update metadata as former moderator = %d, want 403", code)
+	}
+	if code := call(t, h.UpdateRoomMetadata, "carol", room, `{"metadata":{}}`, nil); code != http.StatusOK {
+		t.Errorf("update metadata as new moderator = %d, want 200", code)
+	}
+}
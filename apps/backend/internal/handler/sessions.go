@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"myapp/internal/logging"
+	"myapp/internal/session"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SessionHandler exposes session revocation to internal services
+type SessionHandler struct {
+	sessions *session.Cache
+	logger   *slog.Logger
+}
+
+func NewSessionHandler(sessions *session.Cache, logger *slog.Logger) *SessionHandler {
+	return &SessionHandler{sessions: sessions, logger: logger}
+}
+
+// RevokeSessionRequest names the user, and optionally the single session,
+// to revoke
+type RevokeSessionRequest struct {
+	UserID    string `json:"userId"`
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+// Revoke handles POST /internal/sessions/revoke. Only the services listed
+// in SESSION_REVOKERS reach it, and they may revoke any user: revoking is
+// not acting as the user, so it does not take an assertion for them.
+func (h *SessionHandler) Revoke(c echo.Context) error {
+	var req RevokeSessionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+	}
+	if req.UserID == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "userId is required"})
+	}
+
+	ctx := c.Request().Context()
+	var err error
+	if req.SessionID != "" {
+		err = h.sessions.RevokeSession(ctx, req.UserID, req.SessionID)
+	} else {
+		err = h.sessions.RevokeUser(ctx, req.UserID)
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("session revocation failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "failed to revoke session"})
+	}
+
+	logging.For(c, h.logger).Info("session revoked", "revoked_user_id", req.UserID, "session_id", req.SessionID)
+	return c.JSON(http.StatusOK, StatusResponse{Status: "revoked"})
+}
@@ -3,32 +3,44 @@ package handler
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"myapp/internal/logging"
+	"myapp/internal/metrics"
+	"myapp/internal/tracing"
 	"myapp/internal/storage"
 
 	"github.com/labstack/echo/v4"
 )
 
 type StorageHandler struct {
-	r2 *storage.R2Client
+	r2     *storage.R2Client
+	logger *slog.Logger
 }
 
-func NewStorageHandler(r2 *storage.R2Client) *StorageHandler {
-	return &StorageHandler{r2: r2}
+func presignedGet(*http.Request) string { return "PresignedGetObject" }
+
+// proxyClient fetches objects through presigned URLs
+var proxyClient = &http.Client{
+	Transport: tracing.Transport("r2", presignedGet, metrics.Transport("r2", presignedGet, nil)),
+}
+
+func NewStorageHandler(r2 *storage.R2Client, logger *slog.Logger) *StorageHandler {
+	return &StorageHandler{r2: r2, logger: logger}
 }
 
 // getUserPrefix returns the user-scoped prefix for storage isolation
 // Format: users/{userId}/
 func getUserPrefix(c echo.Context) string {
-	userId := c.Get("userId")
-	if userId == nil || userId == "" {
+	userId, _ := c.Get("userId").(string)
+	if userId == "" {
 		return ""
 	}
-	return fmt.Sprintf("users/%s/", userId)
+	return storage.UserPrefix(userId)
 }
 
 // buildUserScopedKey prepends the user prefix to ensure isolation
@@ -67,6 +79,7 @@ func (h *StorageHandler) ListObjects(c echo.Context) error {
 
 	result, err := h.r2.List(c.Request().Context(), scopedPrefix)
 	if err != nil {
+		logging.For(c, h.logger).Error("list objects failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
@@ -107,6 +120,7 @@ func (h *StorageHandler) UploadObject(c echo.Context) error {
 
 	src, err := file.Open()
 	if err != nil {
+		logging.For(c, h.logger).Error("upload failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to open file",
 		})
@@ -116,11 +130,14 @@ func (h *StorageHandler) UploadObject(c echo.Context) error {
 	contentType := file.Header.Get("Content-Type")
 	entry, err := h.r2.Upload(c.Request().Context(), key, src, contentType)
 	if err != nil {
+		logging.For(c, h.logger).Error("upload failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
+	metrics.UploadedBytes(file.Size)
+
 	// Strip user prefix from response
 	entry.Key = strings.TrimPrefix(entry.Key, userPrefix)
 
@@ -157,6 +174,7 @@ func (h *StorageHandler) DeleteObject(c echo.Context) error {
 
 	err := h.r2.Delete(c.Request().Context(), scopedKey, recursive)
 	if err != nil {
+		logging.For(c, h.logger).Error("delete object failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
@@ -198,6 +216,7 @@ func (h *StorageHandler) CreateFolder(c echo.Context) error {
 
 	entry, err := h.r2.CreateFolder(c.Request().Context(), scopedPrefix, req.Name)
 	if err != nil {
+		logging.For(c, h.logger).Error("create folder failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
@@ -245,6 +264,7 @@ func (h *StorageHandler) GetDownloadURL(c echo.Context) error {
 
 	url, err := h.r2.GetPresignedURL(c.Request().Context(), scopedKey, time.Duration(ttl)*time.Second)
 	if err != nil {
+		logging.For(c, h.logger).Error("presign download failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
@@ -283,13 +303,23 @@ func (h *StorageHandler) ProxyObject(c echo.Context) error {
 
 	url, err := h.r2.GetPresignedURL(c.Request().Context(), scopedKey, 5*time.Minute)
 	if err != nil {
+		logging.For(c, h.logger).Error("proxy object failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	req, err := http.NewRequestWithContext(c.Request().Context(), http.MethodGet, url, nil)
+	if err != nil {
+		logging.For(c, h.logger).Error("proxy object failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
 	}
 
-	resp, err := http.Get(url)
+	resp, err := proxyClient.Do(req)
 	if err != nil {
+		logging.For(c, h.logger).Error("proxy object failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": fmt.Sprintf("failed to fetch object: %v", err),
 		})
@@ -341,6 +371,7 @@ func (h *StorageHandler) RenameObject(c echo.Context) error {
 
 	entry, err := h.r2.Rename(c.Request().Context(), scopedKey, req.NewName)
 	if err != nil {
+		logging.For(c, h.logger).Error("rename object failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
@@ -391,6 +422,7 @@ func (h *StorageHandler) MoveObject(c echo.Context) error {
 
 	entry, err := h.r2.Move(c.Request().Context(), scopedKey, scopedDestPrefix)
 	if err != nil {
+		logging.For(c, h.logger).Error("move object failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
@@ -440,6 +472,7 @@ func (h *StorageHandler) SetVisibility(c echo.Context) error {
 
 	entry, err := h.r2.SetVisibility(c.Request().Context(), scopedKey, req.Visibility)
 	if err != nil {
+		logging.For(c, h.logger).Error("set visibility failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
 		})
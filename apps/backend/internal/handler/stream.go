@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/livekit"
+	"myapp/internal/logging"
+
+	"github.com/labstack/echo/v4"
+	"github.com/livekit/protocol/webhook"
+)
+
+// streamRetry is the reconnection delay suggested to stream clients
+const streamRetry = 3 * time.Second
+
+// maxStreamRooms bounds the rooms one stream follows, each checked against
+// LiveKit when the stream opens
+const maxStreamRooms = 20
+
+// streamedEvents are the webhook event types sent on the live event stream
+var streamedEvents = map[string]bool{
+	webhook.EventRoomStarted:       true,
+	webhook.EventRoomFinished:      true,
+	webhook.EventParticipantJoined: true,
+	webhook.EventParticipantLeft:   true,
+	webhook.EventTrackPublished:    true,
+	webhook.EventTrackUnpublished:  true,
+	webhook.EventIngressStarted:    true,
+	webhook.EventIngressEnded:      true,
+}
+
+type StreamHandler struct {
+	client    *livekit.Client
+	feed      *events.Feed
+	heartbeat time.Duration
+	logger    *slog.Logger
+}
+
+func NewStreamHandler(client *livekit.Client, feed *events.Feed, heartbeat time.Duration, logger *slog.Logger) *StreamHandler {
+	return &StreamHandler{client: client, feed: feed, heartbeat: heartbeat, logger: logger}
+}
+
+// StreamEvents streams room, participant, track and ingress events as
+// Server-Sent Events for the rooms named by repeated ?room= params. Only
+// the owner or moderators of every one of those rooms may stream them, as
+// only they may see a session's history; events naming the room's hosts
+// are also skipped unless the caller is one of them.
+// A client reconnecting with Last-Event-ID (or ?lastEventId=) first gets
+// the events it missed; when they are no longer buffered it gets a "reset"
+// event instead and should reload the rooms it shows.
+func (h *StreamHandler) StreamEvents(c echo.Context) error {
+	userID, _ := c.Get("userId").(string)
+	rooms := map[string]bool{}
+	for _, room := range c.QueryParams()["room"] {
+		if room != "" {
+			rooms[room] = true
+		}
+	}
+	if len(rooms) == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room is required"})
+	}
+	if len(rooms) > maxStreamRooms {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("at most %d rooms may be streamed", maxStreamRooms)})
+	}
+	for room := range rooms {
+		if _, _, ok, err := authorizeRoom(c, h.client, h.logger, room, false); !ok {
+			return err
+		}
+	}
+	lastID := c.Request().Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.QueryParam("lastEventId")
+	}
+
+	sub, replay, complete := h.feed.Subscribe(lastID)
+	defer sub.Close()
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	logger := logging.For(c, h.logger)
+	logger.Debug("event stream opened", "rooms", len(rooms), "replayed", len(replay), "reset", !complete)
+
+	write := func(id, event string, data any) error {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, event, payload); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+	send := func(msg events.Message) error {
+		// Hosts may have changed since the stream opened
+		ev := msg.Event
+		if !rooms[ev.Room] || (len(ev.Hosts) > 0 && !slices.Contains(ev.Hosts, userID)) {
+			return nil
+		}
+		return write(msg.ID, msg.Event.Type, msg.Event)
+	}
+
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return nil
+	}
+	w.Flush()
+	if !complete {
+		if err := write(sub.Position, "reset", StatusResponse{Status: "missed events"}); err != nil {
+			return nil
+		}
+	}
+	for _, msg := range replay {
+		if err := send(msg); err != nil {
+			return nil
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case msg, ok := <-sub.C:
+			// Closed when the server shuts down or the client fell too far
+			// behind; either way the client reconnects and catches up
+			if !ok {
+				logger.Debug("event stream ended by server")
+				return nil
+			}
+			if err := send(msg); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			w.Flush()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/livekit"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestStreamHandler(t *testing.T, feedSize int) (*StreamHandler, *events.Feed) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}})
+	fake.addRoom(t, "retro", &livekit.RoomACL{Owner: "other"})
+	fake.join("standup", "guest")
+	feed := events.NewFeed(feedSize)
+	return NewStreamHandler(fake.client(), feed, time.Minute, testLogger), feed
+}
+
+// stream opens h's event stream for userID and returns what it wrote
+// before the client went away shortly after connecting
+func stream(t *testing.T, h *StreamHandler, userID, query, lastID string) (int, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil).WithContext(ctx)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("userId", userID)
+	if err := h.StreamEvents(c); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	return rec.Code, rec.Body.String()
+}
+
+func TestStreamEventsIsModeratorOnly(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  string
+		query string
+		want  int
+	}{
+		{"owner", "owner", "room=standup", http.StatusOK},
+		{"moderator", "mod", "room=standup", http.StatusOK},
+		{"participant", "guest", "room=standup", http.StatusForbidden},
+		{"stranger", "stranger", "room=standup", http.StatusForbidden},
+		{"unauthenticated", "", "room=standup", http.StatusForbidden},
+		{"one room not moderated", "mod", "room=standup&room=retro", http.StatusForbidden},
+		{"missing room", "mod", "room=standup&room=planning", http.StatusNotFound},
+		{"no room", "mod", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestStreamHandler(t, 8)
+			if code, body := stream(t, h, tt.user, tt.query, ""); code != tt.want {
+				t.Errorf("stream = %d %s, want %d", code, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamEventsReplay(t *testing.T) {
+	h, feed := newTestStreamHandler(t, 4)
+	first := feed.Publish(&events.Event{ID: "EV_1", Type: "room_started", Room: "standup"})
+	feed.Publish(&events.Event{ID: "EV_2", Type: "participant_joined", Room: "standup", Hosts: []string{"owner", "mod"}})
+	feed.Publish(&events.Event{ID: "EV_3", Type: "participant_joined", Room: "retro"})
+	// Published after mod stopped moderating the room
+	last := feed.Publish(&events.Event{ID: "EV_4", Type: "participant_left", Room: "standup", Hosts: []string{"owner"}})
+
+	_, body := stream(t, h, "mod", "room=standup", first.ID)
+	if strings.Contains(body, "event: reset") {
+		t.Fatalf("stream reset for a buffered ID:\n%s", body)
+	}
+	for id, want := range map[string]bool{"EV_1": false, "EV_2": true, "EV_3": false, "EV_4": false} {
+		if got := strings.Contains(body, `"id":"`+id+`"`); got != want {
+			t.Errorf("%s replayed = %v, want %v", id, got, want)
+		}
+	}
+
+	_, body = stream(t, h, "owner", "lastEventId="+last.ID+"&room=standup", "")
+	if strings.Contains(body, "data: {") {
+		t.Errorf("up-to-date client was replayed events:\n%s", body)
+	}
+}
+
+func TestStreamEventsResetsWhenEventsWereMissed(t *testing.T) {
+	h, feed := newTestStreamHandler(t, 2)
+	first := feed.Publish(&events.Event{ID: "EV_1", Type: "room_started", Room: "standup"})
+	for _, id := range []string{"EV_2", "EV_3", "EV_4"} {
+		feed.Publish(&events.Event{ID: id, Type: "participant_joined", Room: "standup"})
+	}
+	restarted := events.NewFeed(2)
+	stale := restarted.Publish(&events.Event{ID: "EV_0"})
+
+	tests := []struct {
+		name   string
+		lastID string
+	}{
+		{"overflowed the buffer", first.ID},
+		{"from before a restart", stale.ID},
+		{"malformed", "garbage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := stream(t, h, "owner", "room=standup", tt.lastID)
+			if code != http.StatusOK || !strings.Contains(body, "event: reset") {
+				t.Fatalf("stream = %d, want a reset:\n%s", code, body)
+			}
+			if strings.Contains(body, `"id":"EV_`) {
+				t.Errorf("events replayed along with the reset:\n%s", body)
+			}
+		})
+	}
+}
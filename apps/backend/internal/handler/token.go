@@ -1,51 +1,181 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"myapp/internal/livekit"
+	"myapp/internal/logging"
 
 	"github.com/labstack/echo/v4"
-	"github.com/livekit/protocol/auth"
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// Limits on the participant metadata and attributes a token may carry
+const (
+	maxTokenMetadataSize   = 4096
+	maxTokenAttributes     = 32
+	maxTokenAttributeSize  = 1024
+	reservedAttributeSpace = "lk."
 )
 
 type TokenHandler struct {
 	client *livekit.Client
+	policy *livekit.TokenPolicy
+	logger *slog.Logger
 }
 
-func NewTokenHandler(client *livekit.Client) *TokenHandler {
-	return &TokenHandler{client: client}
+func NewTokenHandler(client *livekit.Client, policy *livekit.TokenPolicy, logger *slog.Logger) *TokenHandler {
+	return &TokenHandler{client: client, policy: policy, logger: logger}
 }
 
-// GetToken generates a JWT token for room access
+// GetToken generates a JWT token for room access, for the authenticated
+// user's identity. Roles outside the policy's public roles are only
+// granted to the room's owner and moderators, and rooms with a lobby only
+// issue tokens here to them; everyone else goes through the lobby. Banned
+// identities are refused.
 func (h *TokenHandler) GetToken(c echo.Context) error {
 	var req TokenRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
 	}
 
-	if req.Room == "" || req.Identity == "" {
-		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room and identity are required"})
+	if req.Room == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: "room is required"})
+	}
+
+	identity, _ := c.Get("userId").(string)
+	if identity == "" {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "authentication required"})
+	}
+	if req.Identity != "" && req.Identity != identity {
+		logging.For(c, h.logger).Debug("ignoring client-supplied token identity")
 	}
 
-	token, err := h.generateToken(req.Room, req.Identity, req.Name)
+	role, err := livekit.ParseRole(req.Role)
 	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	var ttl time.Duration
+	if req.TTL != 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+		if err := h.policy.CheckTTL(ttl); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	if err := validateParticipantInfo(req.Metadata, req.Attributes); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+
+	// Tokens are only issued for existing rooms: joining a missing room
+	// would have LiveKit create it without an owner, and the name could
+	// then never be claimed through CreateRoom
+	_, acl, err := h.client.RoomACL(c.Request().Context(), req.Room)
+	if errors.Is(err, livekit.ErrRoomNotFound) {
+		return c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("room lookup failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 	}
+	if acl.IsBanned(identity) {
+		logging.For(c, h.logger).Info("token denied, identity is banned", "room", req.Room)
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: "banned from this room"})
+	}
+	if acl.Lobby && !acl.CanModerate(identity) {
+		logging.For(c, h.logger).Info("token denied, room has a lobby", "room", req.Room)
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: "room requires admission through the lobby"})
+	}
+	if !h.policy.Allows(role, identity, acl) {
+		logging.For(c, h.logger).Info("token role denied", "room", req.Room, "role", role)
+		return c.JSON(http.StatusForbidden, ErrorResponse{Error: fmt.Sprintf("not allowed to join as %s", role)})
+	}
 
-	return c.JSON(http.StatusOK, TokenResponse{Token: token})
+	return h.issue(c, livekit.TokenOptions{
+		Room:       req.Room,
+		Identity:   identity,
+		Name:       req.Name,
+		TTL:        ttl,
+		Role:       role,
+		Metadata:   req.Metadata,
+		Attributes: req.Attributes,
+		// RoomAdmin bypasses the ACL, so other hosts go through the API
+		RoomAdmin: role == livekit.RoleHost && acl.Owner == identity,
+	})
 }
 
-func (h *TokenHandler) generateToken(room, identity, name string) (string, error) {
-	at := auth.NewAccessToken(h.client.APIKey(), h.client.Secret())
-	at.AddGrant(&auth.VideoGrant{
-		RoomJoin: true,
-		Room:     room,
-	}).
-		SetIdentity(identity).
-		SetName(name).
-		SetValidFor(time.Hour)
-
-	return at.ToJWT()
+// checkBan returns the named room, or writes a 403 and returns false when
+// identity is banned from it, or a 404 when it does not exist (see
+// GetToken)
+func (h *TokenHandler) checkBan(c echo.Context, roomName, identity string) (*lkproto.Room, bool, error) {
+	room, acl, err := h.client.RoomACL(c.Request().Context(), roomName)
+	if errors.Is(err, livekit.ErrRoomNotFound) {
+		return nil, false, c.JSON(http.StatusNotFound, ErrorResponse{Error: "room not found"})
+	}
+	if err != nil {
+		logging.For(c, h.logger).Error("room lookup failed", "error", err)
+		return nil, false, c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	if acl.IsBanned(identity) {
+		logging.For(c, h.logger).Info("token denied, identity is banned", "room", roomName)
+		return nil, false, c.JSON(http.StatusForbidden, ErrorResponse{Error: "banned from this room"})
+	}
+	return room, true, nil
+}
+
+// issue mints a token for opts, which callers have already authorized,
+// and writes it as the response
+func (h *TokenHandler) issue(c echo.Context, opts livekit.TokenOptions) error {
+	res, err := h.mint(opts)
+	if err != nil {
+		logging.For(c, h.logger).Error("token generation failed", "error", err)
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// mint mints a token for opts, which callers have already authorized,
+// with the policy's default TTL unless opts has one
+func (h *TokenHandler) mint(opts livekit.TokenOptions) (*TokenResponse, error) {
+	if opts.TTL <= 0 {
+		opts.TTL = h.policy.DefaultTTL()
+	}
+
+	token, err := h.client.MintToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:     token,
+		Room:      opts.Room,
+		Role:      string(opts.Role),
+		ExpiresAt: time.Now().Add(opts.TTL).UTC().Truncate(time.Second),
+	}, nil
+}
+
+// validateParticipantInfo bounds client-supplied participant metadata and
+// attributes. Attributes under "lk." are reserved for LiveKit itself.
+func validateParticipantInfo(metadata string, attributes map[string]string) error {
+	if len(metadata) > maxTokenMetadataSize {
+		return fmt.Errorf("metadata must be at most %d bytes", maxTokenMetadataSize)
+	}
+	if len(attributes) > maxTokenAttributes {
+		return fmt.Errorf("at most %d attributes are allowed", maxTokenAttributes)
+	}
+	for key, value := range attributes {
+		if key == "" || strings.HasPrefix(key, reservedAttributeSpace) {
+			return fmt.Errorf("attribute name %q is not allowed", key)
+		}
+		if len(key)+len(value) > maxTokenAttributeSize {
+			return fmt.Errorf("attribute %q must be at most %d bytes", key, maxTokenAttributeSize)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"myapp/internal/livekit"
+
+	"github.com/livekit/protocol/auth"
+)
+
+func newTestTokenHandler(t *testing.T) (*TokenHandler, *fakeLiveKit) {
+	fake := newFakeLiveKit(t)
+	fake.addRoom(t, "standup", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}, Banned: []string{"banned"}})
+	fake.addRoom(t, "interview", &livekit.RoomACL{Owner: "owner", Moderators: []string{"mod"}, Lobby: true})
+	policy := &livekit.TokenPolicy{MaxTTL: time.Hour, PublicRoles: map[livekit.Role]bool{livekit.RoleSpeaker: true, livekit.RoleViewer: true}}
+	return NewTokenHandler(fake.client(), policy, testLogger), fake
+}
+
+func TestGetTokenAuthorization(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		room      string
+		role      string
+		want      int
+		roomAdmin bool
+	}{
+		{"guest with a public role", "guest", "standup", "speaker", http.StatusOK, false},
+		{"guest as host", "guest", "standup", "host", http.StatusForbidden, false},
+		{"guest as hidden observer", "guest", "standup", "observer", http.StatusForbidden, false},
+		{"moderator as host", "mod", "standup", "host", http.StatusOK, false},
+		{"owner as host", "owner", "standup", "host", http.StatusOK, true},
+		{"owner as speaker", "owner", "standup", "speaker", http.StatusOK, false},
+		{"banned identity", "banned", "standup", "viewer", http.StatusForbidden, false},
+		{"guest in a lobby room", "guest", "interview", "viewer", http.StatusForbidden, false},
+		{"moderator in a lobby room", "mod", "interview", "speaker", http.StatusOK, false},
+		{"missing room", "owner", "retro", "host", http.StatusNotFound, false},
+		{"unauthenticated", "", "standup", "viewer", http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestTokenHandler(t)
+			var res TokenResponse
+			body := `{"room":"` + tt.room + `","role":"` + tt.role + `","identity":"someone-else"}`
+			if code := call(t, h.GetToken, tt.user, nil, body, &res); code != tt.want {
+				t.Fatalf("token = %d, want %d", code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			verifier, err := auth.ParseAPIToken(res.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			grants, err := verifier.Verify("secretsecretsecretsecretsecretsecret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if grants.Identity != tt.user {
+				t.Errorf("identity = %q, want the caller %q", grants.Identity, tt.user)
+			}
+			if grants.Video.Room != tt.room || grants.Video.RoomAdmin != tt.roomAdmin {
+				t.Errorf("grant for %s with roomAdmin %v, want %s with %v", grants.Video.Room, grants.Video.RoomAdmin, tt.room, tt.roomAdmin)
+			}
+		})
+	}
+}
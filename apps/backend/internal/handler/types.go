@@ -1,15 +1,37 @@
 package handler
 
-// TokenRequest represents a request for a LiveKit token
+import (
+	"encoding/json"
+	"time"
+
+	"myapp/internal/events"
+	"myapp/internal/invite"
+	"myapp/internal/lobby"
+)
+
+// TokenRequest represents a request for a LiveKit token. The token is
+// always issued to the authenticated user; Identity is ignored and only
+// kept so existing clients still decode.
 type TokenRequest struct {
 	Room     string `json:"room"`
-	Identity string `json:"identity"`
+	Identity string `json:"identity,omitempty"`
 	Name     string `json:"name,omitempty"`
+	// Role is a permission preset: host, speaker, viewer, recorder or
+	// observer (default speaker)
+	Role string `json:"role,omitempty"`
+	// TTL is the token validity in seconds (default one hour)
+	TTL int `json:"ttl,omitempty"`
+	// Metadata and Attributes are shown to the other participants
+	Metadata   string            `json:"metadata,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // TokenResponse represents a token response
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token     string    `json:"token"`
+	Room      string    `json:"room"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 // CreateRoomRequest represents a request to create a room
@@ -17,6 +39,73 @@ type CreateRoomRequest struct {
 	Name            string `json:"name"`
 	EmptyTimeout    uint32 `json:"emptyTimeout,omitempty"`
 	MaxParticipants uint32 `json:"maxParticipants,omitempty"`
+	// Moderators may manage the room alongside its owner, the creator
+	Moderators []string `json:"moderators,omitempty"`
+	// Lobby makes other users wait for a host to admit them
+	Lobby bool `json:"lobby,omitempty"`
+}
+
+// UpdateRoomMetadataRequest replaces a room's app metadata. The "acl"
+// field is managed by the API and cannot be set.
+type UpdateRoomMetadataRequest struct {
+	Metadata map[string]json.RawMessage `json:"metadata"`
+}
+
+// RoomMetadataResponse is a room's app metadata, without its ACL
+type RoomMetadataResponse struct {
+	Room     string                     `json:"room"`
+	Metadata map[string]json.RawMessage `json:"metadata"`
+}
+
+// SetModeratorsRequest replaces the moderators of a room
+type SetModeratorsRequest struct {
+	Moderators []string `json:"moderators"`
+}
+
+// CreateInviteRequest represents a request to invite people to a room
+type CreateInviteRequest struct {
+	// Role is granted to whoever redeems the invite (default speaker)
+	Role string `json:"role,omitempty"`
+	// TTL is the invite lifetime in seconds (default one day)
+	TTL int `json:"ttl,omitempty"`
+	// MaxUses limits redemptions; zero allows any number
+	MaxUses int `json:"maxUses,omitempty"`
+}
+
+// InviteResponse is an invite along with its signed code
+type InviteResponse struct {
+	*invite.Invite
+	Code string `json:"code"`
+	// Link is set when INVITE_LINK_BASE is configured
+	Link string `json:"link,omitempty"`
+}
+
+// RedeemInviteRequest represents a request to exchange an invite code for
+// a LiveKit token
+type RedeemInviteRequest struct {
+	Code       string            `json:"code"`
+	Name       string            `json:"name,omitempty"`
+	Metadata   string            `json:"metadata,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// LobbyRequest represents a request to wait in a room's lobby
+type LobbyRequest struct {
+	// Name is shown to the hosts and used as the display name once admitted
+	Name string `json:"name,omitempty"`
+}
+
+// AdmitRequest represents a host admitting a guest from the lobby
+type AdmitRequest struct {
+	// Role is granted to the guest (default speaker)
+	Role string `json:"role,omitempty"`
+}
+
+// LobbyTicketResponse is a lobby ticket, along with a token once the guest
+// has been admitted
+type LobbyTicketResponse struct {
+	*lobby.Ticket
+	Token *TokenResponse `json:"token,omitempty"`
 }
 
 // MuteTrackRequest represents a request to mute a track
@@ -25,6 +114,122 @@ type MuteTrackRequest struct {
 	Muted    bool   `json:"muted"`
 }
 
+// UpdateParticipantRequest represents a request to change a participant.
+// Empty fields are left unchanged.
+type UpdateParticipantRequest struct {
+	Name     string `json:"name,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+	// Attributes are merged into the participant's; an empty value
+	// deletes the attribute
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Permission *PermissionUpdate `json:"permission,omitempty"`
+}
+
+// PermissionUpdate changes a participant's permissions. Unset fields keep
+// their current value.
+type PermissionUpdate struct {
+	CanSubscribe   *bool `json:"canSubscribe,omitempty"`
+	CanPublish     *bool `json:"canPublish,omitempty"`
+	CanPublishData *bool `json:"canPublishData,omitempty"`
+	// CanPublishSources limits publishing to these sources (camera,
+	// microphone, screen_share, screen_share_audio); an empty list allows all
+	CanPublishSources []string `json:"canPublishSources,omitempty"`
+	CanUpdateMetadata *bool    `json:"canUpdateMetadata,omitempty"`
+}
+
+// SendDataRequest represents a data message sent to a room
+type SendDataRequest struct {
+	// Data is the UTF-8 payload
+	Data  string `json:"data"`
+	Topic string `json:"topic,omitempty"`
+	// DestinationIdentities limits delivery to these participants;
+	// everyone in the room receives it when empty
+	DestinationIdentities []string `json:"destinationIdentities,omitempty"`
+	// Lossy sends the message unreliably, for small and frequent updates
+	Lossy bool `json:"lossy,omitempty"`
+}
+
+// MuteAllRequest represents a request to mute every non-host participant
+type MuteAllRequest struct {
+	// Kinds picks the tracks to mute, "audio" and/or "video" (default both)
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+// ModerationResponse reports the outcome of a room-wide moderation action
+type ModerationResponse struct {
+	// Affected counts the tracks muted or participants removed
+	Affected int `json:"affected"`
+	// Failed lists the identities the action could not be applied to
+	Failed []string `json:"failed,omitempty"`
+}
+
+// BanListResponse is a room's ban list
+type BanListResponse struct {
+	Room   string   `json:"room"`
+	Banned []string `json:"banned"`
+}
+
+// AttendanceResponse is a room session and who attended it
+type AttendanceResponse struct {
+	Session    *events.Session      `json:"session"`
+	Attendance []*events.Attendance `json:"attendance"`
+}
+
+// ParticipationResponse is an identity's time in rooms
+type ParticipationResponse struct {
+	Identity      string                  `json:"identity"`
+	TotalSeconds  int64                   `json:"totalSeconds"`
+	Participation []*events.Participation `json:"participation"`
+}
+
+// StartRecordingRequest represents a request to record a room
+type StartRecordingRequest struct {
+	// Type is "room" for the room composite (default) or "track" for a
+	// single track
+	Type     string `json:"type,omitempty"`
+	TrackSID string `json:"trackSid,omitempty"`
+	// Layout is the composite layout, e.g. "grid" or "speaker"
+	Layout    string `json:"layout,omitempty"`
+	AudioOnly bool   `json:"audioOnly,omitempty"`
+	VideoOnly bool   `json:"videoOnly,omitempty"`
+}
+
+// RecordingResponse is a recording, with a presigned playback URL once it
+// is complete
+type RecordingResponse struct {
+	*events.Recording
+	URL string `json:"url,omitempty"`
+}
+
+// CreateIngressRequest represents a request to stream into a room
+type CreateIngressRequest struct {
+	// InputType is "rtmp" (default) or "whip"
+	InputType string `json:"inputType,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// ParticipantIdentity is given the "ingress-" prefix when it lacks it
+	ParticipantIdentity string `json:"participantIdentity"`
+	ParticipantName     string `json:"participantName,omitempty"`
+	// EnableTranscoding defaults to on for RTMP and off for WHIP
+	EnableTranscoding *bool `json:"enableTranscoding,omitempty"`
+}
+
+// UpdateIngressRequest represents a request to change an ingress. Empty
+// fields are left unchanged.
+type UpdateIngressRequest struct {
+	Name                string `json:"name,omitempty"`
+	ParticipantIdentity string `json:"participantIdentity,omitempty"`
+	ParticipantName     string `json:"participantName,omitempty"`
+	EnableTranscoding   *bool  `json:"enableTranscoding,omitempty"`
+}
+
+// IngressResponse is an ingress, with its stream URL and key for the room's
+// owner
+type IngressResponse struct {
+	*events.Ingress
+	URL       string `json:"url,omitempty"`
+	StreamKey string `json:"streamKey,omitempty"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
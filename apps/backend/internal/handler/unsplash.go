@@ -3,24 +3,41 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 
 	"myapp/internal/config"
+	"myapp/internal/logging"
+	"myapp/internal/metrics"
+	"myapp/internal/tracing"
 
 	"github.com/labstack/echo/v4"
 )
 
+// UnsplashHandler proxies the Unsplash API. Credentials are read from the
+// reloader on every request so they can be rotated without a restart.
 type UnsplashHandler struct {
-	accessKey string
-	utmSource string
+	settings *config.Reloader
+	logger   *slog.Logger
 }
 
-func NewUnsplashHandler(cfg *config.Config) *UnsplashHandler {
-	return &UnsplashHandler{
-		accessKey: cfg.UnsplashAccessKey,
-		utmSource: cfg.UnsplashUTMSource,
+// unsplashOperation names Unsplash calls in metrics and traces. Download
+// tracking URLs contain photo IDs, so the path can't be used.
+func unsplashOperation(r *http.Request) string {
+	if r.URL.Path == "/search/photos" {
+		return "search"
 	}
+	return "track_download"
+}
+
+// unsplashClient calls the Unsplash API
+var unsplashClient = &http.Client{
+	Transport: tracing.Transport("unsplash", unsplashOperation, metrics.Transport("unsplash", unsplashOperation, nil)),
+}
+
+func NewUnsplashHandler(settings *config.Reloader, logger *slog.Logger) *UnsplashHandler {
+	return &UnsplashHandler{settings: settings, logger: logger}
 }
 
 type UnsplashPhoto struct {
@@ -57,6 +74,13 @@ type UnsplashSearchResponse struct {
 
 // Search handles GET /unsplash/search
 func (h *UnsplashHandler) Search(c echo.Context) error {
+	settings := h.settings.Current()
+	if settings.UnsplashAccessKey == "" {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "unsplash is not configured",
+		})
+	}
+
 	query := c.QueryParam("q")
 	if query == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -83,15 +107,17 @@ func (h *UnsplashHandler) Search(c echo.Context) error {
 
 	req, err := http.NewRequestWithContext(c.Request().Context(), "GET", apiURL, nil)
 	if err != nil {
+		logging.For(c, h.logger).Error("unsplash search failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to create request",
 		})
 	}
 
-	req.Header.Set("Authorization", "Client-ID "+h.accessKey)
+	req.Header.Set("Authorization", "Client-ID "+settings.UnsplashAccessKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := unsplashClient.Do(req)
 	if err != nil {
+		logging.For(c, h.logger).Error("unsplash search failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to fetch from Unsplash",
 		})
@@ -113,7 +139,7 @@ func (h *UnsplashHandler) Search(c echo.Context) error {
 
 	// Add UTM parameters to URLs
 	for i := range searchResp.Results {
-		searchResp.Results[i] = h.addUTMParams(searchResp.Results[i])
+		searchResp.Results[i] = addUTMParams(searchResp.Results[i], settings.UnsplashUTMSource)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
@@ -124,6 +150,13 @@ func (h *UnsplashHandler) Search(c echo.Context) error {
 
 // TrackDownload handles GET /unsplash/download - triggers download tracking
 func (h *UnsplashHandler) TrackDownload(c echo.Context) error {
+	settings := h.settings.Current()
+	if settings.UnsplashAccessKey == "" {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{
+			"error": "unsplash is not configured",
+		})
+	}
+
 	downloadLocation := c.QueryParam("download_location")
 	if downloadLocation == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -133,15 +166,17 @@ func (h *UnsplashHandler) TrackDownload(c echo.Context) error {
 
 	req, err := http.NewRequestWithContext(c.Request().Context(), "GET", downloadLocation, nil)
 	if err != nil {
+		logging.For(c, h.logger).Error("unsplash download tracking failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to create request",
 		})
 	}
 
-	req.Header.Set("Authorization", "Client-ID "+h.accessKey)
+	req.Header.Set("Authorization", "Client-ID "+settings.UnsplashAccessKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := unsplashClient.Do(req)
 	if err != nil {
+		logging.For(c, h.logger).Error("unsplash download tracking failed", "error", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "failed to track download",
 		})
@@ -154,12 +189,12 @@ func (h *UnsplashHandler) TrackDownload(c echo.Context) error {
 	return c.JSON(http.StatusOK, result)
 }
 
-func (h *UnsplashHandler) addUTMParams(photo UnsplashPhoto) UnsplashPhoto {
-	if h.utmSource == "" {
+func addUTMParams(photo UnsplashPhoto, utmSource string) UnsplashPhoto {
+	if utmSource == "" {
 		return photo
 	}
 
-	utmParams := fmt.Sprintf("utm_source=%s&utm_medium=referral", url.QueryEscape(h.utmSource))
+	utmParams := fmt.Sprintf("utm_source=%s&utm_medium=referral", url.QueryEscape(utmSource))
 
 	if photo.Links.HTML != "" {
 		photo.Links.HTML = addQueryParams(photo.Links.HTML, utmParams)
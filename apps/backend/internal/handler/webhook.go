@@ -1,50 +1,156 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"myapp/internal/events"
 	"myapp/internal/livekit"
+	"myapp/internal/logging"
+	"myapp/internal/webhooks"
 
 	"github.com/labstack/echo/v4"
 	"github.com/livekit/protocol/auth"
+	lkproto "github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/webhook"
 )
 
 type WebhookHandler struct {
-	client *livekit.Client
+	client   *livekit.Client
+	store    *events.Store
+	feed     *events.Feed
+	dispatch *webhooks.Dispatcher
+	logger   *slog.Logger
 }
 
-func NewWebhookHandler(client *livekit.Client) *WebhookHandler {
-	return &WebhookHandler{client: client}
+func NewWebhookHandler(client *livekit.Client, store *events.Store, feed *events.Feed, dispatch *webhooks.Dispatcher, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{client: client, store: store, feed: feed, dispatch: dispatch, logger: logger}
 }
 
-// HandleWebhook processes LiveKit webhook events
+// Subscribe registers the handler's own reactions to events: logging them,
+// removing banned participants who join and dropping the ACLs of finished
+// rooms
+func (h *WebhookHandler) Subscribe(d *webhooks.Dispatcher) {
+	d.Subscribe("log", h.logEvent, webhooks.AllEvents)
+	d.Subscribe("remove-banned", h.removeIfBanned, webhook.EventParticipantJoined)
+	d.Subscribe("forget-room-acl", h.forgetRoomACL, webhook.EventRoomFinished)
+}
+
+// HandleWebhook verifies a LiveKit webhook event, stores it, publishes it
+// to the live event stream and queues it for the subscribed handlers
 func (h *WebhookHandler) HandleWebhook(c echo.Context) error {
 	authProvider := auth.NewSimpleKeyProvider(h.client.APIKey(), h.client.Secret())
+	logger := logging.For(c, h.logger)
 
 	event, err := webhook.ReceiveWebhookEvent(c.Request(), authProvider)
 	if err != nil {
-		c.Logger().Errorf("webhook validation failed: %v", err)
+		logger.Warn("webhook validation failed", "error", err)
 		return c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid webhook"})
 	}
 
-	// Handle different webhook events
+	// LiveKit retries deliveries it thinks failed, so events already
+	// stored are acknowledged without processing them again
+	logger = logger.With("event", event.GetEvent(), "event_id", event.GetId())
+	ev := events.FromWebhook(event, time.Now())
+	if h.store.Seen(ev.ID) {
+		logger.Debug("duplicate webhook event ignored")
+		return c.JSON(http.StatusOK, StatusResponse{Status: "duplicate"})
+	}
+	if room := event.GetRoom(); room != nil {
+		acl, err := h.client.ACL(c.Request().Context(), room)
+		if err != nil {
+			logger.Error("room ACL lookup failed", "error", err)
+			return c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "room ACL unavailable, retry later"})
+		}
+		ev.Hosts = acl.Hosts()
+	}
+
+	// Storing the event claims it, so of concurrent deliveries of one event
+	// only the first runs the handlers
+	added, err := h.store.Add(ev)
+	if err != nil {
+		logger.Error("storing webhook event failed", "error", err)
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "event store unavailable, retry later"})
+	}
+	if !added {
+		logger.Debug("duplicate webhook event ignored")
+		return c.JSON(http.StatusOK, StatusResponse{Status: "duplicate"})
+	}
+
+	// Handlers run in the background so LiveKit gets a quick ack; shutdown
+	// waits for them. An event whose handlers can't be queued is removed
+	// again, so LiveKit's retry is not taken for a duplicate.
+	if err := h.dispatch.Dispatch(event); err != nil {
+		logger.Error("webhook handlers not queued", "error", err)
+		if err := h.store.Remove(ev.ID); err != nil {
+			logger.Error("removing unprocessed webhook event failed", "error", err)
+		}
+		return c.JSON(http.StatusServiceUnavailable, ErrorResponse{Error: "webhook queue full, retry later"})
+	}
+
+	// Published here rather than by a handler so streams see events in
+	// the order LiveKit sent them
+	if streamedEvents[ev.Type] {
+		h.feed.Publish(ev)
+	}
+
+	return c.JSON(http.StatusOK, StatusResponse{Status: "received"})
+}
+
+// logEvent logs a verified webhook event
+func (h *WebhookHandler) logEvent(ctx context.Context, event *lkproto.WebhookEvent) error {
+	logger := h.logger.With("event", event.GetEvent(), "event_id", event.GetId())
 	switch event.GetEvent() {
 	case webhook.EventRoomStarted:
-		c.Logger().Infof("Room started: %s", event.Room.Name)
+		logger.InfoContext(ctx, "room started", "room", event.Room.Name)
 	case webhook.EventRoomFinished:
-		c.Logger().Infof("Room finished: %s", event.Room.Name)
+		logger.InfoContext(ctx, "room finished", "room", event.Room.Name)
 	case webhook.EventParticipantJoined:
-		c.Logger().Infof("Participant joined: %s in room %s", event.Participant.Identity, event.Room.Name)
+		logger.InfoContext(ctx, "participant joined", "room", event.Room.Name, "participant", event.Participant.Identity)
 	case webhook.EventParticipantLeft:
-		c.Logger().Infof("Participant left: %s from room %s", event.Participant.Identity, event.Room.Name)
+		logger.InfoContext(ctx, "participant left", "room", event.Room.Name, "participant", event.Participant.Identity)
 	case webhook.EventTrackPublished:
-		c.Logger().Infof("Track published: %s by %s", event.Track.Sid, event.Participant.Identity)
+		logger.InfoContext(ctx, "track published", "track", event.Track.Sid, "participant", event.Participant.Identity)
 	case webhook.EventTrackUnpublished:
-		c.Logger().Infof("Track unpublished: %s by %s", event.Track.Sid, event.Participant.Identity)
+		logger.InfoContext(ctx, "track unpublished", "track", event.Track.Sid, "participant", event.Participant.Identity)
+	case webhook.EventIngressStarted, webhook.EventIngressEnded:
+		info := event.IngressInfo
+		logger.InfoContext(ctx, "ingress state changed", "room", info.RoomName, "ingress_id", info.IngressId,
+			"participant", info.ParticipantIdentity, "status", info.GetState().GetStatus().String(), "error", info.GetState().GetError())
 	default:
-		c.Logger().Infof("Received webhook event: %s", event.GetEvent())
+		logger.InfoContext(ctx, "webhook event received")
 	}
+	return nil
+}
 
-	return c.JSON(http.StatusOK, StatusResponse{Status: "received"})
+// removeIfBanned removes a participant who joined a room they are banned
+// from, with a token issued before the ban
+func (h *WebhookHandler) removeIfBanned(ctx context.Context, event *lkproto.WebhookEvent) error {
+	identity := event.Participant.Identity
+	acl, err := h.client.ACL(ctx, event.Room)
+	if err != nil {
+		return fmt.Errorf("room ACL of %s: %w", event.Room.Name, err)
+	}
+	if !acl.IsBanned(identity) {
+		return nil
+	}
+	err = h.client.RemoveParticipant(ctx, event.Room.Name, identity)
+	if err != nil && !errors.Is(err, livekit.ErrParticipantNotFound) {
+		return fmt.Errorf("remove banned participant %s from %s: %w", identity, event.Room.Name, err)
+	}
+	h.logger.InfoContext(ctx, "removed banned participant", "room", event.Room.Name, "participant", identity)
+	return nil
+}
+
+// forgetRoomACL drops the ACL of a finished room. Its SID is never reused,
+// so the ACL can't apply to a later room of the same name.
+func (h *WebhookHandler) forgetRoomACL(ctx context.Context, event *lkproto.WebhookEvent) error {
+	if err := h.client.ForgetRoomACL(ctx, event.Room.Sid); err != nil {
+		return fmt.Errorf("forget ACL of %s: %w", event.Room.Name, err)
+	}
+	return nil
 }
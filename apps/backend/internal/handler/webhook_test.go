@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"myapp/internal/background"
+	"myapp/internal/events"
+	"myapp/internal/webhooks"
+
+	"github.com/labstack/echo/v4"
+	"github.com/livekit/protocol/auth"
+	lkproto "github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func newTestWebhookHandler(t *testing.T, fake *fakeLiveKit, opts webhooks.Options) (*WebhookHandler, *webhooks.Dispatcher, *background.Tracker) {
+	store, err := events.Open("", time.Hour, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := background.NewTracker()
+	d := webhooks.NewDispatcher(opts, tasks, testLogger)
+	return NewWebhookHandler(fake.client(), store, events.NewFeed(16), d, testLogger), d, tasks
+}
+
+// deliver sends event to h signed the way LiveKit signs webhooks
+func deliver(t *testing.T, h *WebhookHandler, event *lkproto.WebhookEvent) (int, string) {
+	t.Helper()
+	body, err := protojson.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(body)
+	token, err := auth.NewAccessToken("key", "secretsecretsecretsecretsecretsecret").
+		SetValidFor(time.Minute).
+		SetSha256(base64.StdEncoding.EncodeToString(sum[:])).
+		ToJWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, "application/webhook+json")
+	req.Header.Set(echo.HeaderAuthorization, token)
+	rec := httptest.NewRecorder()
+	if err := h.HandleWebhook(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	return rec.Code, rec.Body.String()
+}
+
+func roomStarted(id string) *lkproto.WebhookEvent {
+	return &lkproto.WebhookEvent{
+		Id:        id,
+		Event:     webhook.EventRoomStarted,
+		Room:      &lkproto.Room{Sid: "RM_standup", Name: "standup"},
+		CreatedAt: time.Now().Unix(),
+	}
+}
+
+func TestConcurrentWebhookDeliveriesRunHandlersOnce(t *testing.T) {
+	h, d, tasks := newTestWebhookHandler(t, newFakeLiveKit(t), webhooks.Options{QueueSize: 16, Workers: 2, MaxAttempts: 1})
+	var runs atomic.Int32
+	d.Subscribe("count", func(context.Context, *lkproto.WebhookEvent) error {
+		runs.Add(1)
+		return nil
+	}, webhook.EventRoomStarted)
+
+	const deliveries = 8
+	var wg sync.WaitGroup
+	var received atomic.Int32
+	for range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, body := deliver(t, h, roomStarted("EV_1"))
+			if code != http.StatusOK {
+				t.Errorf("delivery = %d %s, want 200", code, body)
+			}
+			if strings.Contains(body, `"received"`) {
+				received.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	tasks.Drain(context.Background())
+
+	if n := received.Load(); n != 1 {
+		t.Errorf("%d deliveries received, want 1 and the rest duplicate", n)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestRedeliveredWebhookIsNotDispatchedAgain(t *testing.T) {
+	h, d, tasks := newTestWebhookHandler(t, newFakeLiveKit(t), webhooks.Options{QueueSize: 16, Workers: 1, MaxAttempts: 1})
+	var runs atomic.Int32
+	d.Subscribe("count", func(context.Context, *lkproto.WebhookEvent) error {
+		runs.Add(1)
+		return nil
+	}, webhook.EventRoomStarted)
+
+	for _, want := range []string{`"received"`, `"duplicate"`} {
+		code, body := deliver(t, h, roomStarted("EV_1"))
+		if code != http.StatusOK || !strings.Contains(body, want) {
+			t.Fatalf("delivery = %d %s, want %s", code, body, want)
+		}
+		tasks.Drain(context.Background())
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestWebhookRefusedWhenQueueFullIsProcessedOnRetry(t *testing.T) {
+	h, d, tasks := newTestWebhookHandler(t, newFakeLiveKit(t), webhooks.Options{QueueSize: 1, Workers: 1, MaxAttempts: 1})
+	release := make(chan struct{})
+	var runs atomic.Int32
+	d.Subscribe("block", func(context.Context, *lkproto.WebhookEvent) error {
+		runs.Add(1)
+		<-release
+		return nil
+	}, webhook.EventRoomStarted)
+
+	// The first event holds the only worker; wait for it to be picked up
+	// so the second fills the queue
+	if code, body := deliver(t, h, roomStarted("EV_1")); code != http.StatusOK {
+		t.Fatalf("first delivery = %d %s", code, body)
+	}
+	for runs.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if code, body := deliver(t, h, roomStarted("EV_2")); code != http.StatusOK {
+		t.Fatalf("second delivery = %d %s", code, body)
+	}
+	if code, _ := deliver(t, h, roomStarted("EV_3")); code != http.StatusServiceUnavailable {
+		t.Fatalf("delivery with a full queue = %d, want 503", code)
+	}
+
+	close(release)
+	tasks.Drain(context.Background())
+
+	// LiveKit's retry is processed rather than taken for a duplicate
+	code, body := deliver(t, h, roomStarted("EV_3"))
+	if code != http.StatusOK || !strings.Contains(body, `"received"`) {
+		t.Fatalf("retried delivery = %d %s, want received", code, body)
+	}
+	tasks.Drain(context.Background())
+	if n := runs.Load(); n != 3 {
+		t.Errorf("handler ran %d times, want 3", n)
+	}
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit can be set at link time, e.g.
+//
+//	go build -ldflags "-X myapp/internal/health.Version=v1.2.3 -X myapp/internal/health.Commit=abc123"
+//
+// When unset they are taken from the build info embedded by the Go toolchain.
+var (
+	Version string
+	Commit  string
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commitTime,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+	GoVersion  string `json:"goVersion"`
+}
+
+// Build returns version information for the running binary
+func Build() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				info.CommitTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
@@ -0,0 +1,137 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check probes a single dependency. Required checks make the service
+// unready when they fail; optional ones are only reported.
+type Check struct {
+	Name     string
+	Required bool
+	Probe    func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Required  bool   `json:"required"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Ready     bool     `json:"-"`
+	Status    string   `json:"status"`
+	CheckedAt string   `json:"checkedAt"`
+	Checks    []Result `json:"checks"`
+}
+
+// Checker runs dependency checks concurrently and caches the report
+// briefly so frequent probes don't hammer the dependencies
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	mu      sync.Mutex // held while checking, so concurrent probes share a run
+	last    *Report
+	expires time.Time
+}
+
+// NewChecker creates a checker that gives each probe timeout to complete
+// and reuses a report for cacheTTL
+func NewChecker(timeout, cacheTTL time.Duration, checks ...Check) *Checker {
+	return &Checker{
+		checks:   checks,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Check returns the cached report or runs every check
+func (c *Checker) Check(ctx context.Context) *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && time.Now().Before(c.expires) {
+		return c.last
+	}
+
+	// The report is shared with other callers, so don't let this caller
+	// disconnecting fail the probes
+	ctx = context.WithoutCancel(ctx)
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Ready:     true,
+		Status:    "ready",
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+		Checks:    results,
+	}
+	for _, result := range results {
+		if result.Required && result.Status != "up" {
+			report.Ready = false
+			report.Status = "not_ready"
+		}
+	}
+
+	c.last = report
+	c.expires = time.Now().Add(c.cacheTTL)
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	result := Result{
+		Name:      check.Name,
+		Status:    "up",
+		Required:  check.Required,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// HTTPProbe checks that url is reachable. Any response below 500 counts as
+// up, since the goal is reachability rather than a particular endpoint.
+func HTTPProbe(url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
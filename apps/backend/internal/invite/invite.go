@@ -0,0 +1,207 @@
+package invite
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"myapp/internal/config"
+)
+
+// ErrInvalidCode is returned for invite codes that are malformed, were not
+// signed with our key or have expired
+var ErrInvalidCode = errors.New("invalid invite code")
+
+// codeClaims is the signed part of an invite code. It lets codes be
+// checked without a store lookup; the store decides whether uses remain.
+type codeClaims struct {
+	ID      string `json:"id"`
+	Room    string `json:"room"`
+	RoomSID string `json:"sid"`
+	Role    string `json:"role"`
+	Expiry  int64  `json:"exp"`
+}
+
+// Manager creates and redeems invites. Invite codes are
+// "<payload>.<signature>", both base64url: the payload names the invite,
+// room and its SID, role and expiry, and the signature is an HMAC-SHA256 of it.
+type Manager struct {
+	store    Store
+	key      []byte
+	maxTTL   time.Duration
+	linkBase string
+}
+
+// NewManager creates a manager on top of store
+func NewManager(store Store, key []byte, maxTTL time.Duration, linkBase string) *Manager {
+	return &Manager{store: store, key: key, maxTTL: maxTTL, linkBase: linkBase}
+}
+
+// New creates the manager described by the invite settings, shared through
+// Redis when INVITE_REDIS_URL is set, or returns nil when invites are not
+// configured
+func New(cfg *config.Config) (*Manager, error) {
+	if !cfg.InvitesEnabled() {
+		return nil, nil
+	}
+	var store Store = NewMemoryStore()
+	if cfg.InviteRedisURL != "" {
+		redisStore, err := NewRedisStore(cfg.InviteRedisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	}
+	return NewManager(store, []byte(cfg.InviteSigningKey), cfg.InviteMaxTTL, cfg.InviteLinkBase), nil
+}
+
+// MaxTTL is the longest lifetime an invite may be created with
+func (m *Manager) MaxTTL() time.Duration {
+	return m.maxTTL
+}
+
+// Create stores a new invite to room, whose SID is roomSID, with role,
+// valid for ttl and maxUses redemptions (unlimited when zero)
+func (m *Manager) Create(ctx context.Context, room, roomSID, role, createdBy string, ttl time.Duration, maxUses int) (*Invite, error) {
+	if ttl <= 0 || ttl > m.maxTTL {
+		return nil, fmt.Errorf("ttl must be positive and at most %s", m.maxTTL)
+	}
+	if maxUses < 0 {
+		return nil, fmt.Errorf("maxUses must not be negative")
+	}
+
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	inv := &Invite{
+		ID:        hex.EncodeToString(id),
+		Room:      room,
+		RoomSID:   roomSID,
+		Role:      role,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		MaxUses:   maxUses,
+	}
+	if err := m.store.Create(ctx, inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// List returns the room's unexpired invites, oldest first
+func (m *Manager) List(ctx context.Context, room string) ([]*Invite, error) {
+	return m.store.List(ctx, room)
+}
+
+// Revoke revokes the invite with id, which must belong to room
+func (m *Manager) Revoke(ctx context.Context, room, id string) error {
+	inv, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inv.Room != room {
+		return ErrNotFound
+	}
+	return m.store.Revoke(ctx, id)
+}
+
+// Redeem checks code and counts a use of its invite. It returns
+// ErrInvalidCode for codes we did not sign or that have expired, and the
+// store's error when the invite was revoked or used up.
+func (m *Manager) Redeem(ctx context.Context, code string) (*Invite, error) {
+	claims, err := m.verify(code)
+	if err != nil {
+		return nil, err
+	}
+	return m.store.Use(ctx, claims.ID)
+}
+
+// Room returns the name and SID of the room a code invites to, without
+// counting a use. It returns ErrInvalidCode like Redeem.
+func (m *Manager) Room(code string) (room, roomSID string, err error) {
+	claims, err := m.verify(code)
+	if err != nil {
+		return "", "", err
+	}
+	return claims.Room, claims.RoomSID, nil
+}
+
+// Code returns the signed code for inv
+func (m *Manager) Code(inv *Invite) string {
+	payload, _ := json.Marshal(codeClaims{
+		ID:      inv.ID,
+		Room:    inv.Room,
+		RoomSID: inv.RoomSID,
+		Role:    inv.Role,
+		Expiry:  inv.ExpiresAt.Unix(),
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(m.sign(encoded))
+}
+
+// Link returns the shareable link for inv: INVITE_LINK_BASE with the code
+// in its "invite" query parameter, or "" when no link base is configured
+func (m *Manager) Link(inv *Invite) string {
+	if m.linkBase == "" {
+		return ""
+	}
+	u, err := url.Parse(m.linkBase)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	query.Set("invite", m.Code(inv))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Close releases the store's connections, if it holds any
+func (m *Manager) Close() error {
+	if closer, ok := m.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// verify checks code's signature and expiry and returns its claims
+func (m *Manager) verify(code string) (*codeClaims, error) {
+	encoded, signature, ok := strings.Cut(code, ".")
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, m.sign(encoded)) {
+		return nil, ErrInvalidCode
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCode
+	}
+	var claims codeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return nil, ErrInvalidCode
+	}
+	if time.Now().Unix() >= claims.Expiry {
+		return nil, ErrInvalidCode
+	}
+	return &claims, nil
+}
+
+func (m *Manager) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write([]byte("invite:" + encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,162 @@
+package invite
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("invite-signing-key-invite-signing-key")
+
+func newTestManager() *Manager {
+	return NewManager(NewMemoryStore(), testKey, 24*time.Hour, "https://meet.example/join")
+}
+
+func TestVerifyCode(t *testing.T) {
+	m := newTestManager()
+	inv, err := m.Create(context.Background(), "standup", "RM_1", "speaker", "alice", time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := m.Code(inv)
+	payload, signature, _ := strings.Cut(code, ".")
+
+	// resign signs a payload with the test key, as only we can
+	resign := func(claims string) string {
+		encoded := base64.RawURLEncoding.EncodeToString([]byte(claims))
+		return encoded + "." + base64.RawURLEncoding.EncodeToString(m.sign(encoded))
+	}
+	expired := *inv
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	other := NewManager(NewMemoryStore(), []byte("another-signing-key-another-signing-key"), time.Hour, "")
+	decoded, _ := base64.RawURLEncoding.DecodeString(payload)
+	tampered := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(decoded), "standup", "boardroom", 1)))
+
+	tests := []struct {
+		name  string
+		code  string
+		valid bool
+	}{
+		{"valid", code, true},
+		{"signed with another key", other.Code(inv), false},
+		{"tampered payload", tampered + "." + signature, false},
+		{"tampered signature", payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged")), false},
+		{"signature of another code", payload + "." + strings.SplitN(m.Code(&expired), ".", 2)[1], false},
+		{"expired", m.Code(&expired), false},
+		{"no signature", payload, false},
+		{"not base64", payload + ".!!!", false},
+		{"payload not JSON", resign("standup"), false},
+		{"no invite ID", resign(`{"room":"standup","exp":9999999999}`), false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room, sid, err := m.Room(tt.code)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidCode) {
+					t.Fatalf("Room = %q, %q, %v; want ErrInvalidCode", room, sid, err)
+				}
+				if _, err := m.Redeem(context.Background(), tt.code); !errors.Is(err, ErrInvalidCode) {
+					t.Errorf("Redeem error = %v, want ErrInvalidCode", err)
+				}
+				return
+			}
+			if err != nil || room != "standup" || sid != "RM_1" {
+				t.Fatalf("Room = %q, %q, %v; want standup, RM_1", room, sid, err)
+			}
+		})
+	}
+}
+
+func TestRedeemUses(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxUses int
+		revoke  bool
+		// errs holds the result of each redemption in turn
+		errs []error
+	}{
+		{"unlimited", 0, false, []error{nil, nil, nil, nil}},
+		{"single use", 1, false, []error{nil, ErrExhausted, ErrExhausted}},
+		{"max uses", 3, false, []error{nil, nil, nil, ErrExhausted}},
+		{"revoked", 0, true, []error{ErrRevoked, ErrRevoked}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager()
+			ctx := context.Background()
+			inv, err := m.Create(ctx, "standup", "RM_1", "viewer", "alice", time.Hour, tt.maxUses)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.revoke {
+				if err := m.Revoke(ctx, "standup", inv.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, want := range tt.errs {
+				used, err := m.Redeem(ctx, m.Code(inv))
+				if !errors.Is(err, want) {
+					t.Fatalf("redemption %d error = %v, want %v", i+1, err, want)
+				}
+				if err == nil && used.Uses != i+1 {
+					t.Errorf("redemption %d counted %d uses", i+1, used.Uses)
+				}
+			}
+		})
+	}
+}
+
+func TestInviteUse(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		inv  Invite
+		want error
+	}{
+		{"unused", Invite{ExpiresAt: now.Add(time.Minute), MaxUses: 1}, nil},
+		{"expired", Invite{ExpiresAt: now}, ErrNotFound},
+		{"expired and revoked", Invite{ExpiresAt: now.Add(-time.Minute), Revoked: true}, ErrNotFound},
+		{"revoked", Invite{ExpiresAt: now.Add(time.Minute), Revoked: true}, ErrRevoked},
+		{"used up", Invite{ExpiresAt: now.Add(time.Minute), MaxUses: 2, Uses: 2}, ErrExhausted},
+		{"unlimited", Invite{ExpiresAt: now.Add(time.Minute), Uses: 1000}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uses := tt.inv.Uses
+			if err := tt.inv.use(now); !errors.Is(err, tt.want) {
+				t.Fatalf("use = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && tt.inv.Uses != uses+1 {
+				t.Errorf("uses = %d, want %d", tt.inv.Uses, uses+1)
+			}
+		})
+	}
+}
+
+func TestCreateLimits(t *testing.T) {
+	m := newTestManager()
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		maxUses int
+		ok      bool
+	}{
+		{"within limits", time.Hour, 5, true},
+		{"max TTL", 24 * time.Hour, 0, true},
+		{"zero TTL", 0, 0, false},
+		{"past max TTL", 25 * time.Hour, 0, false},
+		{"negative uses", time.Hour, -1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Create(context.Background(), "standup", "RM_1", "viewer", "alice", tt.ttl, tt.maxUses)
+			if ok := err == nil; ok != tt.ok {
+				t.Errorf("Create error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package invite
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store. Invites are only visible to this
+// process and are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	invites map[string]*Invite
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{invites: make(map[string]*Invite)}
+}
+
+// Create stores a new invite, dropping expired ones
+func (s *MemoryStore) Create(_ context.Context, inv *Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range s.invites {
+		if !now.Before(existing.ExpiresAt) {
+			delete(s.invites, id)
+		}
+	}
+	stored := *inv
+	s.invites[inv.ID] = &stored
+	return nil
+}
+
+// Get returns the invite with id, or ErrNotFound
+func (s *MemoryStore) Get(_ context.Context, id string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[id]
+	if !ok || !time.Now().Before(inv.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	found := *inv
+	return &found, nil
+}
+
+// List returns the unexpired invites for room, oldest first
+func (s *MemoryStore) List(_ context.Context, room string) ([]*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	invites := []*Invite{}
+	for _, inv := range s.invites {
+		if inv.Room == room && now.Before(inv.ExpiresAt) {
+			found := *inv
+			invites = append(invites, &found)
+		}
+	}
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.Before(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+// Use counts a redemption of the invite with id
+func (s *MemoryStore) Use(_ context.Context, id string) (*Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := inv.use(time.Now()); err != nil {
+		return nil, err
+	}
+	used := *inv
+	return &used, nil
+}
+
+// Revoke marks the invite with id as revoked
+func (s *MemoryStore) Revoke(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[id]
+	if !ok || !time.Now().Before(inv.ExpiresAt) {
+		return ErrNotFound
+	}
+	inv.Revoked = true
+	return nil
+}
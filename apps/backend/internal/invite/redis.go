@@ -0,0 +1,204 @@
+package invite
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key prefixes namespacing invites in a shared Redis. Invite IDs are hex,
+// so they never collide with the room index.
+const (
+	redisKeyPrefix       = "invite:"
+	redisRoomIndexPrefix = "invite:room:"
+)
+
+// redisMaxRetries bounds how often an update is retried when another
+// replica changed the invite concurrently
+const redisMaxRetries = 10
+
+// RedisStore is a Store shared by every replica through Redis. Invites
+// expire with Redis key TTLs, and updates use optimistic transactions so a
+// single-use invite can only be redeemed once.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis server at url
+// (redis://[:password@]host:port/db or rediss:// for TLS)
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Create stores a new invite and indexes it by room
+func (s *RedisStore) Create(ctx context.Context, inv *Invite) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	ttl := time.Until(inv.ExpiresAt)
+	if ttl <= 0 {
+		return ErrNotFound
+	}
+
+	index := redisRoomIndexPrefix + inv.Room
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisKeyPrefix+inv.ID, data, ttl)
+		pipe.SAdd(ctx, index, inv.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keep the index until its longest-lived invite expires. A concurrent
+	// create may shorten it, which only hides invites from List.
+	current, err := s.client.PTTL(ctx, index).Result()
+	if err == nil && current < ttl {
+		err = s.client.PExpire(ctx, index, ttl).Err()
+	}
+	return err
+}
+
+// Get returns the invite with id, or ErrNotFound
+func (s *RedisStore) Get(ctx context.Context, id string) (*Invite, error) {
+	data, err := s.client.Get(ctx, redisKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// List returns the unexpired invites for room, oldest first. Expired
+// invites are dropped from the index as they are found.
+func (s *RedisStore) List(ctx context.Context, room string) ([]*Invite, error) {
+	index := redisRoomIndexPrefix + room
+	ids, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	invites := []*Invite{}
+	if len(ids) == 0 {
+		return invites, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = redisKeyPrefix + id
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		inv, err := decode([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, inv)
+	}
+	if len(expired) > 0 {
+		s.client.SRem(ctx, index, expired...)
+	}
+
+	sort.Slice(invites, func(i, j int) bool { return invites[i].CreatedAt.Before(invites[j].CreatedAt) })
+	return invites, nil
+}
+
+// Use counts a redemption of the invite with id
+func (s *RedisStore) Use(ctx context.Context, id string) (*Invite, error) {
+	return s.update(ctx, id, func(inv *Invite) error {
+		return inv.use(time.Now())
+	})
+}
+
+// Revoke marks the invite with id as revoked
+func (s *RedisStore) Revoke(ctx context.Context, id string) error {
+	_, err := s.update(ctx, id, func(inv *Invite) error {
+		inv.Revoked = true
+		return nil
+	})
+	return err
+}
+
+// update applies fn to the invite with id and writes it back, retrying
+// when the invite changed in between
+func (s *RedisStore) update(ctx context.Context, id string, fn func(*Invite) error) (*Invite, error) {
+	key := redisKeyPrefix + id
+	var updated *Invite
+
+	txn := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		inv, err := decode(data)
+		if err != nil {
+			return err
+		}
+		if err := fn(inv); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(inv); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, redis.KeepTTL)
+			return nil
+		})
+		updated = inv
+		return err
+	}
+
+	for i := 0; i < redisMaxRetries; i++ {
+		err := s.client.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("invite %s: too many concurrent updates", id)
+}
+
+// Ping checks the connection to Redis
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the connection pool
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func decode(data []byte) (*Invite, error) {
+	var inv Invite
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("decode invite: %w", err)
+	}
+	return &inv, nil
+}
@@ -0,0 +1,64 @@
+package invite
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for invites that do not exist or have expired
+	ErrNotFound = errors.New("invite not found")
+	// ErrRevoked is returned when redeeming a revoked invite
+	ErrRevoked = errors.New("invite revoked")
+	// ErrExhausted is returned when an invite has no uses left
+	ErrExhausted = errors.New("invite has no uses left")
+)
+
+// Invite grants whoever redeems it a token for Room with Role, until it
+// expires, is revoked or has been used MaxUses times. It is bound to the
+// room with RoomSID, so it can't be redeemed for a later room that reuses
+// the name.
+type Invite struct {
+	ID        string    `json:"id"`
+	Room      string    `json:"room"`
+	RoomSID   string    `json:"roomSid"`
+	Role      string    `json:"role"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// MaxUses is zero for invites that can be redeemed any number of times
+	MaxUses int  `json:"maxUses,omitempty"`
+	Uses    int  `json:"uses"`
+	Revoked bool `json:"revoked,omitempty"`
+}
+
+// use counts a redemption, or returns why the invite cannot be redeemed
+func (inv *Invite) use(now time.Time) error {
+	switch {
+	case !now.Before(inv.ExpiresAt):
+		return ErrNotFound
+	case inv.Revoked:
+		return ErrRevoked
+	case inv.MaxUses > 0 && inv.Uses >= inv.MaxUses:
+		return ErrExhausted
+	}
+	inv.Uses++
+	return nil
+}
+
+// Store holds invites until they expire. Implementations must be safe for
+// concurrent use, and Use and Revoke must be atomic.
+type Store interface {
+	// Create stores a new invite
+	Create(ctx context.Context, inv *Invite) error
+	// Get returns the invite with id, or ErrNotFound
+	Get(ctx context.Context, id string) (*Invite, error)
+	// List returns the unexpired invites for room, oldest first
+	List(ctx context.Context, room string) ([]*Invite, error)
+	// Use counts a redemption of the invite with id and returns it
+	// updated, or the reason it cannot be redeemed
+	Use(ctx context.Context, id string) (*Invite, error)
+	// Revoke marks the invite with id as revoked
+	Revoke(ctx context.Context, id string) error
+}
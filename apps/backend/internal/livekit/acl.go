@@ -0,0 +1,172 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"myapp/internal/config"
+)
+
+// ErrACLExists is returned when creating the ACL of a room that has one
+var ErrACLExists = errors.New("room already has an ACL")
+
+// aclRetention is how long an ACL is kept after it was last read or
+// written. Room SIDs are never reused, so this only bounds the ACLs of
+// rooms whose room_finished event was missed.
+const aclRetention = 30 * 24 * time.Hour
+
+// RoomACL records who controls a room. It is kept by the backend, keyed by
+// the room's SID, rather than in the room's metadata: LiveKit sends
+// metadata to every participant, and any RoomAdmin token can rewrite it.
+// Rooms created outside the API have no ACL, and so no owner.
+type RoomACL struct {
+	Owner      string   `json:"owner"`
+	Moderators []string `json:"moderators,omitempty"`
+	// Lobby makes everyone but the owner and moderators wait for a host to
+	// admit them before they get a token
+	Lobby bool `json:"lobby,omitempty"`
+	// Banned identities are removed from the room and refused tokens
+	Banned []string `json:"banned,omitempty"`
+}
+
+// CanModerate reports whether userID owns or moderates the room
+func (a *RoomACL) CanModerate(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	return a.Owner == userID || slices.Contains(a.Moderators, userID)
+}
+
+// IsBanned reports whether identity is on the room's ban list
+func (a *RoomACL) IsBanned(identity string) bool {
+	return slices.Contains(a.Banned, identity)
+}
+
+// Hosts returns the owner followed by the moderators, or nil for a room
+// without an owner
+func (a *RoomACL) Hosts() []string {
+	if a.Owner == "" {
+		return nil
+	}
+	return append([]string{a.Owner}, a.Moderators...)
+}
+
+func (a *RoomACL) clone() *RoomACL {
+	out := *a
+	out.Moderators = slices.Clone(a.Moderators)
+	out.Banned = slices.Clone(a.Banned)
+	return &out
+}
+
+// ACLStore keeps room ACLs by room SID. Implementations must be safe for
+// concurrent use, and Create and Update must be atomic.
+type ACLStore interface {
+	// Get returns the ACL of the room with sid, or nil when it has none
+	Get(ctx context.Context, sid string) (*RoomACL, error)
+	// Create stores acl for the room with sid, or returns ErrACLExists
+	// when the room already has one
+	Create(ctx context.Context, sid string, acl *RoomACL) error
+	// Update applies update to the ACL of the room with sid, stores the
+	// result and returns it. It returns ErrRoomNotFound when the room has
+	// no ACL, and an error from update as is without writing anything.
+	Update(ctx context.Context, sid string, update func(acl *RoomACL) error) (*RoomACL, error)
+	// Delete forgets the ACL of the room with sid
+	Delete(ctx context.Context, sid string) error
+}
+
+// NewACLStore creates the ACL store described by the LiveKit settings,
+// shared through Redis when ROOM_ACL_REDIS_URL is set
+func NewACLStore(cfg *config.Config) (ACLStore, error) {
+	if cfg.RoomACLRedisURL != "" {
+		return NewRedisACLStore(cfg.RoomACLRedisURL)
+	}
+	return NewMemoryACLStore(), nil
+}
+
+// MemoryACLStore is an in-process ACLStore. ACLs are only visible to this
+// process and are lost on restart.
+type MemoryACLStore struct {
+	mu   sync.Mutex
+	acls map[string]*memoryACL
+}
+
+type memoryACL struct {
+	acl     *RoomACL
+	expires time.Time
+}
+
+// NewMemoryACLStore creates an empty store
+func NewMemoryACLStore() *MemoryACLStore {
+	return &MemoryACLStore{acls: make(map[string]*memoryACL)}
+}
+
+// Get returns the ACL of the room with sid, keeping it for another
+// aclRetention
+func (s *MemoryACLStore) Get(_ context.Context, sid string) (*RoomACL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.lookup(sid)
+	if entry == nil {
+		return nil, nil
+	}
+	return entry.acl.clone(), nil
+}
+
+// Create stores acl unless the room has an ACL, dropping expired ones
+func (s *MemoryACLStore) Create(_ context.Context, sid string, acl *RoomACL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, entry := range s.acls {
+		if !now.Before(entry.expires) {
+			delete(s.acls, id)
+		}
+	}
+	if _, ok := s.acls[sid]; ok {
+		return ErrACLExists
+	}
+	s.acls[sid] = &memoryACL{acl: acl.clone(), expires: now.Add(aclRetention)}
+	return nil
+}
+
+// Update applies update to a copy of the room's ACL and stores it
+func (s *MemoryACLStore) Update(_ context.Context, sid string, update func(acl *RoomACL) error) (*RoomACL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.lookup(sid)
+	if entry == nil {
+		return nil, ErrRoomNotFound
+	}
+	acl := entry.acl.clone()
+	if err := update(acl); err != nil {
+		return nil, err
+	}
+	entry.acl = acl
+	return acl.clone(), nil
+}
+
+// Delete forgets the room's ACL
+func (s *MemoryACLStore) Delete(_ context.Context, sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.acls, sid)
+	return nil
+}
+
+// lookup returns the unexpired entry for sid, extending its retention
+func (s *MemoryACLStore) lookup(sid string) *memoryACL {
+	entry, ok := s.acls[sid]
+	now := time.Now()
+	if !ok || !now.Before(entry.expires) {
+		delete(s.acls, sid)
+		return nil
+	}
+	entry.expires = now.Add(aclRetention)
+	return entry
+}
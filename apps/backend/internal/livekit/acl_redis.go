@@ -0,0 +1,137 @@
+package livekit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisACLPrefix namespaces ACLs in a shared Redis
+const redisACLPrefix = "roomacl:"
+
+// redisMaxRetries bounds how often an ACL update is retried when another
+// replica changed the ACL concurrently
+const redisMaxRetries = 10
+
+// RedisACLStore is an ACLStore shared by every replica through Redis.
+// Updates use optimistic transactions, so concurrent changes made on
+// different replicas never undo each other.
+type RedisACLStore struct {
+	client *redis.Client
+}
+
+// NewRedisACLStore connects to the Redis server at url
+// (redis://[:password@]host:port/db or rediss:// for TLS)
+func NewRedisACLStore(url string) (*RedisACLStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisACLStore{client: redis.NewClient(opts)}, nil
+}
+
+// Get returns the ACL of the room with sid, keeping it for another
+// aclRetention
+func (s *RedisACLStore) Get(ctx context.Context, sid string) (*RoomACL, error) {
+	key := redisACLPrefix + sid
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Expire(ctx, key, aclRetention)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeACL([]byte(get.Val()))
+}
+
+// Create stores acl unless the room has an ACL
+func (s *RedisACLStore) Create(ctx context.Context, sid string, acl *RoomACL) error {
+	data, err := json.Marshal(acl)
+	if err != nil {
+		return err
+	}
+	created, err := s.client.SetNX(ctx, redisACLPrefix+sid, data, aclRetention).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrACLExists
+	}
+	return nil
+}
+
+// Update applies update to the room's ACL, retrying when the ACL changed
+// in between
+func (s *RedisACLStore) Update(ctx context.Context, sid string, update func(acl *RoomACL) error) (*RoomACL, error) {
+	key := redisACLPrefix + sid
+	var updated *RoomACL
+
+	txn := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return ErrRoomNotFound
+		}
+		if err != nil {
+			return err
+		}
+		acl, err := decodeACL(data)
+		if err != nil {
+			return err
+		}
+		if err := update(acl); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(acl); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, aclRetention)
+			return nil
+		})
+		updated = acl
+		return err
+	}
+
+	for i := 0; i < redisMaxRetries; i++ {
+		err := s.client.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	return nil, fmt.Errorf("room %s: too many concurrent ACL updates", sid)
+}
+
+// Delete forgets the room's ACL
+func (s *RedisACLStore) Delete(ctx context.Context, sid string) error {
+	return s.client.Del(ctx, redisACLPrefix+sid).Err()
+}
+
+// Ping checks the connection to Redis
+func (s *RedisACLStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the connection pool
+func (s *RedisACLStore) Close() error {
+	return s.client.Close()
+}
+
+func decodeACL(data []byte) (*RoomACL, error) {
+	var acl RoomACL
+	if err := json.Unmarshal(data, &acl); err != nil {
+		return nil, fmt.Errorf("decode room ACL: %w", err)
+	}
+	return &acl, nil
+}
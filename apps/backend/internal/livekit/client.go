@@ -1,21 +1,50 @@
 package livekit
 
 import (
+	"context"
+	"io"
+
 	"myapp/internal/config"
+	"myapp/internal/metrics"
+	"myapp/internal/tracing"
 
+	lkproto "github.com/livekit/protocol/livekit"
 	lksdk "github.com/livekit/server-sdk-go/v2"
+	"github.com/twitchtv/twirp"
 )
 
 type Client struct {
-	cfg *config.Config
+	cfg  *config.Config
+	acls ACLStore
 }
 
-func NewClient(cfg *config.Config) *Client {
-	return &Client{cfg: cfg}
+// NewClient creates a client keeping room ACLs in acls
+func NewClient(cfg *config.Config, acls ACLStore) *Client {
+	return &Client{cfg: cfg, acls: acls}
 }
 
 func (c *Client) RoomService() *lksdk.RoomServiceClient {
-	return lksdk.NewRoomServiceClient(c.cfg.LivekitHost, c.cfg.LivekitAPIKey, c.cfg.LivekitSecret)
+	return lksdk.NewRoomServiceClient(c.cfg.LivekitHost, c.cfg.LivekitAPIKey, c.cfg.LivekitSecret,
+		twirp.WithClientHooks(twirp.ChainClientHooks(
+			metrics.TwirpHooks("livekit"),
+			tracing.TwirpHooks("livekit"),
+		)))
+}
+
+func (c *Client) EgressService() *lksdk.EgressClient {
+	return lksdk.NewEgressClient(c.cfg.LivekitHost, c.cfg.LivekitAPIKey, c.cfg.LivekitSecret,
+		twirp.WithClientHooks(twirp.ChainClientHooks(
+			metrics.TwirpHooks("livekit"),
+			tracing.TwirpHooks("livekit"),
+		)))
+}
+
+func (c *Client) IngressService() *lksdk.IngressClient {
+	return lksdk.NewIngressClient(c.cfg.LivekitHost, c.cfg.LivekitAPIKey, c.cfg.LivekitSecret,
+		twirp.WithClientHooks(twirp.ChainClientHooks(
+			metrics.TwirpHooks("livekit"),
+			tracing.TwirpHooks("livekit"),
+		)))
 }
 
 func (c *Client) APIKey() string {
@@ -25,3 +54,17 @@ func (c *Client) APIKey() string {
 func (c *Client) Secret() string {
 	return c.cfg.LivekitSecret
 }
+
+// Ping checks that the LiveKit server is reachable and accepts our credentials
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.RoomService().ListRooms(ctx, &lkproto.ListRoomsRequest{})
+	return err
+}
+
+// Close releases the ACL store's connections, if it holds any
+func (c *Client) Close() error {
+	if closer, ok := c.acls.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+var (
+	// ErrEgressNotFound is returned for egress IDs LiveKit does not know
+	ErrEgressNotFound = errors.New("egress not found")
+	// ErrEgressEnded is returned when stopping an egress that already ended
+	ErrEgressEnded = errors.New("egress already ended")
+)
+
+// RecordingOptions tunes a room composite recording
+type RecordingOptions struct {
+	// Layout is the composite template layout, e.g. "grid" or "speaker"
+	Layout    string
+	AudioOnly bool
+	VideoOnly bool
+}
+
+// StartRoomRecording records room's composite as an MP4 (OGG when audio
+// only) uploaded to key in the R2 bucket
+func (c *Client) StartRoomRecording(ctx context.Context, room, key string, opts RecordingOptions) (*lkproto.EgressInfo, error) {
+	fileType := lkproto.EncodedFileType_MP4
+	if opts.AudioOnly {
+		fileType = lkproto.EncodedFileType_OGG
+	}
+	return c.EgressService().StartRoomCompositeEgress(ctx, &lkproto.RoomCompositeEgressRequest{
+		RoomName:  room,
+		Layout:    opts.Layout,
+		AudioOnly: opts.AudioOnly,
+		VideoOnly: opts.VideoOnly,
+		FileOutputs: []*lkproto.EncodedFileOutput{{
+			FileType:        fileType,
+			Filepath:        key,
+			DisableManifest: true,
+			Output:          &lkproto.EncodedFileOutput_S3{S3: c.recordingUpload()},
+		}},
+	})
+}
+
+// StartTrackRecording records a single track without transcoding, uploaded
+// to key in the R2 bucket. LiveKit adds the extension matching the codec
+// when key has none.
+func (c *Client) StartTrackRecording(ctx context.Context, room, trackSID, key string) (*lkproto.EgressInfo, error) {
+	return c.EgressService().StartTrackEgress(ctx, &lkproto.TrackEgressRequest{
+		RoomName: room,
+		TrackId:  trackSID,
+		Output: &lkproto.TrackEgressRequest_File{File: &lkproto.DirectFileOutput{
+			Filepath:        key,
+			DisableManifest: true,
+			Output:          &lkproto.DirectFileOutput_S3{S3: c.recordingUpload()},
+		}},
+	})
+}
+
+// GetEgress returns the egress with id, or ErrEgressNotFound
+func (c *Client) GetEgress(ctx context.Context, id string) (*lkproto.EgressInfo, error) {
+	res, err := c.EgressService().ListEgress(ctx, &lkproto.ListEgressRequest{EgressId: id})
+	var twerr twirp.Error
+	if errors.As(err, &twerr) && twerr.Code() == twirp.NotFound {
+		return nil, fmt.Errorf("%w: %s", ErrEgressNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range res.Items {
+		if info.EgressId == id {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrEgressNotFound, id)
+}
+
+// ListActiveEgress returns the egress running for room
+func (c *Client) ListActiveEgress(ctx context.Context, room string) ([]*lkproto.EgressInfo, error) {
+	res, err := c.EgressService().ListEgress(ctx, &lkproto.ListEgressRequest{RoomName: room, Active: true})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// StopEgress stops the egress with id, or returns ErrEgressNotFound or
+// ErrEgressEnded
+func (c *Client) StopEgress(ctx context.Context, id string) (*lkproto.EgressInfo, error) {
+	info, err := c.EgressService().StopEgress(ctx, &lkproto.StopEgressRequest{EgressId: id})
+	var twerr twirp.Error
+	if errors.As(err, &twerr) {
+		switch twerr.Code() {
+		case twirp.NotFound:
+			return nil, fmt.Errorf("%w: %s", ErrEgressNotFound, id)
+		case twirp.FailedPrecondition:
+			return nil, fmt.Errorf("%w: %s", ErrEgressEnded, id)
+		}
+	}
+	return info, err
+}
+
+// recordingUpload points egress at the R2 bucket
+func (c *Client) recordingUpload() *lkproto.S3Upload {
+	return &lkproto.S3Upload{
+		AccessKey:      c.cfg.R2AccessKeyID,
+		Secret:         c.cfg.R2SecretAccessKey,
+		Endpoint:       c.cfg.R2Endpoint,
+		Bucket:         c.cfg.R2Bucket,
+		Region:         "auto",
+		ForcePathStyle: true,
+	}
+}
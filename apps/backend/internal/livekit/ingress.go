@@ -0,0 +1,87 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+// ErrIngressNotFound is returned for ingress IDs LiveKit does not know
+var ErrIngressNotFound = errors.New("ingress not found")
+
+// IngressOptions describes who an ingress publishes as. Empty fields are
+// left unchanged by UpdateIngress.
+type IngressOptions struct {
+	Name                string
+	ParticipantIdentity string
+	ParticipantName     string
+	// EnableTranscoding is LiveKit's default when nil: on for RTMP, off
+	// for WHIP
+	EnableTranscoding *bool
+}
+
+// CreateIngress creates an RTMP or WHIP ingress publishing into room
+func (c *Client) CreateIngress(ctx context.Context, input lkproto.IngressInput, room string, opts IngressOptions) (*lkproto.IngressInfo, error) {
+	return c.IngressService().CreateIngress(ctx, &lkproto.CreateIngressRequest{
+		InputType:           input,
+		Name:                opts.Name,
+		RoomName:            room,
+		ParticipantIdentity: opts.ParticipantIdentity,
+		ParticipantName:     opts.ParticipantName,
+		EnableTranscoding:   opts.EnableTranscoding,
+	})
+}
+
+// ListIngress returns the ingress publishing into room
+func (c *Client) ListIngress(ctx context.Context, room string) ([]*lkproto.IngressInfo, error) {
+	res, err := c.IngressService().ListIngress(ctx, &lkproto.ListIngressRequest{RoomName: room})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+// GetIngress returns the ingress with id, or ErrIngressNotFound
+func (c *Client) GetIngress(ctx context.Context, id string) (*lkproto.IngressInfo, error) {
+	res, err := c.IngressService().ListIngress(ctx, &lkproto.ListIngressRequest{IngressId: id})
+	if err != nil {
+		return nil, ingressError(err, id)
+	}
+	for _, info := range res.Items {
+		if info.IngressId == id {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrIngressNotFound, id)
+}
+
+// UpdateIngress changes the name and participant of the ingress with id,
+// or returns ErrIngressNotFound
+func (c *Client) UpdateIngress(ctx context.Context, id string, opts IngressOptions) (*lkproto.IngressInfo, error) {
+	info, err := c.IngressService().UpdateIngress(ctx, &lkproto.UpdateIngressRequest{
+		IngressId:           id,
+		Name:                opts.Name,
+		ParticipantIdentity: opts.ParticipantIdentity,
+		ParticipantName:     opts.ParticipantName,
+		EnableTranscoding:   opts.EnableTranscoding,
+	})
+	return info, ingressError(err, id)
+}
+
+// DeleteIngress deletes the ingress with id, disconnecting its stream, or
+// returns ErrIngressNotFound
+func (c *Client) DeleteIngress(ctx context.Context, id string) error {
+	_, err := c.IngressService().DeleteIngress(ctx, &lkproto.DeleteIngressRequest{IngressId: id})
+	return ingressError(err, id)
+}
+
+func ingressError(err error, id string) error {
+	var twerr twirp.Error
+	if errors.As(err, &twerr) && twerr.Code() == twirp.NotFound {
+		return fmt.Errorf("%w: %s", ErrIngressNotFound, id)
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"github.com/twitchtv/twirp"
+)
+
+// ErrParticipantNotFound is returned when a participant is not in a room
+var ErrParticipantNotFound = errors.New("participant not found")
+
+// GetParticipant returns the participant with identity in room, or
+// ErrParticipantNotFound
+func (c *Client) GetParticipant(ctx context.Context, room, identity string) (*lkproto.ParticipantInfo, error) {
+	participant, err := c.RoomService().GetParticipant(ctx, &lkproto.RoomParticipantIdentity{
+		Room:     room,
+		Identity: identity,
+	})
+	var twerr twirp.Error
+	if errors.As(err, &twerr) && twerr.Code() == twirp.NotFound {
+		return nil, fmt.Errorf("%w: %s", ErrParticipantNotFound, identity)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return participant, nil
+}
+
+// ParseTrackSources parses track source names such as "camera" or
+// "screen_share_audio"
+func ParseTrackSources(names []string) ([]lkproto.TrackSource, error) {
+	sources := make([]lkproto.TrackSource, 0, len(names))
+	for _, name := range names {
+		value, ok := lkproto.TrackSource_value[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok || lkproto.TrackSource(value) == lkproto.TrackSource_UNKNOWN {
+			return nil, fmt.Errorf("unknown track source %q", name)
+		}
+		sources = append(sources, lkproto.TrackSource(value))
+	}
+	return sources, nil
+}
+
+// RemoveParticipant removes the participant with identity from room, or
+// returns ErrParticipantNotFound if they are not in it
+func (c *Client) RemoveParticipant(ctx context.Context, room, identity string) error {
+	_, err := c.RoomService().RemoveParticipant(ctx, &lkproto.RoomParticipantIdentity{
+		Room:     room,
+		Identity: identity,
+	})
+	var twerr twirp.Error
+	if errors.As(err, &twerr) && twerr.Code() == twirp.NotFound {
+		return fmt.Errorf("%w: %s", ErrParticipantNotFound, identity)
+	}
+	return err
+}
@@ -0,0 +1,74 @@
+package livekit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// ErrRoomNotFound is returned when a room does not exist
+var ErrRoomNotFound = errors.New("room not found")
+
+// GetRoom returns the named room, or ErrRoomNotFound
+func (c *Client) GetRoom(ctx context.Context, name string) (*lkproto.Room, error) {
+	res, err := c.RoomService().ListRooms(ctx, &lkproto.ListRoomsRequest{Names: []string{name}})
+	if err != nil {
+		return nil, err
+	}
+	for _, room := range res.Rooms {
+		if room.Name == name {
+			return room, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, name)
+}
+
+// ACL returns the ACL of room, or an ACL without an owner when the room
+// has none
+func (c *Client) ACL(ctx context.Context, room *lkproto.Room) (*RoomACL, error) {
+	acl, err := c.acls.Get(ctx, room.Sid)
+	if err != nil {
+		return nil, err
+	}
+	if acl == nil {
+		return &RoomACL{}, nil
+	}
+	return acl, nil
+}
+
+// RoomACL returns the named room and its ACL, or ErrRoomNotFound
+func (c *Client) RoomACL(ctx context.Context, name string) (*lkproto.Room, *RoomACL, error) {
+	room, err := c.GetRoom(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	acl, err := c.ACL(ctx, room)
+	if err != nil {
+		return nil, nil, err
+	}
+	return room, acl, nil
+}
+
+// CreateRoomACL gives room its first ACL, or returns ErrACLExists
+func (c *Client) CreateRoomACL(ctx context.Context, room *lkproto.Room, acl *RoomACL) error {
+	return c.acls.Create(ctx, room.Sid, acl)
+}
+
+// UpdateRoomACL applies update to room's current ACL and returns the
+// stored ACL, or ErrRoomNotFound when the room has no ACL. An error from
+// update is returned as is and nothing is written. Updates are atomic, also
+// across replicas sharing the ACL store.
+func (c *Client) UpdateRoomACL(ctx context.Context, room *lkproto.Room, update func(acl *RoomACL) error) (*RoomACL, error) {
+	acl, err := c.acls.Update(ctx, room.Sid, update)
+	if errors.Is(err, ErrRoomNotFound) {
+		return nil, fmt.Errorf("%w: %s has no ACL", ErrRoomNotFound, room.Name)
+	}
+	return acl, err
+}
+
+// ForgetRoomACL drops the ACL of the room with sid once the room is gone
+func (c *Client) ForgetRoomACL(ctx context.Context, sid string) error {
+	return c.acls.Delete(ctx, sid)
+}
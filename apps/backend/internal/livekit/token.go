@@ -0,0 +1,186 @@
+package livekit
+
+import (
+	"fmt"
+	"time"
+
+	"myapp/internal/config"
+
+	"github.com/livekit/protocol/auth"
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// DefaultTokenTTL is how long minted tokens are valid when no TTL is given
+const DefaultTokenTTL = time.Hour
+
+// MinTokenTTL is the shortest validity a token may be requested with
+const MinTokenTTL = time.Minute
+
+// Role is a preset of permissions a participant joins a room with
+type Role string
+
+const (
+	// RoleHost publishes, subscribes and updates its own metadata; only the
+	// room's owner also gets RoomAdmin (see TokenOptions.RoomAdmin)
+	RoleHost Role = "host"
+	// RoleSpeaker publishes camera, microphone and screen share
+	RoleSpeaker Role = "speaker"
+	// RoleViewer only subscribes and sends data messages such as chat
+	RoleViewer Role = "viewer"
+	// RoleRecorder is a hidden subscriber marked as recording the room
+	RoleRecorder Role = "recorder"
+	// RoleObserver is a hidden subscriber, invisible to other participants
+	RoleObserver Role = "observer"
+)
+
+// DefaultRole is used when a token request names no role
+const DefaultRole = RoleSpeaker
+
+// speakerSources are the track sources speakers and hosts may publish
+var speakerSources = []lkproto.TrackSource{
+	lkproto.TrackSource_CAMERA,
+	lkproto.TrackSource_MICROPHONE,
+	lkproto.TrackSource_SCREEN_SHARE,
+	lkproto.TrackSource_SCREEN_SHARE_AUDIO,
+}
+
+// ParseRole returns the role named name, or DefaultRole when it is empty
+func ParseRole(name string) (Role, error) {
+	switch role := Role(name); role {
+	case "":
+		return DefaultRole, nil
+	case RoleHost, RoleSpeaker, RoleViewer, RoleRecorder, RoleObserver:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q", name)
+	}
+}
+
+// Grant returns the video grant for joining room with role r
+func (r Role) Grant(room string) *auth.VideoGrant {
+	grant := &auth.VideoGrant{RoomJoin: true, Room: room}
+
+	switch r {
+	case RoleHost:
+		grant.SetCanPublish(true)
+		grant.SetCanPublishSources(speakerSources)
+		grant.SetCanSubscribe(true)
+		grant.SetCanPublishData(true)
+		grant.SetCanUpdateOwnMetadata(true)
+	case RoleSpeaker:
+		grant.SetCanPublish(true)
+		grant.SetCanPublishSources(speakerSources)
+		grant.SetCanSubscribe(true)
+		grant.SetCanPublishData(true)
+	case RoleViewer:
+		grant.SetCanPublish(false)
+		grant.SetCanSubscribe(true)
+		grant.SetCanPublishData(true)
+	case RoleRecorder:
+		grant.Recorder = true
+		grant.Hidden = true
+		grant.SetCanPublish(false)
+		grant.SetCanSubscribe(true)
+		grant.SetCanPublishData(false)
+	case RoleObserver:
+		grant.Hidden = true
+		grant.SetCanPublish(false)
+		grant.SetCanSubscribe(true)
+		grant.SetCanPublishData(false)
+	default:
+		// Unknown roles may join but do nothing
+		grant.SetCanPublish(false)
+		grant.SetCanSubscribe(false)
+		grant.SetCanPublishData(false)
+	}
+	return grant
+}
+
+// TokenOptions describes an access token to mint
+type TokenOptions struct {
+	Room     string
+	Identity string
+	Name     string
+	TTL      time.Duration
+	// Role selects the grant; DefaultRole when empty
+	Role Role
+	// Metadata and Attributes are visible to the other participants
+	Metadata   string
+	Attributes map[string]string
+	// RoomAdmin lets the token manage the room through the LiveKit API,
+	// which bypasses the backend's ACL checks, so it is only set for the
+	// room's owner
+	RoomAdmin bool
+}
+
+// MintToken creates a signed JWT that lets identity join room
+func (c *Client) MintToken(opts TokenOptions) (string, error) {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	role := opts.Role
+	if role == "" {
+		role = DefaultRole
+	}
+
+	grant := role.Grant(opts.Room)
+	grant.RoomAdmin = opts.RoomAdmin
+
+	at := auth.NewAccessToken(c.APIKey(), c.Secret())
+	at.AddGrant(grant).
+		SetIdentity(opts.Identity).
+		SetName(opts.Name).
+		SetMetadata(opts.Metadata).
+		SetValidFor(ttl)
+	if len(opts.Attributes) > 0 {
+		at.SetAttributes(opts.Attributes)
+	}
+
+	return at.ToJWT()
+}
+
+// TokenPolicy decides which tokens users may mint for themselves. Any
+// user may join with a public role; the other roles are reserved for the
+// room's owner and moderators.
+type TokenPolicy struct {
+	MaxTTL      time.Duration
+	PublicRoles map[Role]bool
+}
+
+// NewTokenPolicy creates the policy described by the LiveKit settings
+func NewTokenPolicy(cfg *config.Config) *TokenPolicy {
+	public := make(map[Role]bool, len(cfg.LivekitPublicRoles))
+	for _, role := range cfg.LivekitPublicRoles {
+		public[Role(role)] = true
+	}
+	return &TokenPolicy{MaxTTL: cfg.LivekitTokenMaxTTL, PublicRoles: public}
+}
+
+// DefaultTTL is the validity of tokens requested without a TTL
+func (p *TokenPolicy) DefaultTTL() time.Duration {
+	return min(DefaultTokenTTL, p.MaxTTL)
+}
+
+// CheckTTL reports whether ttl is within the policy's bounds
+func (p *TokenPolicy) CheckTTL(ttl time.Duration) error {
+	if ttl < MinTokenTTL || ttl > p.MaxTTL {
+		return fmt.Errorf("ttl must be between %s and %s", MinTokenTTL, p.MaxTTL)
+	}
+	return nil
+}
+
+// Allows reports whether userID may join with role in a room with acl.
+// Public roles are open to anyone; the others need the room's owner or a
+// moderator, so a nil acl (no room to read one from) only allows public
+// roles.
+func (p *TokenPolicy) Allows(role Role, userID string, acl *RoomACL) bool {
+	switch {
+	case p.PublicRoles[role]:
+		return true
+	case acl == nil:
+		return false
+	default:
+		return acl.CanModerate(userID)
+	}
+}
@@ -0,0 +1,176 @@
+package lobby
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"myapp/internal/config"
+)
+
+// pollInterval is how often Wait re-reads a ticket, so decisions made on
+// other replicas are noticed without a shared notification channel
+const pollInterval = time.Second
+
+// Lobby holds guests waiting to be admitted to rooms. Each guest gets a
+// pending ticket that a host of the room admits or denies.
+type Lobby struct {
+	store Store
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// NewLobby creates a lobby on top of store whose tickets last ttl, both
+// while pending and after a decision
+func NewLobby(store Store, ttl time.Duration) *Lobby {
+	return &Lobby{store: store, ttl: ttl, waiters: make(map[string][]chan struct{})}
+}
+
+// New creates the lobby described by the lobby settings, shared through
+// Redis when LOBBY_REDIS_URL is set
+func New(cfg *config.Config) (*Lobby, error) {
+	var store Store = NewMemoryStore()
+	if cfg.LobbyRedisURL != "" {
+		redisStore, err := NewRedisStore(cfg.LobbyRedisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	}
+	return NewLobby(store, cfg.LobbyTicketTTL), nil
+}
+
+// Request puts userID in room's lobby. A user already waiting for the room
+// gets their pending ticket back.
+func (l *Lobby) Request(ctx context.Context, room, userID, name string) (*Ticket, error) {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, id); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	return l.store.Create(ctx, &Ticket{
+		ID:        hex.EncodeToString(id),
+		Room:      room,
+		UserID:    userID,
+		Name:      name,
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(l.ttl),
+	})
+}
+
+// Get returns the ticket with id, or ErrNotFound
+func (l *Lobby) Get(ctx context.Context, id string) (*Ticket, error) {
+	return l.store.Get(ctx, id)
+}
+
+// List returns the guests waiting for room, oldest first
+func (l *Lobby) List(ctx context.Context, room string) ([]*Ticket, error) {
+	return l.store.List(ctx, room)
+}
+
+// Admit lets the guest with ticket id into room with role
+func (l *Lobby) Admit(ctx context.Context, room, id, role, decidedBy string) (*Ticket, error) {
+	return l.decide(ctx, room, id, StatusAdmitted, role, decidedBy)
+}
+
+// Deny turns away the guest with ticket id
+func (l *Lobby) Deny(ctx context.Context, room, id, decidedBy string) (*Ticket, error) {
+	return l.decide(ctx, room, id, StatusDenied, "", decidedBy)
+}
+
+// Wait returns the ticket with id once it has been decided, or as it
+// stands after timeout or when ctx is done
+func (l *Lobby) Wait(ctx context.Context, id string, timeout time.Duration) (*Ticket, error) {
+	ticket, err := l.store.Get(ctx, id)
+	if err != nil || ticket.Status != StatusPending || timeout <= 0 {
+		return ticket, err
+	}
+
+	notify := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.waiters[id] = append(l.waiters[id], notify)
+	l.mu.Unlock()
+	defer l.unwait(id, notify)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ticket, nil
+		case <-deadline.C:
+			return ticket, nil
+		case <-notify:
+		case <-poll.C:
+		}
+		current, err := l.store.Get(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ticket, nil
+			}
+			return nil, err
+		}
+		ticket = current
+		if ticket.Status != StatusPending {
+			return ticket, nil
+		}
+	}
+}
+
+// Close releases the store's connections, if it holds any
+func (l *Lobby) Close() error {
+	if closer, ok := l.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (l *Lobby) decide(ctx context.Context, room, id string, status Status, role, decidedBy string) (*Ticket, error) {
+	ticket, err := l.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Room != room {
+		return nil, ErrNotFound
+	}
+	ticket, err = l.store.Decide(ctx, id, status, role, decidedBy, l.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	for _, notify := range l.waiters[id] {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	l.mu.Unlock()
+	return ticket, nil
+}
+
+func (l *Lobby) unwait(id string, notify chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	waiters := l.waiters[id]
+	for i, waiter := range waiters {
+		if waiter == notify {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(l.waiters, id)
+	} else {
+		l.waiters[id] = waiters
+	}
+}
@@ -0,0 +1,105 @@
+package lobby
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store. Tickets are only visible to this
+// process and are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	tickets map[string]*Ticket
+	pending map[string]string // room + "\x00" + user ID -> pending ticket ID
+}
+
+// NewMemoryStore creates an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tickets: make(map[string]*Ticket),
+		pending: make(map[string]string),
+	}
+}
+
+// Create stores a new pending ticket, dropping expired ones
+func (s *MemoryStore) Create(_ context.Context, ticket *Ticket) (*Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range s.tickets {
+		if !now.Before(existing.ExpiresAt) {
+			s.remove(id)
+		}
+	}
+
+	key := ticket.Room + "\x00" + ticket.UserID
+	if id, ok := s.pending[key]; ok {
+		found := *s.tickets[id]
+		return &found, nil
+	}
+	stored := *ticket
+	s.tickets[ticket.ID] = &stored
+	s.pending[key] = ticket.ID
+	return ticket, nil
+}
+
+// Get returns the ticket with id, or ErrNotFound
+func (s *MemoryStore) Get(_ context.Context, id string) (*Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticket, ok := s.tickets[id]
+	if !ok || !time.Now().Before(ticket.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	found := *ticket
+	return &found, nil
+}
+
+// List returns the unexpired pending tickets for room, oldest first
+func (s *MemoryStore) List(_ context.Context, room string) ([]*Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	tickets := []*Ticket{}
+	for _, ticket := range s.tickets {
+		if ticket.Room == room && ticket.Status == StatusPending && now.Before(ticket.ExpiresAt) {
+			found := *ticket
+			tickets = append(tickets, &found)
+		}
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].CreatedAt.Before(tickets[j].CreatedAt) })
+	return tickets, nil
+}
+
+// Decide moves a pending ticket to status
+func (s *MemoryStore) Decide(_ context.Context, id string, status Status, role, decidedBy string, ttl time.Duration) (*Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticket, ok := s.tickets[id]
+	now := time.Now()
+	if !ok || !now.Before(ticket.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	if ticket.Status != StatusPending {
+		return nil, ErrDecided
+	}
+	ticket.decide(status, role, decidedBy, now, ttl)
+	delete(s.pending, ticket.Room+"\x00"+ticket.UserID)
+	decided := *ticket
+	return &decided, nil
+}
+
+func (s *MemoryStore) remove(id string) {
+	ticket := s.tickets[id]
+	delete(s.tickets, id)
+	key := ticket.Room + "\x00" + ticket.UserID
+	if s.pending[key] == id {
+		delete(s.pending, key)
+	}
+}
@@ -0,0 +1,219 @@
+package lobby
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key prefixes namespacing tickets in a shared Redis. Ticket IDs are hex,
+// so they never collide with the indexes.
+const (
+	redisKeyPrefix       = "lobby:"
+	redisRoomIndexPrefix = "lobby:room:"
+	redisPendingPrefix   = "lobby:pending:"
+)
+
+// redisMaxRetries bounds how often a decision is retried when another
+// replica changed the ticket concurrently
+const redisMaxRetries = 10
+
+// RedisStore is a Store shared by every replica through Redis. Tickets
+// expire with Redis key TTLs, and decisions use optimistic transactions so
+// a ticket is only ever decided once.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis server at url
+// (redis://[:password@]host:port/db or rediss:// for TLS)
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Create stores a new pending ticket unless the user already has one for
+// the room
+func (s *RedisStore) Create(ctx context.Context, ticket *Ticket) (*Ticket, error) {
+	ttl := time.Until(ticket.ExpiresAt)
+	if ttl <= 0 {
+		return nil, ErrNotFound
+	}
+	data, err := json.Marshal(ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := pendingKey(ticket.Room, ticket.UserID)
+	claimed, err := s.client.SetNX(ctx, pending, ticket.ID, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		id, err := s.client.Get(ctx, pending).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if existing, err := s.Get(ctx, id); err == nil && existing.Status == StatusPending {
+			return existing, nil
+		}
+		// The previous ticket expired or was decided in between
+		if err := s.client.Set(ctx, pending, ticket.ID, ttl).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	index := redisRoomIndexPrefix + ticket.Room
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisKeyPrefix+ticket.ID, data, ttl)
+		pipe.SAdd(ctx, index, ticket.ID)
+		pipe.PExpire(ctx, index, ttl)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ticket, nil
+}
+
+// Get returns the ticket with id, or ErrNotFound
+func (s *RedisStore) Get(ctx context.Context, id string) (*Ticket, error) {
+	data, err := s.client.Get(ctx, redisKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// List returns the unexpired pending tickets for room, oldest first.
+// Expired and decided tickets are dropped from the index as they are found.
+func (s *RedisStore) List(ctx context.Context, room string) ([]*Ticket, error) {
+	index := redisRoomIndexPrefix + room
+	ids, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := []*Ticket{}
+	if len(ids) == 0 {
+		return tickets, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = redisKeyPrefix + id
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, ids[i])
+			continue
+		}
+		ticket, err := decode([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		if ticket.Status != StatusPending {
+			stale = append(stale, ids[i])
+			continue
+		}
+		tickets = append(tickets, ticket)
+	}
+	if len(stale) > 0 {
+		s.client.SRem(ctx, index, stale...)
+	}
+
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].CreatedAt.Before(tickets[j].CreatedAt) })
+	return tickets, nil
+}
+
+// Decide moves a pending ticket to status, retrying when the ticket
+// changed in between
+func (s *RedisStore) Decide(ctx context.Context, id string, status Status, role, decidedBy string, ttl time.Duration) (*Ticket, error) {
+	key := redisKeyPrefix + id
+	var decided *Ticket
+
+	txn := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		ticket, err := decode(data)
+		if err != nil {
+			return err
+		}
+		if ticket.Status != StatusPending {
+			return ErrDecided
+		}
+		ticket.decide(status, role, decidedBy, time.Now(), ttl)
+		if data, err = json.Marshal(ticket); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, ttl)
+			pipe.SRem(ctx, redisRoomIndexPrefix+ticket.Room, id)
+			pipe.Del(ctx, pendingKey(ticket.Room, ticket.UserID))
+			return nil
+		})
+		decided = ticket
+		return err
+	}
+
+	for i := 0; i < redisMaxRetries; i++ {
+		err := s.client.Watch(ctx, txn, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return decided, nil
+	}
+	return nil, fmt.Errorf("ticket %s: too many concurrent updates", id)
+}
+
+// Ping checks the connection to Redis
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the connection pool
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// pendingKey names the marker of a user's pending ticket for a room. The
+// pair is hashed since room names and user IDs may contain any character.
+func pendingKey(room, userID string) string {
+	sum := sha256.Sum256([]byte(room + "\x00" + userID))
+	return redisPendingPrefix + hex.EncodeToString(sum[:])
+}
+
+func decode(data []byte) (*Ticket, error) {
+	var ticket Ticket
+	if err := json.Unmarshal(data, &ticket); err != nil {
+		return nil, fmt.Errorf("decode ticket: %w", err)
+	}
+	return &ticket, nil
+}
@@ -0,0 +1,64 @@
+package lobby
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for tickets that do not exist or have expired
+	ErrNotFound = errors.New("ticket not found")
+	// ErrDecided is returned when admitting or denying a ticket that has
+	// already been admitted or denied
+	ErrDecided = errors.New("ticket already decided")
+)
+
+// Status is where a ticket stands
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusAdmitted Status = "admitted"
+	StatusDenied   Status = "denied"
+)
+
+// Ticket is a user's request to enter a room, waiting for a host
+type Ticket struct {
+	ID        string    `json:"id"`
+	Room      string    `json:"room"`
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name,omitempty"`
+	Status    Status    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Role and DecidedBy are set once a host has admitted or denied it
+	Role      string     `json:"role,omitempty"`
+	DecidedBy string     `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+}
+
+// decide records a host's decision and keeps the ticket for ttl after it
+func (t *Ticket) decide(status Status, role, decidedBy string, now time.Time, ttl time.Duration) {
+	t.Status = status
+	t.Role = role
+	t.DecidedBy = decidedBy
+	decidedAt := now.UTC().Truncate(time.Second)
+	t.DecidedAt = &decidedAt
+	t.ExpiresAt = now.Add(ttl).UTC().Truncate(time.Second)
+}
+
+// Store holds tickets until they expire. Implementations must be safe for
+// concurrent use, and Decide must be atomic.
+type Store interface {
+	// Create stores a new pending ticket, or returns the user's pending
+	// ticket for the same room if there is one
+	Create(ctx context.Context, ticket *Ticket) (*Ticket, error)
+	// Get returns the ticket with id, or ErrNotFound
+	Get(ctx context.Context, id string) (*Ticket, error)
+	// List returns the unexpired pending tickets for room, oldest first
+	List(ctx context.Context, room string) ([]*Ticket, error)
+	// Decide moves a pending ticket to status, keeping it for ttl so the
+	// guest can collect the outcome, and returns it updated
+	Decide(ctx context.Context, id string, status Status, role, decidedBy string, ttl time.Duration) (*Ticket, error)
+}
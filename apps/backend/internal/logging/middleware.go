@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// loggerKey is the echo context key holding the request logger
+const loggerKey = "logger"
+
+// Middleware assigns every request an ID (reusing a valid incoming
+// X-Request-ID), attaches a request-scoped logger carrying the request ID
+// and route, and writes one access log record per request
+func Middleware(base *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if requestID == "" || len(requestID) > 128 {
+				requestID = newRequestID()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+			logger := base.With(
+				slog.String("request_id", requestID),
+				slog.String("method", req.Method),
+				slog.String("route", c.Path()),
+			)
+			if span := trace.SpanContextFromContext(req.Context()); span.IsValid() {
+				logger = logger.With(slog.String("trace_id", span.TraceID().String()))
+			}
+			c.Set(loggerKey, logger)
+
+			err := next(c)
+			if err != nil {
+				// Let echo write the error response so the status is known
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			level := slog.LevelInfo
+			switch {
+			case status >= 500:
+				level = slog.LevelError
+			case status >= 400:
+				level = slog.LevelWarn
+			}
+
+			attrs := []slog.Attr{
+				slog.String("path", req.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_ip", c.RealIP()),
+				slog.Int64("bytes_in", req.ContentLength),
+				slog.Int64("bytes_out", c.Response().Size),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			For(c, base).LogAttrs(req.Context(), level, "request", attrs...)
+			return nil
+		}
+	}
+}
+
+// For returns the request-scoped logger, or fallback outside a request
+func For(c echo.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := c.Get(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// With adds attributes, such as the authenticated user ID, to the request
+// logger for the rest of the request
+func With(c echo.Context, attrs ...any) {
+	if logger, ok := c.Get(loggerKey).(*slog.Logger); ok {
+		c.Set(loggerKey, logger.With(attrs...))
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
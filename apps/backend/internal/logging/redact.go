@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute (and header) names whose values are never
+// logged, compared case-insensitively
+var sensitiveKeys = map[string]struct{}{
+	"authorization":       {},
+	"proxy-authorization": {},
+	"cookie":              {},
+	"set-cookie":          {},
+	"x-api-key":           {},
+	"x-service-identity":  {},
+	"api_key":             {},
+	"apikey":              {},
+	"token":               {},
+	"secret":              {},
+	"password":            {},
+	"hash":                {},
+	"keyhash":             {},
+	"signature":           {},
+}
+
+// sensitiveSuffixes catch names like key_hash, access_token or api_secret
+var sensitiveSuffixes = []string{"_hash", "_token", "_secret", "_password", "-token", "-signature"}
+
+// Redact is a slog ReplaceAttr function that masks secrets: attributes with
+// sensitive names (including HTTP headers logged with Headers) and string
+// values that look like credentials, such as bearer tokens and JWTs
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if a.Value.Kind() == slog.KindString && looksLikeCredential(a.Value.String()) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := sensitiveKeys[key]; ok {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func looksLikeCredential(value string) bool {
+	lower := strings.ToLower(value)
+	if strings.HasPrefix(lower, "bearer ") || strings.HasPrefix(lower, "basic ") || strings.HasPrefix(lower, "client-id ") {
+		return true
+	}
+	// JWTs: three dot-separated segments with a JSON header
+	return strings.HasPrefix(value, "eyJ") && strings.Count(value, ".") == 2
+}
+
+// Headers returns h as a log group. Sensitive headers are redacted by the
+// handler like any other attribute.
+func Headers(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for name, values := range h {
+		attrs = append(attrs, slog.String(name, strings.Join(values, ", ")))
+	}
+	return slog.Group("headers", attrs...)
+}
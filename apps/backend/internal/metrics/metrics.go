@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric exposed on /metrics. A dedicated registry
+// keeps out anything dependencies register on the global one.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	sessionCacheLookups = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "session_cache_lookups_total",
+		Help: "Session cache lookups by result (hit, negative_hit, revoked, miss or error).",
+	}, []string{"result"})
+
+	rateLimitRejections = factory.NewCounter(prometheus.CounterOpts{
+		Name: "api_rate_limit_rejections_total",
+		Help: "API hub requests rejected by the per-key rate limit.",
+	})
+
+	uploadBytes = factory.NewCounter(prometheus.CounterOpts{
+		Name: "storage_upload_bytes_total",
+		Help: "Bytes successfully uploaded to storage.",
+	})
+
+	webhookJobs = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_handler_runs_total",
+		Help: "Webhook handler runs by handler and outcome (success, retried, failed or dropped).",
+	}, []string{"handler", "outcome"})
+
+	webhookQueueDepth = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_queue_depth",
+		Help: "Webhook handler runs waiting for a worker.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+}
+
+// SessionCacheLookup records a session cache lookup: "hit" for a cached
+// valid session, "negative_hit" for a cached invalid one, "revoked" for a
+// cached session whose user has since been revoked, "miss", or "error"
+// when the store could not be read
+func SessionCacheLookup(result string) {
+	sessionCacheLookups.WithLabelValues(result).Inc()
+}
+
+// RateLimitRejected records a request rejected by the rate limiter
+func RateLimitRejected() {
+	rateLimitRejections.Inc()
+}
+
+// UploadedBytes records n bytes stored by an upload
+func UploadedBytes(n int64) {
+	uploadBytes.Add(float64(n))
+}
+
+// WebhookJob records the outcome of a webhook handler run: "success",
+// "retried" when it failed and will run again, "failed" when it gave up, or
+// "dropped" when the queue was full
+func WebhookJob(handler, outcome string) {
+	webhookJobs.WithLabelValues(handler, outcome).Inc()
+}
+
+// WebhookQueueDepth records how many webhook handler runs are queued
+func WebhookQueueDepth(n int) {
+	webhookQueueDepth.Set(float64(n))
+}
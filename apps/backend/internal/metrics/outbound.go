@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twitchtv/twirp"
+)
+
+var (
+	outboundRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbound_requests_total",
+		Help: "Calls to external services by service, operation and outcome.",
+	}, []string{"service", "operation", "outcome"})
+
+	outboundDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "outbound_request_duration_seconds",
+		Help:    "Latency of calls to external services.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+)
+
+// ObserveOutbound records one call to an external service. The outcome is
+// "error" when err is non-nil and "success" otherwise.
+func ObserveOutbound(service, operation string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	outboundRequests.WithLabelValues(service, operation, outcome).Inc()
+	outboundDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+}
+
+// Transport instruments an HTTP client. operation names the call for the
+// metric labels; when nil the URL path is used, so it must be supplied for
+// services whose paths contain IDs. Responses with a 5xx status count as
+// errors. A nil next uses http.DefaultTransport.
+func Transport(service string, operation func(*http.Request) string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if operation == nil {
+		operation = func(r *http.Request) string { return r.URL.Path }
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(r)
+		outcomeErr := err
+		if err == nil && resp.StatusCode >= 500 {
+			outcomeErr = errServerStatus
+		}
+		ObserveOutbound(service, operation(r), start, outcomeErr)
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// errServerStatus marks 5xx responses as failed calls
+var errServerStatus = errors.New("server error status")
+
+type startKey struct{}
+
+// TwirpHooks instruments a Twirp client such as the LiveKit RoomService,
+// labelling calls with the Twirp method name
+func TwirpHooks(service string) *twirp.ClientHooks {
+	observe := func(ctx context.Context, err error) {
+		start, ok := ctx.Value(startKey{}).(time.Time)
+		if !ok {
+			// Failed before the request was sent
+			start = time.Now()
+		}
+		method, _ := twirp.MethodName(ctx)
+		ObserveOutbound(service, method, start, err)
+	}
+	return &twirp.ClientHooks{
+		RequestPrepared: func(ctx context.Context, _ *http.Request) (context.Context, error) {
+			return context.WithValue(ctx, startKey{}, time.Now()), nil
+		},
+		ResponseReceived: func(ctx context.Context) {
+			observe(ctx, nil)
+		},
+		Error: func(ctx context.Context, err twirp.Error) {
+			observe(ctx, err)
+		},
+	}
+}
+
+// AWSMiddleware instruments an AWS SDK client such as the R2 S3 client,
+// labelling calls with the API operation name. Retries are included in the
+// measured latency. Add it through the client's APIOptions.
+func AWSMiddleware(service string) func(*smithymiddleware.Stack) error {
+	return func(stack *smithymiddleware.Stack) error {
+		return stack.Initialize.Add(smithymiddleware.InitializeMiddlewareFunc("Metrics",
+			func(ctx context.Context, in smithymiddleware.InitializeInput, next smithymiddleware.InitializeHandler) (smithymiddleware.InitializeOutput, smithymiddleware.Metadata, error) {
+				start := time.Now()
+				out, md, err := next.HandleInitialize(ctx, in)
+				ObserveOutbound(service, awsmiddleware.GetOperationName(ctx), start, err)
+				return out, md, err
+			}), smithymiddleware.After)
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminTokenMiddleware requires the static admin token as a bearer token
+func AdminTokenMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			presented := strings.TrimPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+			if presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "admin token required",
+				})
+			}
+			return next(c)
+		}
+	}
+}
@@ -1,32 +1,52 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"myapp/internal/logging"
+	"myapp/internal/metrics"
+	"myapp/internal/serviceauth"
+	"myapp/internal/session"
+	"myapp/internal/tracing"
+
 	"github.com/labstack/echo/v4"
 )
 
 // AuthConfig holds configuration for the auth middleware
 type AuthConfig struct {
 	ConvexURL string
+	// Sessions caches Convex session checks; nil checks every request
+	Sessions *session.Cache
+	// Tokens verifies Convex Auth JWTs locally; nil leaves them to Convex
+	Tokens *TokenVerifier
+	// Services verifies signed service identities; nil rejects them
+	Services *serviceauth.Verifier
+	// TrustUserIDHeader accepts a raw X-User-ID header as the user.
+	// Anyone can set it, so it is only for local development.
+	TrustUserIDHeader bool
+	Logger            *slog.Logger
 }
 
 // UserInfo represents the authenticated user
 type UserInfo struct {
 	UserID string `json:"userId"`
 	Email  string `json:"email,omitempty"`
+	// SessionID and IssuedAt are known for verified tokens and are
+	// checked against the revocation deny list
+	SessionID string    `json:"-"`
+	IssuedAt  time.Time `json:"-"`
 }
 
-// Simple in-memory cache for session validation (use Redis in production)
-var sessionCache = make(map[string]*cachedSession)
-
-type cachedSession struct {
-	user      *UserInfo
-	expiresAt time.Time
+// sessionClient calls the Convex session validation endpoint
+var sessionClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: tracing.Transport("convex", nil, metrics.Transport("convex", nil, nil)),
 }
 
 // AuthMiddleware validates user authentication via Convex session
@@ -34,15 +54,70 @@ func AuthMiddleware(cfg AuthConfig) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			var userID string
+			// Known for tokens and assertions, to check the deny list
+			var sessionID string
+			var issuedAt time.Time
+			logger := logging.For(c, cfg.Logger)
+
+			// Method 1: Signed identity asserted by an internal service.
+			// A bad assertion fails the request rather than falling back.
+			if c.Request().Header.Get(serviceauth.Header) != "" {
+				if cfg.Services == nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "service identities are not accepted",
+					})
+				}
+				identity, err := cfg.Services.Verify(c.Request())
+				if err != nil {
+					logger.Warn("service identity rejected", "error", err, "remote_addr", c.Request().RemoteAddr)
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "invalid service identity",
+					})
+				}
+				userID = identity.UserID
+				issuedAt = identity.IssuedAt
+				logging.With(c, "service", identity.Service)
+			}
 
-			// Method 1: Check X-User-ID header (trusted internal services only)
-			// In production, this should only be allowed from trusted internal IPs
-			userID = c.Request().Header.Get("X-User-ID")
+			// Method 2: Raw X-User-ID header, local development only
+			if header := c.Request().Header.Get("X-User-ID"); userID == "" && header != "" {
+				if cfg.TrustUserIDHeader {
+					userID = header
+				} else {
+					logger.Debug("ignoring untrusted X-User-ID header")
+				}
+			}
 
-			// Method 2: Validate session with Convex by forwarding cookies
+			// Method 3: Convex Auth JWT as a bearer token, verified locally.
+			// Bearer requests never fall back to Convex.
+			if token := bearerToken(c.Request()); userID == "" && token != "" && cfg.Tokens != nil {
+				user, err := cfg.Tokens.Verify(c.Request().Context(), token)
+				if err != nil {
+					logger.Debug("authorization token rejected", "error", err)
+					return c.JSON(http.StatusUnauthorized, map[string]string{
+						"error": "invalid token",
+					})
+				}
+				userID, sessionID, issuedAt = user.UserID, user.SessionID, user.IssuedAt
+			}
+
+			// Method 4: Convex Auth JWT cookie, verified locally. An expired
+			// or invalid cookie falls through to the session check below.
+			if token := jwtCookie(c.Request()); userID == "" && token != "" && cfg.Tokens != nil {
+				user, err := cfg.Tokens.Verify(c.Request().Context(), token)
+				if err != nil {
+					logger.Debug("session cookie token rejected", "error", err)
+				} else {
+					userID, sessionID, issuedAt = user.UserID, user.SessionID, user.IssuedAt
+				}
+			}
+
+			// Method 5: Validate session with Convex by forwarding cookies
 			if userID == "" && cfg.ConvexURL != "" {
-				user, err := validateSessionWithConvex(cfg.ConvexURL, c.Request())
-				if err == nil && user != nil {
+				user, err := validateSessionWithConvex(cfg.Sessions, cfg.ConvexURL, c.Request())
+				if err != nil {
+					logger.Debug("session validation failed", "error", err)
+				} else if user != nil {
 					userID = user.UserID
 				}
 			}
@@ -53,44 +128,80 @@ func AuthMiddleware(cfg AuthConfig) echo.MiddlewareFunc {
 				})
 			}
 
+			// Reject tokens issued before the user or session was revoked.
+			// Cached Convex sessions are checked by the cache itself.
+			if !issuedAt.IsZero() && cfg.Sessions != nil && cfg.Sessions.Revoked(c.Request().Context(), userID, sessionID, issuedAt) {
+				logger.Info("revoked credentials rejected", "user_id", userID)
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "session revoked",
+				})
+			}
+
 			// Store user ID in context
 			c.Set("userId", userID)
+			logging.With(c, "user_id", userID)
 
 			return next(c)
 		}
 	}
 }
 
-// validateSessionWithConvex validates the session by forwarding cookies to Convex
-func validateSessionWithConvex(convexURL string, originalReq *http.Request) (*UserInfo, error) {
+// validateSessionWithConvex validates the session by forwarding cookies to
+// Convex, caching the outcome in sessions when it is set
+func validateSessionWithConvex(sessions *session.Cache, convexURL string, originalReq *http.Request) (*UserInfo, error) {
 	// Get all cookies from the original request
 	cookies := originalReq.Cookies()
 	if len(cookies) == 0 {
 		return nil, fmt.Errorf("no cookies found")
 	}
 
-	// Build cache key from relevant auth cookies
-	cacheKey := buildCacheKey(cookies)
-	
-	// Check cache first
-	if cached, ok := sessionCache[cacheKey]; ok {
-		if time.Now().Before(cached.expiresAt) {
-			return cached.user, nil
+	validate := func(ctx context.Context) (*session.Entry, error) {
+		return checkSessionWithConvex(ctx, convexURL, originalReq)
+	}
+
+	var entry *session.Entry
+	var err error
+	if sessions == nil {
+		entry, err = validate(originalReq.Context())
+		if err == nil && !entry.Valid {
+			err = session.ErrInvalid
+		}
+	} else {
+		// Cache by the credentials Convex sees. Without any there is
+		// nothing to tell sessions apart, so don't share one entry.
+		credentials := buildCacheKey(cookies)
+		if auth := originalReq.Header.Get("Authorization"); auth != "" {
+			credentials += ";authorization=" + auth
 		}
-		// Cache expired, remove it
-		delete(sessionCache, cacheKey)
+		if credentials == "" {
+			return nil, fmt.Errorf("no session cookies found")
+		}
+		entry, err = sessions.Lookup(originalReq.Context(), credentials, validate)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserInfo{
+		UserID: entry.UserID,
+		Email:  entry.Email,
+	}, nil
+}
 
+// checkSessionWithConvex asks Convex whether the request's session is
+// valid. A rejected session is reported as an invalid entry; transport and
+// server errors are returned so they are not cached.
+func checkSessionWithConvex(ctx context.Context, convexURL string, originalReq *http.Request) (*session.Entry, error) {
 	// Create request to Convex validate-session endpoint
-	req, err := http.NewRequest("POST", convexURL+"/api/validate-session", strings.NewReader("{}"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, convexURL+"/api/validate-session", strings.NewReader("{}"))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	
+
 	// Forward all cookies from the original request
-	for _, cookie := range cookies {
+	for _, cookie := range originalReq.Cookies() {
 		req.AddCookie(cookie)
 	}
 
@@ -99,11 +210,9 @@ func validateSessionWithConvex(convexURL string, originalReq *http.Request) (*Us
 		req.Header.Set("Authorization", auth)
 	}
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := sessionClient.Do(req)
 	if err != nil {
-		fmt.Printf("Convex session validation request failed: %v\n", err)
-		return nil, err
+		return nil, fmt.Errorf("convex session validation request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -122,21 +231,13 @@ func validateSessionWithConvex(convexURL string, originalReq *http.Request) (*Us
 	}
 
 	if !result.Valid || result.UserID == "" {
-		return nil, fmt.Errorf("invalid session")
+		return &session.Entry{Valid: false}, nil
 	}
-
-	user := &UserInfo{
+	return &session.Entry{
+		Valid:  true,
 		UserID: result.UserID,
 		Email:  result.Email,
-	}
-
-	// Cache the result for 5 minutes
-	sessionCache[cacheKey] = &cachedSession{
-		user:      user,
-		expiresAt: time.Now().Add(5 * time.Minute),
-	}
-
-	return user, nil
+	}, nil
 }
 
 // buildCacheKey creates a cache key from auth-related cookies
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"myapp/internal/session"
+
+	"github.com/labstack/echo/v4"
+)
+
+// fakeConvex stands in for the Convex validate-session endpoint. Every
+// session cookie value is valid for the user of the same name, except
+// "bad", and each call is counted per session.
+type fakeConvex struct {
+	*httptest.Server
+	delay time.Duration
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newFakeConvex(t *testing.T, delay time.Duration) *fakeConvex {
+	f := &fakeConvex{delay: delay, calls: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/validate-session" {
+			http.NotFound(w, r)
+			return
+		}
+		cookie, err := r.Cookie("__session")
+		if err != nil {
+			http.Error(w, "no session", http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.calls[cookie.Value]++
+		f.mu.Unlock()
+
+		time.Sleep(f.delay)
+		valid := cookie.Value != "bad"
+		json.NewEncoder(w).Encode(map[string]any{"valid": valid, "userId": cookie.Value})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeConvex) callsFor(sessionID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[sessionID]
+}
+
+// barrierStore holds the first n reads until all n have missed, so they
+// reach the validation together
+type barrierStore struct {
+	session.Store
+	n       int32
+	reads   atomic.Int32
+	arrived sync.WaitGroup
+}
+
+func newBarrierStore(store session.Store, n int) *barrierStore {
+	s := &barrierStore{Store: store, n: int32(n)}
+	s.arrived.Add(n)
+	return s
+}
+
+func (s *barrierStore) Get(ctx context.Context, key string) (*session.Entry, error) {
+	entry, err := s.Store.Get(ctx, key)
+	if s.reads.Add(1) <= s.n {
+		s.arrived.Done()
+		s.arrived.Wait()
+	}
+	return entry, err
+}
+
+func TestConvexSessionConcurrentMissesShareOneCall(t *testing.T) {
+	const requests = 20
+	convex := newFakeConvex(t, 100*time.Millisecond)
+	store := newBarrierStore(session.NewMemoryStore(100), requests)
+	auth := testAuth(convex.URL, store)
+
+	var wg sync.WaitGroup
+	results := make(chan string, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- serve(auth, "alice")
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	for got := range results {
+		if got != "200 alice" {
+			t.Errorf("response = %q, want 200 alice", got)
+		}
+	}
+	if got := convex.callsFor("alice"); got != 1 {
+		t.Errorf("Convex calls = %d for %d concurrent misses, want 1", got, requests)
+	}
+
+	// Later requests are served from the cache
+	if got := serve(auth, "alice"); got != "200 alice" {
+		t.Errorf("cached response = %q, want 200 alice", got)
+	}
+	if got := convex.callsFor("alice"); got != 1 {
+		t.Errorf("Convex calls = %d after a cache hit, want 1", got)
+	}
+}
+
+func TestConvexSessionRejectionIsCached(t *testing.T) {
+	convex := newFakeConvex(t, 0)
+	auth := testAuth(convex.URL, session.NewMemoryStore(100))
+
+	for i := 0; i < 3; i++ {
+		if got := serve(auth, "bad"); got != "401" {
+			t.Fatalf("response = %q, want 401", got)
+		}
+	}
+	if got := convex.callsFor("bad"); got != 1 {
+		t.Errorf("Convex calls = %d for a rejected session, want 1", got)
+	}
+}
+
+func TestConvexSessionCacheEviction(t *testing.T) {
+	const size = 2
+	convex := newFakeConvex(t, 0)
+	store := session.NewMemoryStore(size)
+	auth := testAuth(convex.URL, store)
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if got := serve(auth, user); got != "200 "+user {
+			t.Fatalf("response = %q, want 200 %s", got, user)
+		}
+	}
+	if got := store.Len(); got != size {
+		t.Fatalf("cached sessions = %d, want %d", got, size)
+	}
+
+	// bob and carol are still cached; alice was evicted and is checked
+	// with Convex again
+	serve(auth, "bob")
+	serve(auth, "carol")
+	serve(auth, "alice")
+	for user, want := range map[string]int{"alice": 2, "bob": 1, "carol": 1} {
+		if got := convex.callsFor(user); got != want {
+			t.Errorf("Convex calls for %s = %d, want %d", user, got, want)
+		}
+	}
+	if got := store.Len(); got != size {
+		t.Errorf("cached sessions = %d, want %d", got, size)
+	}
+}
+
+func testAuth(convexURL string, store session.Store) echo.HandlerFunc {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions := session.NewCache(store, time.Minute, time.Minute, time.Hour, logger)
+	return AuthMiddleware(AuthConfig{ConvexURL: convexURL, Sessions: sessions, Logger: logger})(func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("userId").(string))
+	})
+}
+
+// serve runs a request with sessionID as its session cookie and returns
+// the status, followed by the user ID when authenticated
+func serve(h echo.HandlerFunc, sessionID string) string {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "__session", Value: sessionID})
+	rec := httptest.NewRecorder()
+	if err := h(echo.New().NewContext(req, rec)); err != nil {
+		return err.Error()
+	}
+	if rec.Code != http.StatusOK {
+		return strconv.Itoa(rec.Code)
+	}
+	return strconv.Itoa(rec.Code) + " " + rec.Body.String()
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"myapp/internal/background"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TrackInFlight registers every request with the tracker so graceful
+// shutdown can report requests that did not finish in time
+func TrackInFlight(tasks *background.Tracker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			done := tasks.Track("request " + c.Request().Method + " " + c.Request().URL.Path)
+			defer done()
+			return next(c)
+		}
+	}
+}
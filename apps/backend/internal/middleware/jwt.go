@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"myapp/internal/config"
+	"myapp/internal/metrics"
+	"myapp/internal/tracing"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// jwtCookieSuffix matches the Convex Auth JWT cookie, which is prefixed
+// with "__Host-" outside localhost
+const jwtCookieSuffix = "__convexAuthJWT"
+
+// jwksRetryInterval limits refetches triggered by unknown key IDs or failed
+// fetches, so bad tokens cannot be used to hammer the JWKS endpoint
+const jwksRetryInterval = 30 * time.Second
+
+// jwtLeeway tolerates clock skew between Convex and the backend
+const jwtLeeway = 30 * time.Second
+
+// jwtAlgorithms are the signature algorithms Convex Auth tokens may use
+var jwtAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.ES256): true,
+	string(jose.EdDSA): true,
+}
+
+var errUnknownJWTKey = errors.New("unknown signing key")
+
+// jwksClient fetches signing keys from Convex
+var jwksClient = &http.Client{
+	Timeout:   5 * time.Second,
+	Transport: tracing.Transport("convex", nil, metrics.Transport("convex", nil, nil)),
+}
+
+// TokenVerifier validates Convex Auth JWTs locally against the keys
+// published at the deployment's JWKS endpoint, as configured in
+// convex/auth.config.ts (domain is the issuer, applicationID the audience).
+// Keys are refetched every refresh interval and whenever a token names an
+// unknown key ID; when a fetch fails the previous keys are kept, so
+// verification keeps working through brief Convex outages.
+type TokenVerifier struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	refresh  time.Duration
+	// maxTTL bounds exp - iat, so a revocation outlives the tokens it
+	// rejects
+	maxTTL time.Duration
+
+	fetchMu sync.Mutex // serializes fetches
+
+	mu          sync.RWMutex
+	keys        jose.JSONWebKeySet
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewTokenVerifier creates a verifier from the Convex Auth settings, or
+// returns nil when local verification is not configured
+func NewTokenVerifier(cfg *config.Config) *TokenVerifier {
+	if !cfg.ConvexAuthEnabled() {
+		return nil
+	}
+	return &TokenVerifier{
+		issuer:   cfg.ConvexAuthIssuer,
+		audience: cfg.ConvexAuthAudience,
+		jwksURL:  cfg.ConvexAuthJWKSURL,
+		refresh:  cfg.ConvexAuthJWKSRefresh,
+		maxTTL:   cfg.ConvexAuthMaxTokenTTL,
+	}
+}
+
+// Verify validates a raw JWT and returns the user it was issued to
+func (v *TokenVerifier) Verify(ctx context.Context, raw string) (*UserInfo, error) {
+	token, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	if len(token.Headers) != 1 {
+		return nil, fmt.Errorf("token must have exactly one signature")
+	}
+	header := token.Headers[0]
+	if !jwtAlgorithms[header.Algorithm] {
+		return nil, fmt.Errorf("algorithm %q is not accepted", header.Algorithm)
+	}
+
+	key, err := v.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+		return nil, fmt.Errorf("algorithm %q does not match key %q", header.Algorithm, header.KeyID)
+	}
+
+	var claims jwt.Claims
+	if err := token.Claims(key.Key, &claims); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	if claims.Expiry == nil || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("token must carry iat and exp")
+	}
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:   v.issuer,
+		Audience: jwt.Audience{v.audience},
+		Time:     time.Now(),
+	}, jwtLeeway)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Expiry.Time().Sub(claims.IssuedAt.Time()) > v.maxTTL {
+		return nil, fmt.Errorf("token lifetime exceeds %s", v.maxTTL)
+	}
+
+	// Convex Auth subjects are "<userId>|<sessionId>"
+	userID, sessionID, _ := strings.Cut(claims.Subject, "|")
+	if userID == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+	return &UserInfo{
+		UserID:    userID,
+		SessionID: sessionID,
+		IssuedAt:  claims.IssuedAt.Time(),
+	}, nil
+}
+
+// key returns the signing key with the given ID, fetching the key set
+// when it is stale or does not contain the key
+func (v *TokenVerifier) key(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	if key, fresh := v.cached(kid); key != nil && fresh {
+		return key, nil
+	}
+
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	// Another request may have refreshed the keys while we waited
+	key, fresh := v.cached(kid)
+	if key != nil && fresh {
+		return key, nil
+	}
+
+	v.mu.RLock()
+	recent := time.Since(v.lastAttempt) < jwksRetryInterval
+	v.mu.RUnlock()
+	if recent {
+		if key != nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("%w %q", errUnknownJWTKey, kid)
+	}
+
+	keys, err := fetchJWKS(ctx, v.jwksURL)
+
+	v.mu.Lock()
+	v.lastAttempt = time.Now()
+	if err == nil {
+		v.keys = *keys
+		v.fetchedAt = v.lastAttempt
+	}
+	v.mu.Unlock()
+
+	if err != nil {
+		// Serve the previous keys rather than failing while Convex is down
+		if key != nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("fetch signing keys: %w", err)
+	}
+	if key, _ := v.cached(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownJWTKey, kid)
+}
+
+// cached looks up a key in the current key set and reports whether the
+// set is still within its refresh interval
+func (v *TokenVerifier) cached(kid string) (*jose.JSONWebKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	fresh := !v.fetchedAt.IsZero() && time.Since(v.fetchedAt) < v.refresh
+	for i := range v.keys.Keys {
+		key := &v.keys.Keys[i]
+		if key.KeyID == kid && key.Use != "enc" && key.IsPublic() {
+			return key, fresh
+		}
+	}
+	return nil, fresh
+}
+
+// fetchJWKS downloads and decodes a JSON Web Key Set
+func fetchJWKS(ctx context.Context, url string) (*jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := jwksClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&keys); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+	return &keys, nil
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// jwtCookie returns the Convex Auth JWT cookie value, if present
+func jwtCookie(r *http.Request) string {
+	for _, cookie := range r.Cookies() {
+		if strings.HasSuffix(cookie.Name, jwtCookieSuffix) && cookie.Value != "" {
+			return cookie.Value
+		}
+	}
+	return ""
+}
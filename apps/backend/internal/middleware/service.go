@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"myapp/internal/logging"
+	"myapp/internal/serviceauth"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ServiceIdentityMiddleware requires a signed service identity, for
+// internal endpoints only other services may call. The asserted user is
+// stored as "userId" and the calling service as "service".
+func ServiceIdentityMiddleware(services *serviceauth.Verifier, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			identity, err := services.Verify(c.Request())
+			if err != nil {
+				logging.For(c, logger).Warn("service identity rejected", "error", err, "remote_addr", c.Request().RemoteAddr)
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "service identity required",
+				})
+			}
+
+			c.Set("userId", identity.UserID)
+			c.Set("service", identity.Service)
+			logging.With(c, "service", identity.Service, "user_id", identity.UserID)
+			return next(c)
+		}
+	}
+}
+
+// RequireServices only lets through callers whose service identity, set by
+// ServiceIdentityMiddleware, was signed with one of the given keys. It
+// guards endpoints that act on any user rather than on the asserted one.
+func RequireServices(services []string, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			service, _ := c.Get("service").(string)
+			if !slices.Contains(services, service) {
+				logging.For(c, logger).Warn("service not allowed", "path", c.Path())
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "service not allowed",
+				})
+			}
+			return next(c)
+		}
+	}
+}
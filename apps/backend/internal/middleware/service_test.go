@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"myapp/internal/config"
+	"myapp/internal/serviceauth"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequireServices(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	cfg := &config.Config{
+		ServiceAuthKeys:     "revoker=hs256:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=,worker=hs256:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		ServiceAuthAudience: "backend",
+		ServiceAuthMaxTTL:   5 * time.Minute,
+	}
+	verifier, err := serviceauth.NewVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	e := echo.New()
+	e.POST("/revoke", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, ServiceIdentityMiddleware(verifier, logger), RequireServices([]string{"revoker"}, logger))
+
+	tests := []struct {
+		name    string
+		service string
+		user    string
+		want    int
+	}{
+		{"revoker for any user", "revoker", "svc-revoker", http.StatusNoContent},
+		{"other service", "worker", "user-1", http.StatusForbidden},
+		{"no assertion", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/revoke", nil)
+			if tt.service != "" {
+				assertion, err := serviceauth.Sign(config.ServiceKey{ID: tt.service, Algorithm: "HS256", Key: secret}, secret, tt.user, "backend", time.Minute)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set(serviceauth.Header, assertion)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -1,71 +1,212 @@
 package router
 
 import (
+	"log/slog"
 	"net/http"
+	"time"
 
+	"myapp/internal/background"
 	"myapp/internal/config"
+	"myapp/internal/events"
 	"myapp/internal/handler"
+	"myapp/internal/health"
+	"myapp/internal/invite"
 	"myapp/internal/livekit"
+	"myapp/internal/lobby"
+	"myapp/internal/logging"
+	"myapp/internal/metrics"
 	"myapp/internal/middleware"
+	"myapp/internal/serviceauth"
+	"myapp/internal/session"
 	"myapp/internal/storage"
+	"myapp/internal/tracing"
+	"myapp/internal/webhooks"
 
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 )
 
-func Setup(e *echo.Echo, client *livekit.Client, r2 *storage.R2Client, cfg *config.Config) {
+// Deps bundles the shared services the routes are built from
+type Deps struct {
+	Client *livekit.Client
+	// R2 is nil when storage is not configured
+	R2     *storage.R2Client
+	Config *config.Config
+	// Settings serves the configuration that can change at runtime
+	Settings *config.Reloader
+	// Tasks tracks in-flight requests and background work for shutdown
+	Tasks *background.Tracker
+	// Services verifies signed service identities; nil when service auth
+	// is not configured
+	Services *serviceauth.Verifier
+	// Sessions caches Convex session validation
+	Sessions *session.Cache
+	// Invites manages meeting invites; nil when invites are not configured
+	Invites *invite.Manager
+	// Lobby holds guests waiting to be admitted to rooms
+	Lobby *lobby.Lobby
+	// Events keeps verified webhook events for session history
+	Events *events.Store
+	// Feed streams live webhook events to subscribed clients
+	Feed *events.Feed
+	// Webhooks runs the handlers subscribed to webhook events
+	Webhooks *webhooks.Dispatcher
+	// Traces holds recent spans when the memory trace exporter is
+	// selected, and is nil otherwise
+	Traces *tracing.Recorder
+	// Logger is the base logger; requests get a child carrying the
+	// request ID, route, user ID and API key ID
+	Logger *slog.Logger
+}
+
+// Setup registers middleware and routes on e and returns the route groups
+// that were enabled for the given configuration
+func Setup(e *echo.Echo, deps Deps) []string {
+	client, r2, cfg, settings, logger := deps.Client, deps.R2, deps.Config, deps.Settings, deps.Logger
+	var groups []string
+
 	// Middleware
-	e.Use(echomiddleware.Logger())
-	e.Use(echomiddleware.Recover())
+	e.Use(middleware.TrackInFlight(deps.Tasks))
+	e.Use(metrics.Middleware())
+	e.Use(tracing.Middleware())
+	e.Use(logging.Middleware(logger))
+	e.Use(echomiddleware.RecoverWithConfig(echomiddleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			logging.For(c, logger).Error("panic recovered", "error", err, "stack", string(stack))
+			return err
+		},
+	}))
 	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
-		AllowOrigins:     cfg.CORSOrigins,
-		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "X-Requested-With", "X-User-ID", "X-API-Key", "custom"},
+		// Checked per request so origins can be reloaded
+		AllowOriginFunc: func(origin string) (bool, error) {
+			return settings.Current().AllowsOrigin(origin), nil
+		},
+		AllowMethods:     []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "Last-Event-ID", "X-Requested-With", "X-User-ID", "X-API-Key", "custom"},
 		AllowCredentials: true,
 	}))
 
 	// Health check
 	e.GET("/", handler.HealthCheck)
 	e.GET("/health", handler.HealthCheck)
+	e.GET("/version", handler.BuildInfo)
+
+	// Liveness and readiness probes
+	healthHandler := handler.NewHealthHandler(newHealthChecker(deps), logger)
+	e.GET("/livez", healthHandler.Livez)
+	e.GET("/readyz", healthHandler.Readyz)
+
+	// Prometheus metrics
+	e.GET("/metrics", metrics.Handler())
 
 	// Swagger UI (single source from docs/swagger.json)
 	e.GET("/docs", handler.SwaggerUIHandler)
 	e.GET("/swagger.json", handler.SwaggerJSONHandler)
 
 	// Initialize handlers
-	tokenHandler := handler.NewTokenHandler(client)
-	roomHandler := handler.NewRoomHandler(client)
-	participantHandler := handler.NewParticipantHandler(client)
-	webhookHandler := handler.NewWebhookHandler(client)
+	tokenHandler := handler.NewTokenHandler(client, livekit.NewTokenPolicy(cfg), logger)
+	roomHandler := handler.NewRoomHandler(client, logger)
+	participantHandler := handler.NewParticipantHandler(client, logger)
+	webhookHandler := handler.NewWebhookHandler(client, deps.Events, deps.Feed, deps.Webhooks, logger)
+	webhookHandler.Subscribe(deps.Webhooks)
+	historyHandler := handler.NewHistoryHandler(deps.Events, logger)
+	streamHandler := handler.NewStreamHandler(client, deps.Feed, cfg.EventStreamHeartbeat, logger)
+
+	// User authentication, shared by every route acting as a user
+	userAuth := middleware.AuthMiddleware(middleware.AuthConfig{
+		ConvexURL:         cfg.ConvexURL,
+		Sessions:          deps.Sessions,
+		Tokens:            middleware.NewTokenVerifier(cfg),
+		Services:          deps.Services,
+		TrustUserIDHeader: cfg.DevTrustUserIDHeader,
+		Logger:            logger,
+	})
 
 	// LiveKit routes
 	lk := e.Group("/livekit")
-	
+	groups = append(groups, "/livekit")
+
+	// Webhook - authenticated by LiveKit's signature instead
+	lk.POST("/webhook", webhookHandler.HandleWebhook)
+
+	// Everything else acts as the authenticated user
+	lku := lk.Group("", userAuth)
+
 	// Token
-	lk.POST("/token", tokenHandler.GetToken)
-	
+	lku.POST("/token", tokenHandler.GetToken)
+
 	// Rooms
-	lk.POST("/rooms", roomHandler.CreateRoom)
-	lk.GET("/rooms", roomHandler.ListRooms)
-	lk.DELETE("/rooms/:room", roomHandler.DeleteRoom)
-	
+	lku.POST("/rooms", roomHandler.CreateRoom)
+	lku.GET("/rooms", roomHandler.ListRooms)
+	lku.DELETE("/rooms/:room", roomHandler.DeleteRoom)
+	lku.PUT("/rooms/:room/moderators", roomHandler.SetModerators)
+	lku.PUT("/rooms/:room/metadata", roomHandler.UpdateRoomMetadata)
+	lku.POST("/rooms/:room/data", roomHandler.SendData)
+
 	// Participants
-	lk.GET("/rooms/:room/participants", participantHandler.ListParticipants)
-	lk.DELETE("/rooms/:room/participants/:identity", participantHandler.RemoveParticipant)
-	lk.POST("/rooms/:room/participants/:identity/mute", participantHandler.MuteTrack)
-	
-	// Webhook
-	lk.POST("/webhook", webhookHandler.HandleWebhook)
+	lku.GET("/rooms/:room/participants", participantHandler.ListParticipants)
+	lku.GET("/rooms/:room/participants/:identity", participantHandler.GetParticipant)
+	lku.PATCH("/rooms/:room/participants/:identity", participantHandler.UpdateParticipant)
+	lku.DELETE("/rooms/:room/participants/:identity", participantHandler.RemoveParticipant)
+	lku.POST("/rooms/:room/participants/:identity/mute", participantHandler.MuteTrack)
+
+	// Room-wide moderation
+	moderationHandler := handler.NewModerationHandler(client, logger)
+	lku.POST("/rooms/:room/mute-all", moderationHandler.MuteAll)
+	lku.POST("/rooms/:room/kick-all", moderationHandler.KickAll)
+	lku.GET("/rooms/:room/bans", moderationHandler.ListBans)
+	lku.PUT("/rooms/:room/bans/:identity", moderationHandler.Ban)
+	lku.DELETE("/rooms/:room/bans/:identity", moderationHandler.Unban)
+
+	// Invites (only when an invite signing key is configured)
+	if deps.Invites != nil {
+		inviteHandler := handler.NewInviteHandler(client, deps.Invites, tokenHandler, logger)
+		lku.POST("/rooms/:room/invites", inviteHandler.CreateInvite)
+		lku.GET("/rooms/:room/invites", inviteHandler.ListInvites)
+		lku.DELETE("/rooms/:room/invites/:id", inviteHandler.RevokeInvite)
+		lku.POST("/invites/redeem", inviteHandler.RedeemInvite)
+	}
+
+	// Lobby (guests wait here for rooms created with a lobby)
+	lobbyHandler := handler.NewLobbyHandler(client, deps.Lobby, tokenHandler, logger)
+	lku.POST("/rooms/:room/lobby", lobbyHandler.RequestEntry)
+	lku.GET("/rooms/:room/lobby", lobbyHandler.ListLobby)
+	lku.POST("/rooms/:room/lobby/:ticket/admit", lobbyHandler.Admit)
+	lku.POST("/rooms/:room/lobby/:ticket/deny", lobbyHandler.Deny)
+	lku.GET("/lobby/:ticket", lobbyHandler.GetTicket)
+
+	// Ingress (RTMP or WHIP streams into rooms)
+	ingressHandler := handler.NewIngressHandler(client, logger)
+	lku.POST("/rooms/:room/ingress", ingressHandler.CreateIngress)
+	lku.GET("/rooms/:room/ingress", ingressHandler.ListIngress)
+	lku.PATCH("/rooms/:room/ingress/:id", ingressHandler.UpdateIngress)
+	lku.DELETE("/rooms/:room/ingress/:id", ingressHandler.DeleteIngress)
+
+	// Session history, from webhook events
+	lku.GET("/rooms/:room/sessions", historyHandler.ListSessions)
+	lku.GET("/rooms/:room/sessions/:session/attendance", historyHandler.GetAttendance)
+	lku.GET("/participation", historyHandler.MyParticipation)
+
+	// Live room, participant and track events
+	lku.GET("/events", streamHandler.StreamEvents)
+
+	// Recordings, written through LiveKit Egress to the user's storage
+	if r2 != nil {
+		recordingHandler := handler.NewRecordingHandler(client, r2, deps.Events, logger)
+		lku.POST("/rooms/:room/recordings", recordingHandler.StartRecording)
+		lku.GET("/rooms/:room/recordings", recordingHandler.ListRoomRecordings)
+		lku.POST("/recordings/:egress/stop", recordingHandler.StopRecording)
+		lku.GET("/recordings", recordingHandler.ListRecordings)
+	}
 
 	// Storage routes (R2) - with user authentication for isolation
 	if r2 != nil {
-		storageHandler := handler.NewStorageHandler(r2)
+		storageHandler := handler.NewStorageHandler(r2, logger)
 		st := e.Group("/storage")
+		groups = append(groups, "/storage")
 		
 		// Apply auth middleware to all storage routes
-		st.Use(middleware.AuthMiddleware(middleware.AuthConfig{
-			ConvexURL: cfg.ConvexURL,
-		}))
+		st.Use(userAuth)
 		
 		st.GET("/list", storageHandler.ListObjects)
 		st.POST("/upload", storageHandler.UploadObject)
@@ -78,26 +219,41 @@ func Setup(e *echo.Echo, client *livekit.Client, r2 *storage.R2Client, cfg *conf
 		st.POST("/visibility", storageHandler.SetVisibility)
 	}
 
-	// Unsplash routes
-	if cfg.UnsplashAccessKey != "" {
-		unsplashHandler := handler.NewUnsplashHandler(cfg)
-		us := e.Group("/unsplash")
-		us.GET("/search", unsplashHandler.Search)
-		us.GET("/download", unsplashHandler.TrackDownload)
+	// Internal routes, callable only with a signed service identity from
+	// one of the services allowed to revoke sessions
+	if deps.Services != nil && len(cfg.SessionRevokers) > 0 {
+		sessionHandler := handler.NewSessionHandler(deps.Sessions, logger)
+		internal := e.Group("/internal", middleware.ServiceIdentityMiddleware(deps.Services, logger))
+		groups = append(groups, "/internal")
+		internal.POST("/sessions/revoke", sessionHandler.Revoke, middleware.RequireServices(cfg.SessionRevokers, logger))
 	}
 
+	// Unsplash routes - always registered so credentials can be added by
+	// a reload; they respond 503 until a key is configured
+	unsplashHandler := handler.NewUnsplashHandler(settings, logger)
+	us := e.Group("/unsplash")
+	if cfg.UnsplashEnabled() {
+		groups = append(groups, "/unsplash")
+	} else {
+		groups = append(groups, "/unsplash (unconfigured)")
+	}
+	us.GET("/search", unsplashHandler.Search)
+	us.GET("/download", unsplashHandler.TrackDownload)
+
 	// Knowledge Base routes
-	kbHandler := handler.NewKnowledgeBaseHandler()
+	kbHandler := handler.NewKnowledgeBaseHandler(logger)
 	kb := e.Group("/knowledge-bases")
+	groups = append(groups, "/knowledge-bases")
 	kb.GET("", kbHandler.ListKnowledgeBases)
 	kb.POST("", kbHandler.CreateKnowledgeBase)
 	kb.DELETE("/:id", kbHandler.DeleteKnowledgeBase)
 	kb.POST("/collections", kbHandler.CreateCollection)
 
 	// API Hub routes (public API with API key authentication)
-	apiHubHandler := handler.NewAPIHubHandler()
+	apiHubHandler := handler.NewAPIHubHandler(settings, deps.Tasks, logger)
 	
 	api := e.Group("/api/v1")
+	groups = append(groups, "/api/v1")
 	
 	// Public endpoints (no auth required)
 	api.GET("/health", apiHubHandler.HealthCheck)
@@ -115,4 +271,32 @@ func Setup(e *echo.Echo, client *livekit.Client, r2 *storage.R2Client, cfg *conf
 	apiProtected.POST("/leads", apiHubHandler.CreateLead)
 	apiProtected.POST("/leads/bulk", apiHubHandler.BulkCreateLeads)
 	apiProtected.GET("/leads", apiHubHandler.ListLeads)
+
+	// Admin routes (only when an admin token is configured)
+	if cfg.AdminEnabled() {
+		adminHandler := handler.NewAdminHandler(settings, deps.Traces, logger)
+		admin := e.Group("/admin")
+		groups = append(groups, "/admin")
+		admin.Use(middleware.AdminTokenMiddleware(cfg.AdminToken))
+		admin.POST("/reload", adminHandler.ReloadConfig)
+		admin.GET("/traces", adminHandler.ListTraces)
+		admin.GET("/participation/:identity", historyHandler.Participation)
+	}
+
+	return groups
+}
+
+// newHealthChecker builds the readiness checks for the configured
+// dependencies
+func newHealthChecker(deps Deps) *health.Checker {
+	checks := []health.Check{
+		{Name: "livekit", Required: true, Probe: deps.Client.Ping},
+	}
+	if deps.R2 != nil {
+		checks = append(checks, health.Check{Name: "r2", Required: true, Probe: deps.R2.Ping})
+	}
+	if deps.Config.ConvexURL != "" {
+		checks = append(checks, health.Check{Name: "convex", Required: true, Probe: health.HTTPProbe(deps.Config.ConvexURL)})
+	}
+	return health.NewChecker(3*time.Second, 5*time.Second, checks...)
 }
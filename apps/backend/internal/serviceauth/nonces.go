@@ -0,0 +1,73 @@
+package serviceauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisNoncePrefix namespaces used nonces in a shared Redis
+const redisNoncePrefix = "serviceauth:nonce:"
+
+// nonceStore remembers used nonces until their assertions expire
+type nonceStore interface {
+	// use records nonce for ttl and reports whether it was unused
+	use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// memoryNonces keeps used nonces in this process only
+type memoryNonces struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func newMemoryNonces() *memoryNonces {
+	return &memoryNonces{seen: make(map[string]time.Time)}
+}
+
+func (n *memoryNonces) use(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(n.lastPrune) > time.Minute {
+		for key, exp := range n.seen {
+			if now.After(exp) {
+				delete(n.seen, key)
+			}
+		}
+		n.lastPrune = now
+	}
+
+	if exp, ok := n.seen[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	n.seen[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// redisNonces keeps used nonces in a Redis shared by every replica, each
+// under a key expiring with its assertion
+type redisNonces struct {
+	client *redis.Client
+}
+
+func newRedisNonces(url string) (*redisNonces, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &redisNonces{client: redis.NewClient(opts)}, nil
+}
+
+func (n *redisNonces) use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return n.client.SetNX(ctx, redisNoncePrefix+nonce, 1, max(ttl, time.Millisecond)).Result()
+}
+
+func (n *redisNonces) Close() error {
+	return n.client.Close()
+}
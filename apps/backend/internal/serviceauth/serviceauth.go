@@ -0,0 +1,218 @@
+package serviceauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"myapp/internal/config"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// Header carries the signed identity assertion
+const Header = "X-Service-Identity"
+
+// leeway tolerates clock skew between services
+const leeway = 30 * time.Second
+
+var (
+	ErrUntrustedNetwork = errors.New("caller is not in a trusted network")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrReplayed         = errors.New("assertion nonce already used")
+)
+
+// Identity is a verified assertion made by an internal service on behalf
+// of a user
+type Identity struct {
+	UserID string
+	// Service is the ID of the key the assertion was signed with
+	Service  string
+	Nonce    string
+	IssuedAt time.Time
+	Expiry   time.Time
+}
+
+// Verifier checks signed identity assertions. An assertion is a compact
+// JWT signed with HS256 or EdDSA whose "kid" header names one of the
+// configured keys, carrying sub (user ID), aud, iat, exp and a unique jti
+// (nonce). Lifetimes are capped so used nonces only need to be remembered
+// briefly. They are kept per process, or in Redis so an assertion is
+// accepted once across every replica.
+type Verifier struct {
+	keys     map[string]config.ServiceKey
+	audience string
+	maxTTL   time.Duration
+	trusted  []*net.IPNet
+	nonces   nonceStore
+}
+
+// NewVerifier creates a verifier from the service auth settings, with used
+// nonces shared through Redis when SERVICE_AUTH_REDIS_URL is set, or
+// returns nil when service auth is not configured
+func NewVerifier(cfg *config.Config) (*Verifier, error) {
+	if !cfg.ServiceAuthEnabled() {
+		return nil, nil
+	}
+
+	keys, err := config.ParseServiceKeys(cfg.ServiceAuthKeys)
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{
+		keys:     make(map[string]config.ServiceKey, len(keys)),
+		audience: cfg.ServiceAuthAudience,
+		maxTTL:   cfg.ServiceAuthMaxTTL,
+	}
+	for _, key := range keys {
+		v.keys[key.ID] = key
+	}
+	for _, cidr := range cfg.ServiceAuthTrustedCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		v.trusted = append(v.trusted, network)
+	}
+	if cfg.ServiceAuthRedisURL != "" {
+		if v.nonces, err = newRedisNonces(cfg.ServiceAuthRedisURL); err != nil {
+			return nil, err
+		}
+	} else {
+		v.nonces = newMemoryNonces()
+	}
+	return v, nil
+}
+
+// Close releases the nonce store's connections, if it holds any
+func (v *Verifier) Close() error {
+	if closer, ok := v.nonces.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Verify checks the assertion in r's Header. Trusted networks are matched
+// against the connection's peer address, not forwarding headers, so a
+// proxy in front of the server must itself be in a trusted network.
+func (v *Verifier) Verify(r *http.Request) (*Identity, error) {
+	if len(v.trusted) > 0 && !v.fromTrustedNetwork(r.RemoteAddr) {
+		return nil, ErrUntrustedNetwork
+	}
+	return v.VerifyToken(r.Context(), r.Header.Get(Header))
+}
+
+// VerifyToken checks a single assertion and records its nonce. An
+// assertion whose nonce cannot be recorded is rejected.
+func (v *Verifier) VerifyToken(ctx context.Context, raw string) (*Identity, error) {
+	token, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed assertion: %w", err)
+	}
+	if len(token.Headers) != 1 {
+		return nil, errors.New("assertion must have exactly one signature")
+	}
+
+	header := token.Headers[0]
+	key, ok := v.keys[header.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// Never let the token choose the algorithm
+	if header.Algorithm != key.Algorithm {
+		return nil, fmt.Errorf("key %q requires %s, assertion uses %s", key.ID, key.Algorithm, header.Algorithm)
+	}
+
+	var claims jwt.Claims
+	if err := token.Claims(verificationKey(key), &claims); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	now := time.Now()
+	if claims.Subject == "" || claims.ID == "" || claims.Expiry == nil || claims.IssuedAt == nil {
+		return nil, errors.New("assertion must carry sub, jti, iat and exp")
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{
+		Audience: jwt.Audience{v.audience},
+		Time:     now,
+	}, leeway); err != nil {
+		return nil, err
+	}
+	expiry := claims.Expiry.Time()
+	if expiry.Sub(claims.IssuedAt.Time()) > v.maxTTL {
+		return nil, fmt.Errorf("assertion lifetime exceeds %s", v.maxTTL)
+	}
+
+	fresh, err := v.nonces.use(ctx, key.ID+":"+claims.ID, expiry.Add(leeway).Sub(now))
+	if err != nil {
+		return nil, fmt.Errorf("record nonce: %w", err)
+	}
+	if !fresh {
+		return nil, ErrReplayed
+	}
+
+	return &Identity{
+		UserID:   claims.Subject,
+		Service:  key.ID,
+		Nonce:    claims.ID,
+		IssuedAt: claims.IssuedAt.Time(),
+		Expiry:   expiry,
+	}, nil
+}
+
+func (v *Verifier) fromTrustedNetwork(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range v.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func verificationKey(key config.ServiceKey) interface{} {
+	if key.Algorithm == "EdDSA" {
+		return ed25519.PublicKey(key.Key)
+	}
+	return key.Key
+}
+
+// Sign creates an assertion for userID. signingKey is the HMAC secret for
+// HS256 keys or the ed25519.PrivateKey for EdDSA keys.
+func Sign(key config.ServiceKey, signingKey interface{}, userID, audience string, ttl time.Duration) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(key.Algorithm), Key: signingKey},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", key.ID),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return jwt.Signed(signer).Claims(jwt.Claims{
+		Subject:  userID,
+		Audience: jwt.Audience{audience},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(ttl)),
+		ID:       hex.EncodeToString(nonce),
+	}).CompactSerialize()
+}
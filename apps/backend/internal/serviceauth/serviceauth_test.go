@@ -0,0 +1,68 @@
+package serviceauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myapp/internal/config"
+)
+
+func newTestVerifier(t *testing.T) (*Verifier, config.ServiceKey) {
+	t.Helper()
+	v, err := NewVerifier(&config.Config{
+		ServiceAuthKeys:     "worker=hs256:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		ServiceAuthAudience: "backend",
+		ServiceAuthMaxTTL:   5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, v.keys["worker"]
+}
+
+func TestVerifyTokenRejectsReplays(t *testing.T) {
+	v, key := newTestVerifier(t)
+	assertion, err := Sign(key, key.Key, "user-1", "backend", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := v.VerifyToken(context.Background(), assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != "user-1" || identity.Service != "worker" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if _, err := v.VerifyToken(context.Background(), assertion); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replay error = %v, want ErrReplayed", err)
+	}
+
+	// A replica sharing the nonce store rejects it too
+	replica, _ := newTestVerifier(t)
+	replica.nonces = v.nonces
+	if _, err := replica.VerifyToken(context.Background(), assertion); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replay on another replica error = %v, want ErrReplayed", err)
+	}
+}
+
+func TestVerifyTokenFailsClosed(t *testing.T) {
+	v, key := newTestVerifier(t)
+	v.nonces = failingNonces{}
+	assertion, err := Sign(key, key.Key, "user-1", "backend", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyToken(context.Background(), assertion); err == nil {
+		t.Error("assertion accepted without recording its nonce")
+	}
+}
+
+// failingNonces stands in for an unreachable Redis
+type failingNonces struct{}
+
+func (failingNonces) use(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
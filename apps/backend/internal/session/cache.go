@@ -0,0 +1,192 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"myapp/internal/config"
+	"myapp/internal/metrics"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrInvalid is returned for sessions the validator rejected
+var ErrInvalid = errors.New("invalid session")
+
+// revocationSkew keeps deny list entries past the revocation TTL for the
+// clock skew expired tokens are still accepted with
+const revocationSkew = time.Minute
+
+// Validator checks credentials with the source of truth. It returns an
+// entry with Valid false for a rejected session and an error only for
+// failures worth retrying, which are not cached.
+type Validator func(ctx context.Context) (*Entry, error)
+
+// Cache remembers session validation results. Valid sessions are kept for
+// the TTL and invalid ones for the (shorter) negative TTL, and concurrent
+// lookups for the same credentials share a single validation.
+//
+// It also keeps the deny list of revoked users and sessions. A revocation
+// rejects cached results and tokens from before it for the revocation TTL,
+// which must outlive both the cache TTL and the longest token lifetime.
+type Cache struct {
+	store         Store
+	ttl           time.Duration
+	negativeTTL   time.Duration
+	revocationTTL time.Duration
+	group         singleflight.Group
+	logger        *slog.Logger
+}
+
+// NewCache creates a cache on top of store
+func NewCache(store Store, ttl, negativeTTL, revocationTTL time.Duration, logger *slog.Logger) *Cache {
+	return &Cache{
+		store:         store,
+		ttl:           ttl,
+		negativeTTL:   negativeTTL,
+		revocationTTL: revocationTTL,
+		logger:        logger,
+	}
+}
+
+// New creates the cache described by the session cache settings: shared
+// through Redis when SESSION_CACHE_REDIS_URL is set, in memory otherwise
+func New(cfg *config.Config, logger *slog.Logger) (*Cache, error) {
+	var store Store = NewMemoryStore(cfg.SessionCacheSize)
+	if cfg.SessionCacheRedisURL != "" {
+		redisStore, err := NewRedisStore(cfg.SessionCacheRedisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	}
+	return NewCache(store, cfg.SessionCacheTTL, cfg.SessionCacheNegativeTTL, cfg.SessionRevocationTTL, logger), nil
+}
+
+// Lookup returns the cached result for credentials, calling validate on a
+// miss. A store that fails is treated as a miss so authentication keeps
+// working without it. It returns ErrInvalid for rejected sessions, and
+// revalidates cached ones whose user has since been revoked.
+func (c *Cache) Lookup(ctx context.Context, credentials string, validate Validator) (*Entry, error) {
+	key := cacheKey(credentials)
+
+	entry, err := c.store.Get(ctx, key)
+	switch {
+	case err != nil:
+		c.logger.Warn("session cache read failed", "error", err)
+		metrics.SessionCacheLookup("error")
+	case entry != nil && entry.Valid && c.Revoked(ctx, entry.UserID, "", entry.ValidatedAt):
+		metrics.SessionCacheLookup("revoked")
+		if err := c.store.Delete(ctx, key); err != nil {
+			c.logger.Warn("session cache delete failed", "error", err)
+		}
+	case entry != nil && entry.Valid:
+		metrics.SessionCacheLookup("hit")
+		return entry, nil
+	case entry != nil:
+		metrics.SessionCacheLookup("negative_hit")
+		return nil, ErrInvalid
+	default:
+		metrics.SessionCacheLookup("miss")
+	}
+
+	// The shared validation must not be cancelled by whichever request
+	// happened to start it
+	results := c.group.DoChan(key, func() (interface{}, error) {
+		vctx := context.WithoutCancel(ctx)
+		validatedAt := time.Now()
+		entry, err := validate(vctx)
+		if err != nil {
+			return nil, err
+		}
+		entry.ValidatedAt = validatedAt
+		ttl := c.ttl
+		if !entry.Valid {
+			ttl = c.negativeTTL
+		}
+		if err := c.store.Set(vctx, key, entry, ttl); err != nil {
+			c.logger.Warn("session cache write failed", "error", err)
+		}
+		return entry, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		entry := result.Val.(*Entry)
+		// A revocation may have landed while Convex was answering
+		if !entry.Valid || c.Revoked(ctx, entry.UserID, "", entry.ValidatedAt) {
+			return nil, ErrInvalid
+		}
+		return entry, nil
+	}
+}
+
+// Invalidate drops the cached result for credentials
+func (c *Cache) Invalidate(ctx context.Context, credentials string) error {
+	return c.store.Delete(ctx, cacheKey(credentials))
+}
+
+// RevokeUser rejects every session and token of userID issued before now
+// and drops the user's cached sessions
+func (c *Cache) RevokeUser(ctx context.Context, userID string) error {
+	if err := c.store.Revoke(ctx, "user:"+userID, time.Now(), c.revocationTTL+revocationSkew); err != nil {
+		return err
+	}
+	return c.store.DeleteUser(ctx, userID)
+}
+
+// RevokeSession rejects tokens for one session of userID. Cached cookie
+// sessions don't record which session they belong to, so all of the
+// user's are dropped and revalidated.
+func (c *Cache) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if err := c.store.Revoke(ctx, "session:"+sessionID, time.Now(), c.revocationTTL+revocationSkew); err != nil {
+		return err
+	}
+	return c.store.DeleteUser(ctx, userID)
+}
+
+// Revoked reports whether credentials for userID (and sessionID, when
+// known) issued at issuedAt have since been revoked. A deny list that
+// cannot be read is logged and treated as empty.
+func (c *Cache) Revoked(ctx context.Context, userID, sessionID string, issuedAt time.Time) bool {
+	ids := []string{"user:" + userID}
+	if sessionID != "" {
+		ids = append(ids, "session:"+sessionID)
+	}
+	for _, id := range ids {
+		revokedAt, err := c.store.RevokedAt(ctx, id)
+		if err != nil {
+			c.logger.Warn("session deny list read failed", "error", err)
+			continue
+		}
+		if !revokedAt.IsZero() && !issuedAt.After(revokedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close releases the store's connections, if it holds any
+func (c *Cache) Close() error {
+	if closer, ok := c.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// cacheKey hashes credentials so session cookies are never stored or
+// sent to a shared store as-is
+func cacheKey(credentials string) string {
+	sum := sha256.Sum256([]byte(credentials))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,146 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a bounded, in-process LRU Store. Entries are evicted when
+// they expire or, once the store is full, least recently used first.
+// Revocations are only seen by this process.
+type MemoryStore struct {
+	mu          sync.Mutex
+	size        int
+	order       *list.List // front is most recently used
+	entries     map[string]*list.Element
+	revocations map[string]memoryRevocation
+}
+
+type memoryRevocation struct {
+	at        time.Time
+	expiresAt time.Time
+}
+
+type memoryItem struct {
+	key       string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates a store holding at most size entries
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{
+		size:        size,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+		revocations: make(map[string]memoryRevocation),
+	}
+}
+
+// Get returns the entry for key, or nil when it is missing or expired
+func (s *MemoryStore) Get(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	item := elem.Value.(*memoryItem)
+	if time.Now().After(item.expiresAt) {
+		s.remove(elem)
+		return nil, nil
+	}
+	s.order.MoveToFront(elem)
+	entry := item.entry
+	return &entry, nil
+}
+
+// Set stores an entry, evicting the least recently used entries when the
+// store is full
+func (s *MemoryStore) Set(_ context.Context, key string, entry *Entry, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := s.entries[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.entry = *entry
+		item.expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return nil
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryItem{key: key, entry: *entry, expiresAt: expiresAt})
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete removes the entry for key
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// DeleteUser removes every valid entry for userID
+func (s *MemoryStore) DeleteUser(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for elem := s.order.Front(); elem != nil; {
+		next := elem.Next()
+		if item := elem.Value.(*memoryItem); item.entry.Valid && item.entry.UserID == userID {
+			s.remove(elem)
+		}
+		elem = next
+	}
+	return nil
+}
+
+// Revoke records a revocation of id, dropping expired ones
+func (s *MemoryStore) Revoke(_ context.Context, id string, at time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, revocation := range s.revocations {
+		if now.After(revocation.expiresAt) {
+			delete(s.revocations, key)
+		}
+	}
+	s.revocations[id] = memoryRevocation{at: at, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// RevokedAt returns when id was last revoked, or the zero time
+func (s *MemoryStore) RevokedAt(_ context.Context, id string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revocation, ok := s.revocations[id]
+	if !ok || time.Now().After(revocation.expiresAt) {
+		return time.Time{}, nil
+	}
+	return revocation.at, nil
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryItem).key)
+}
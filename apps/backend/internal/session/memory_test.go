@@ -0,0 +1,118 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(3)
+
+	for i := 0; i < 3; i++ {
+		set(t, s, fmt.Sprintf("k%d", i), time.Minute)
+	}
+	// k0 becomes the most recently used, leaving k1 the oldest
+	if entry := get(t, s, "k0"); entry == nil {
+		t.Fatal("k0 missing before the store was full")
+	}
+	set(t, s, "k3", time.Minute)
+	set(t, s, "k4", time.Minute)
+
+	if got := s.Len(); got != 3 {
+		t.Fatalf("Len() = %d, want 3", got)
+	}
+	for key, want := range map[string]bool{"k0": true, "k1": false, "k2": false, "k3": true, "k4": true} {
+		if got := get(t, s, key) != nil; got != want {
+			t.Errorf("%s cached = %v, want %v", key, got, want)
+		}
+	}
+
+	// Updating an entry does not grow the store
+	set(t, s, "k4", time.Minute)
+	if got := s.Len(); got != 3 {
+		t.Errorf("Len() after update = %d, want 3", got)
+	}
+	if err := s.Delete(ctx, "k4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Len(); got != 2 {
+		t.Errorf("Len() after delete = %d, want 2", got)
+	}
+}
+
+func TestMemoryStoreStaysBounded(t *testing.T) {
+	const size = 100
+	s := NewMemoryStore(size)
+	for i := 0; i < 10*size; i++ {
+		set(t, s, fmt.Sprintf("k%d", i), time.Minute)
+		if got := s.Len(); got > size {
+			t.Fatalf("Len() = %d after %d sets, want at most %d", got, i+1, size)
+		}
+	}
+	// The most recent entries survive
+	for i := 9 * size; i < 10*size; i++ {
+		if get(t, s, fmt.Sprintf("k%d", i)) == nil {
+			t.Fatalf("k%d evicted, want the last %d entries kept", i, size)
+		}
+	}
+}
+
+func TestMemoryStoreExpiresEntries(t *testing.T) {
+	s := NewMemoryStore(10)
+	set(t, s, "short", time.Millisecond)
+	set(t, s, "long", time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	if get(t, s, "short") != nil {
+		t.Error("expired entry returned")
+	}
+	if get(t, s, "long") == nil {
+		t.Error("live entry missing")
+	}
+	// The expired entry is dropped when it is read
+	if got := s.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1", got)
+	}
+}
+
+func TestMemoryStoreDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(10)
+	for key, entry := range map[string]*Entry{
+		"a1": {Valid: true, UserID: "alice"},
+		"a2": {Valid: true, UserID: "alice"},
+		"b1": {Valid: true, UserID: "bob"},
+		"x":  {Valid: false},
+	} {
+		if err := s.Set(ctx, key, entry, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.DeleteUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{"a1": false, "a2": false, "b1": true, "x": true} {
+		if got := get(t, s, key) != nil; got != want {
+			t.Errorf("%s cached = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func set(t *testing.T, s Store, key string, ttl time.Duration) {
+	t.Helper()
+	if err := s.Set(context.Background(), key, &Entry{Valid: true, UserID: "user-" + key}, ttl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, s Store, key string) *Entry {
+	t.Helper()
+	entry, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
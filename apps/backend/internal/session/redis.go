@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key prefixes namespacing session data in a shared Redis. Entry keys
+// are hex hashes, so they never collide with the user index.
+const (
+	redisKeyPrefix        = "session:"
+	redisUserIndexPrefix  = "session:user:"
+	redisRevocationPrefix = "session:revoked:"
+)
+
+// RedisStore is a Store shared by every replica through Redis. Entries
+// expire with Redis key TTLs.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis server at url
+// (redis://[:password@]host:port/db or rediss:// for TLS)
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Get returns the entry for key, or nil when there is none
+func (s *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("decode cached session: %w", err)
+	}
+	return &entry, nil
+}
+
+// Set stores an entry for key until ttl elapses. Valid entries are also
+// indexed by user so DeleteUser can find them.
+func (s *RedisStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if !entry.Valid || entry.UserID == "" {
+		return s.client.Set(ctx, redisKeyPrefix+key, data, ttl).Err()
+	}
+
+	index := redisUserIndexPrefix + entry.UserID
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisKeyPrefix+key, data, ttl)
+		pipe.SAdd(ctx, index, key)
+		pipe.Expire(ctx, index, ttl)
+		return nil
+	})
+	return err
+}
+
+// Delete removes the entry for key
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, redisKeyPrefix+key).Err()
+}
+
+// DeleteUser removes every valid entry for userID
+func (s *RedisStore) DeleteUser(ctx context.Context, userID string) error {
+	index := redisUserIndexPrefix + userID
+	keys, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		names = append(names, redisKeyPrefix+key)
+	}
+	names = append(names, index)
+	return s.client.Del(ctx, names...).Err()
+}
+
+// Revoke records a revocation of id that expires after ttl
+func (s *RedisStore) Revoke(ctx context.Context, id string, at time.Time, ttl time.Duration) error {
+	return s.client.Set(ctx, redisRevocationPrefix+id, at.UnixNano(), ttl).Err()
+}
+
+// RevokedAt returns when id was last revoked, or the zero time
+func (s *RedisStore) RevokedAt(ctx context.Context, id string) (time.Time, error) {
+	nanos, err := s.client.Get(ctx, redisRevocationPrefix+id).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// Ping checks the connection to Redis
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the connection pool
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
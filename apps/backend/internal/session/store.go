@@ -0,0 +1,36 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is the cached outcome of validating a session. Invalid sessions
+// are cached too, so repeated requests with a bad cookie don't each reach
+// Convex.
+type Entry struct {
+	Valid  bool   `json:"valid"`
+	UserID string `json:"userId,omitempty"`
+	Email  string `json:"email,omitempty"`
+	// ValidatedAt is when validation started, so a revocation that raced
+	// with it still applies
+	ValidatedAt time.Time `json:"validatedAt"`
+}
+
+// Store holds cached entries. Implementations must be safe for concurrent
+// use and must expire entries after their TTL.
+type Store interface {
+	// Get returns the entry for key, or nil when there is none
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Set stores an entry for key until ttl elapses
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error
+	// Delete removes the entry for key, if any
+	Delete(ctx context.Context, key string) error
+	// DeleteUser removes every valid entry for userID
+	DeleteUser(ctx context.Context, userID string) error
+	// Revoke records that credentials for id issued before at are no
+	// longer accepted, for ttl
+	Revoke(ctx context.Context, id string, at time.Time, ttl time.Duration) error
+	// RevokedAt returns when id was last revoked, or the zero time
+	RevokedAt(ctx context.Context, id string) (time.Time, error)
+}
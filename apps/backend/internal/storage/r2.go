@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"myapp/internal/config"
+	"myapp/internal/metrics"
+	"myapp/internal/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -40,6 +42,11 @@ type ListResult struct {
 	Files   []StorageEntry `json:"files"`
 }
 
+// UserPrefix returns the key prefix that isolates a user's objects
+func UserPrefix(userID string) string {
+	return fmt.Sprintf("users/%s/", userID)
+}
+
 func NewR2Client(cfg *config.Config) (*R2Client, error) {
 	r2Resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		return aws.Endpoint{
@@ -60,7 +67,9 @@ func NewR2Client(cfg *config.Config) (*R2Client, error) {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(awsCfg)
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, metrics.AWSMiddleware("r2"), tracing.AWSMiddleware("r2"))
+	})
 
 	return &R2Client{
 		client:    client,
@@ -69,6 +78,16 @@ func NewR2Client(cfg *config.Config) (*R2Client, error) {
 	}, nil
 }
 
+// Ping checks that the bucket exists and the credentials can access it
+func (r *R2Client) Ping(ctx context.Context) error {
+	_, err := r.client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(r.bucket),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reach bucket: %w", err)
+	}
+	return nil
+}
 
 func (r *R2Client) List(ctx context.Context, prefix string) (*ListResult, error) {
 	input := &s3.ListObjectsV2Input{
@@ -104,35 +123,9 @@ func (r *R2Client) List(ctx context.Context, prefix string) (*ListResult, error)
 
 	// Process objects (files)
 	for _, obj := range result.Contents {
-		if obj.Key == nil {
-			continue
-		}
-		key := *obj.Key
-		name := strings.TrimPrefix(key, prefix)
-		if name == "" || strings.HasSuffix(name, "/") {
-			continue
-		}
-
-		var updatedAt *string
-		if obj.LastModified != nil {
-			t := obj.LastModified.Format(time.RFC3339)
-			updatedAt = &t
-		}
-
-		var publicURL *string
-		if r.publicURL != "" {
-			url := fmt.Sprintf("%s/%s", strings.TrimSuffix(r.publicURL, "/"), key)
-			publicURL = &url
+		if entry, ok := r.fileEntry(obj, prefix); ok {
+			files = append(files, entry)
 		}
-
-		files = append(files, StorageEntry{
-			Key:       key,
-			Name:      name,
-			IsFolder:  false,
-			Size:      obj.Size,
-			UpdatedAt: updatedAt,
-			PublicURL: publicURL,
-		})
 	}
 
 	return &ListResult{
@@ -142,6 +135,63 @@ func (r *R2Client) List(ctx context.Context, prefix string) (*ListResult, error)
 	}, nil
 }
 
+// ListFiles lists every file under prefix, including those in nested
+// folders, reading as many pages as the bucket returns
+func (r *R2Client) ListFiles(ctx context.Context, prefix string) ([]StorageEntry, error) {
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	files := make([]StorageEntry, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			if entry, ok := r.fileEntry(obj, prefix); ok {
+				files = append(files, entry)
+			}
+		}
+	}
+	return files, nil
+}
+
+// fileEntry describes a listed object named relative to prefix. Folder
+// placeholders are skipped.
+func (r *R2Client) fileEntry(obj types.Object, prefix string) (StorageEntry, bool) {
+	if obj.Key == nil {
+		return StorageEntry{}, false
+	}
+	key := *obj.Key
+	name := strings.TrimPrefix(key, prefix)
+	if name == "" || strings.HasSuffix(name, "/") {
+		return StorageEntry{}, false
+	}
+
+	var updatedAt *string
+	if obj.LastModified != nil {
+		t := obj.LastModified.Format(time.RFC3339)
+		updatedAt = &t
+	}
+
+	var publicURL *string
+	if r.publicURL != "" {
+		url := fmt.Sprintf("%s/%s", strings.TrimSuffix(r.publicURL, "/"), key)
+		publicURL = &url
+	}
+
+	return StorageEntry{
+		Key:       key,
+		Name:      name,
+		IsFolder:  false,
+		Size:      obj.Size,
+		UpdatedAt: updatedAt,
+		PublicURL: publicURL,
+	}, true
+}
+
 func (r *R2Client) Upload(ctx context.Context, key string, body io.Reader, contentType string) (*StorageEntry, error) {
 	if contentType == "" {
 		contentType = mime.TypeByExtension(filepath.Ext(key))
@@ -178,6 +228,34 @@ func (r *R2Client) Upload(ctx context.Context, key string, body io.Reader, conte
 	}, nil
 }
 
+// Get opens an object for reading. The caller must close the returned body.
+func (r *R2Client) Get(ctx context.Context, key string) (io.ReadCloser, *StorageEntry, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	}
+
+	result, err := r.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get object: %w", err)
+	}
+
+	var updatedAt *string
+	if result.LastModified != nil {
+		t := result.LastModified.Format(time.RFC3339)
+		updatedAt = &t
+	}
+
+	return result.Body, &StorageEntry{
+		Key:         key,
+		Name:        filepath.Base(key),
+		IsFolder:    false,
+		Size:        result.ContentLength,
+		ContentType: result.ContentType,
+		UpdatedAt:   updatedAt,
+	}, nil
+}
+
 func (r *R2Client) Delete(ctx context.Context, key string, recursive bool) error {
 	if recursive && strings.HasSuffix(key, "/") {
 		// Delete all objects with this prefix
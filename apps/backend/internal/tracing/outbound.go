@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// peerService labels the external service a client span calls
+var peerService = attribute.Key("peer.service")
+
+// Transport starts a client span for every request and injects the W3C
+// traceparent header. Spans are named "<service> <operation>"; operation
+// works as in metrics.Transport and defaults to the URL path. A nil next
+// uses http.DefaultTransport.
+func Transport(service string, operation func(*http.Request) string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if operation == nil {
+		operation = func(r *http.Request) string { return r.URL.Path }
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		ctx, span := tracer().Start(r.Context(), service+" "+operation(r),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				peerService.String(service),
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.ServerAddress(r.URL.Hostname()),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		// A RoundTripper must not modify the caller's request
+		r = r.Clone(ctx)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return resp, err
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode))
+		}
+		return resp, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// twirpSpanKey marks a context carrying a span started by TwirpHooks
+type twirpSpanKey struct{}
+
+// TwirpHooks starts a client span for every call of a Twirp client such as
+// the LiveKit RoomService and propagates the trace to the server
+func TwirpHooks(service string) *twirp.ClientHooks {
+	return &twirp.ClientHooks{
+		RequestPrepared: func(ctx context.Context, r *http.Request) (context.Context, error) {
+			method, _ := twirp.MethodName(ctx)
+			rpcService, _ := twirp.ServiceName(ctx)
+			ctx, _ = tracer().Start(ctx, service+" "+method,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					peerService.String(service),
+					semconv.RPCSystemKey.String("twirp"),
+					semconv.RPCService(rpcService),
+					semconv.RPCMethod(method),
+				),
+			)
+			otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+			return context.WithValue(ctx, twirpSpanKey{}, true), nil
+		},
+		ResponseReceived: func(ctx context.Context) {
+			trace.SpanFromContext(ctx).End()
+		},
+		Error: func(ctx context.Context, err twirp.Error) {
+			// Errors before the request was prepared have no client span,
+			// and the span in ctx belongs to the caller
+			if ctx.Value(twirpSpanKey{}) == nil {
+				return
+			}
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, string(err.Code()))
+			span.End()
+		},
+	}
+}
+
+// AWSMiddleware starts a client span for every operation of an AWS SDK
+// client such as the R2 S3 client, including its retries. Add it through
+// the client's APIOptions.
+func AWSMiddleware(service string) func(*smithymiddleware.Stack) error {
+	return func(stack *smithymiddleware.Stack) error {
+		return stack.Initialize.Add(smithymiddleware.InitializeMiddlewareFunc("Tracing",
+			func(ctx context.Context, in smithymiddleware.InitializeInput, next smithymiddleware.InitializeHandler) (smithymiddleware.InitializeOutput, smithymiddleware.Metadata, error) {
+				operation := awsmiddleware.GetOperationName(ctx)
+				ctx, span := tracer().Start(ctx, service+" "+operation,
+					trace.WithSpanKind(trace.SpanKindClient),
+					trace.WithAttributes(
+						peerService.String(service),
+						semconv.RPCSystemKey.String("aws-api"),
+						semconv.RPCService(awsmiddleware.GetServiceID(ctx)),
+						semconv.RPCMethod(operation),
+					),
+				)
+				defer span.End()
+
+				out, md, err := next.HandleInitialize(ctx, in)
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				}
+				return out, md, err
+			}), smithymiddleware.After)
+	}
+}
@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanRecord is a finished span kept by a Recorder
+type SpanRecord struct {
+	TraceID    string            `json:"traceId"`
+	SpanID     string            `json:"spanId"`
+	ParentID   string            `json:"parentId,omitempty"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	Start      time.Time         `json:"start"`
+	DurationMs float64           `json:"durationMs"`
+	Status     string            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Recorder is an in-memory span exporter for local testing. It keeps the
+// most recent spans up to a fixed capacity.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []SpanRecord
+	next  int
+	full  bool
+}
+
+// NewRecorder creates a recorder holding up to capacity spans
+func NewRecorder(capacity int) *Recorder {
+	return &Recorder{spans: make([]SpanRecord, capacity)}
+}
+
+// ExportSpans implements sdktrace.SpanExporter
+func (r *Recorder) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, span := range spans {
+		r.spans[r.next] = newSpanRecord(span)
+		r.next = (r.next + 1) % len(r.spans)
+		if r.next == 0 {
+			r.full = true
+		}
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter
+func (r *Recorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the recorded spans, oldest first. A non-empty traceID
+// limits the result to that trace.
+func (r *Recorder) Spans(traceID string) []SpanRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ordered := r.spans[:r.next]
+	if r.full {
+		ordered = append(append([]SpanRecord(nil), r.spans[r.next:]...), r.spans[:r.next]...)
+	}
+
+	spans := make([]SpanRecord, 0, len(ordered))
+	for _, span := range ordered {
+		if traceID == "" || span.TraceID == traceID {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func newSpanRecord(span sdktrace.ReadOnlySpan) SpanRecord {
+	record := SpanRecord{
+		TraceID:    span.SpanContext().TraceID().String(),
+		SpanID:     span.SpanContext().SpanID().String(),
+		Name:       span.Name(),
+		Kind:       span.SpanKind().String(),
+		Start:      span.StartTime(),
+		DurationMs: float64(span.EndTime().Sub(span.StartTime()).Microseconds()) / 1000,
+		Status:     span.Status().Code.String(),
+		Error:      span.Status().Description,
+	}
+	if parent := span.Parent(); parent.IsValid() {
+		record.ParentID = parent.SpanID().String()
+	}
+	if attrs := span.Attributes(); len(attrs) > 0 {
+		record.Attributes = make(map[string]string, len(attrs))
+		for _, attr := range attrs {
+			record.Attributes[string(attr.Key)] = attr.Value.Emit()
+		}
+	}
+	return record
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"myapp/internal/config"
+	"myapp/internal/health"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this service
+const instrumentationName = "myapp"
+
+// recorderCapacity bounds the spans kept by the memory exporter
+const recorderCapacity = 1000
+
+// Provider owns the tracer provider installed by Setup
+type Provider struct {
+	provider *sdktrace.TracerProvider
+	// Recorder holds recent spans when the memory exporter is selected,
+	// and is nil otherwise
+	Recorder *Recorder
+}
+
+// Setup installs the W3C trace context propagator and, when tracing is
+// enabled, a global tracer provider exporting to the configured exporter.
+// With tracing disabled no spans are recorded, but incoming trace context
+// is still passed on to outbound calls.
+func Setup(ctx context.Context, cfg *config.Config) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if !cfg.TracingEnabled() {
+		return &Provider{}, nil
+	}
+
+	p := &Provider{}
+	var processor sdktrace.SpanProcessor
+	switch cfg.TracingExporter {
+	case "otlp":
+		// Endpoint, headers and TLS come from the standard
+		// OTEL_EXPORTER_OTLP_* environment variables
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("create OTLP exporter: %w", err)
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case "stdout":
+		// stderr keeps spans apart from the log stream on stdout
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+		if err != nil {
+			return nil, fmt.Errorf("create stdout exporter: %w", err)
+		}
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	case "memory":
+		p.Recorder = NewRecorder(recorderCapacity)
+		processor = sdktrace.NewSimpleSpanProcessor(p.Recorder)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.TracingExporter)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName("backend"),
+			semconv.ServiceVersion(health.Build().Version),
+		),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the above
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build tracing resource: %w", err)
+	}
+
+	p.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(p.provider)
+	return p, nil
+}
+
+// Shutdown flushes pending spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.provider == nil {
+		return nil
+	}
+	return p.provider.Shutdown(ctx)
+}
+
+// tracer returns the tracer from the current global provider
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
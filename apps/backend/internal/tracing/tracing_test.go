@@ -0,0 +1,288 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithymiddleware "github.com/aws/smithy-go/middleware"
+	"github.com/labstack/echo/v4"
+	lkproto "github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+	"github.com/twitchtv/twirp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+)
+
+// Incoming trace context the request spans must continue
+const (
+	incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingSpanID  = "00f067aa0ba902b7"
+)
+
+// setupTest installs a tracer provider exporting to memory, restoring the
+// previous globals when the test ends
+func setupTest(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter
+}
+
+// upstream stands in for the services the handler calls, keeping the
+// traceparent header of each request by path
+type upstream struct {
+	*httptest.Server
+	mu          sync.Mutex
+	traceparent map[string]string
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{traceparent: make(map[string]string)}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.traceparent[r.URL.Path] = r.Header.Get("traceparent")
+		u.mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/twirp/livekit.RoomService/ListRooms":
+			data, _ := proto.Marshal(&lkproto.ListRoomsResponse{Rooms: []*lkproto.Room{{Name: "standup"}}})
+			w.Header().Set("Content-Type", "application/protobuf")
+			w.Write(data)
+		case strings.HasPrefix(r.URL.Path, "/twirp/"):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not_found","msg":"room not found"}`))
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Write([]byte(`{"valid":true}`))
+		}
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *upstream) traceparentFor(path string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.traceparent[path]
+}
+
+// newTestServer serves GET /rooms/:room, which calls Convex over HTTP,
+// LiveKit over Twirp and R2 through the AWS SDK, each instrumented the
+// way the service instruments them
+func newTestServer(up *upstream) *echo.Echo {
+	httpClient := &http.Client{Transport: Transport("convex", nil, nil)}
+	rooms := lksdk.NewRoomServiceClient(up.URL, "key", "secretsecretsecretsecretsecretsecret",
+		twirp.WithClientHooks(TwirpHooks("livekit")))
+	r2 := s3.New(s3.Options{
+		BaseEndpoint: aws.String(up.URL),
+		Region:       "auto",
+		Credentials:  credentials.NewStaticCredentialsProvider("a", "b", ""),
+		UsePathStyle: true,
+		APIOptions:   []func(*smithymiddleware.Stack) error{AWSMiddleware("r2")},
+	})
+
+	e := echo.New()
+	e.Use(Middleware())
+	e.GET("/rooms/:room", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, up.URL+"/api/validate-session", nil)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if _, err := rooms.ListRooms(ctx, &lkproto.ListRoomsRequest{Names: []string{c.Param("room")}}); err != nil {
+			return err
+		}
+		// Fails with a Twirp error
+		rooms.DeleteRoom(ctx, &lkproto.DeleteRoomRequest{Room: c.Param("room")})
+
+		if _, err := r2.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("media")}); err != nil {
+			return err
+		}
+		if c.QueryParam("fail") != "" {
+			return c.String(http.StatusInternalServerError, "failed")
+		}
+		return c.String(http.StatusOK, "ok")
+	})
+	return e
+}
+
+func TestRequestSpanParentsOutboundSpans(t *testing.T) {
+	exporter := setupTest(t)
+	up := newUpstream(t)
+	e := newTestServer(up)
+
+	req := httptest.NewRequest(http.MethodGet, "/rooms/standup", nil)
+	req.Header.Set("traceparent", "00-"+incomingTraceID+"-"+incomingSpanID+"-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	spans := spansByName(t, exporter.GetSpans())
+	server := spans["GET /rooms/:room"]
+	if server == nil {
+		t.Fatalf("no request span among %v", names(exporter.GetSpans()))
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("request span kind = %v, want server", server.SpanKind)
+	}
+	if got := server.SpanContext.TraceID().String(); got != incomingTraceID {
+		t.Errorf("request trace = %s, want the incoming %s", got, incomingTraceID)
+	}
+	if got := server.Parent.SpanID().String(); got != incomingSpanID || !server.Parent.IsRemote() {
+		t.Errorf("request parent = %s (remote %v), want the incoming span %s", got, server.Parent.IsRemote(), incomingSpanID)
+	}
+	wantAttributes(t, server, map[attribute.Key]string{
+		"http.route":                "/rooms/:room",
+		"http.request.method":       "GET",
+		"url.path":                  "/rooms/standup",
+		"http.response.status_code": "200",
+	})
+	if server.Status.Code == codes.Error {
+		t.Errorf("request span status = %v, want unset for a 200", server.Status)
+	}
+
+	children := []struct {
+		name       string
+		path       string
+		propagated bool
+		attrs      map[attribute.Key]string
+	}{
+		{"convex /api/validate-session", "/api/validate-session", true, map[attribute.Key]string{
+			"peer.service":              "convex",
+			"http.request.method":       "POST",
+			"http.response.status_code": "200",
+		}},
+		{"livekit ListRooms", "/twirp/livekit.RoomService/ListRooms", true, map[attribute.Key]string{
+			"peer.service": "livekit",
+			"rpc.system":   "twirp",
+			"rpc.service":  "RoomService",
+			"rpc.method":   "ListRooms",
+		}},
+		{"livekit DeleteRoom", "/twirp/livekit.RoomService/DeleteRoom", true, map[attribute.Key]string{
+			"rpc.method": "DeleteRoom",
+		}},
+		{"r2 HeadBucket", "/media", false, map[attribute.Key]string{
+			"peer.service": "r2",
+			"rpc.system":   "aws-api",
+			"rpc.method":   "HeadBucket",
+		}},
+	}
+	for _, want := range children {
+		span := spans[want.name]
+		if span == nil {
+			t.Errorf("no %q span among %v", want.name, names(exporter.GetSpans()))
+			continue
+		}
+		if span.SpanKind != trace.SpanKindClient {
+			t.Errorf("%s kind = %v, want client", want.name, span.SpanKind)
+		}
+		if span.Parent.SpanID() != server.SpanContext.SpanID() || span.SpanContext.TraceID() != server.SpanContext.TraceID() {
+			t.Errorf("%s parent = %s, want the request span %s", want.name, span.Parent.SpanID(), server.SpanContext.SpanID())
+		}
+		wantAttributes(t, span, want.attrs)
+
+		// Convex and LiveKit continue the trace from the client span; R2
+		// is not sent trace context
+		if !want.propagated {
+			continue
+		}
+		traceparent := up.traceparentFor(want.path)
+		if wantPrefix := "00-" + incomingTraceID + "-" + span.SpanContext.SpanID().String() + "-"; !strings.HasPrefix(traceparent, wantPrefix) {
+			t.Errorf("%s traceparent = %q, want %s...", want.name, traceparent, wantPrefix)
+		}
+	}
+
+	if span := spans["livekit DeleteRoom"]; span != nil && (span.Status.Code != codes.Error || span.Status.Description != "not_found") {
+		t.Errorf("failed Twirp call status = %v, want error not_found", span.Status)
+	}
+	if span := spans["livekit ListRooms"]; span != nil && span.Status.Code == codes.Error {
+		t.Errorf("ListRooms status = %v, want unset", span.Status)
+	}
+	// Every span has ended, so none is left open by the hooks
+	if got, want := len(exporter.GetSpans()), 1+len(children); got != want {
+		t.Errorf("exported %d spans %v, want %d", got, names(exporter.GetSpans()), want)
+	}
+}
+
+func TestRequestSpanMarksServerErrors(t *testing.T) {
+	exporter := setupTest(t)
+	e := newTestServer(newUpstream(t))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rooms/standup?fail=1", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+
+	server := spansByName(t, exporter.GetSpans())["GET /rooms/:room"]
+	if server == nil {
+		t.Fatalf("no request span among %v", names(exporter.GetSpans()))
+	}
+	if server.Status.Code != codes.Error {
+		t.Errorf("request span status = %v, want error", server.Status)
+	}
+	if server.Parent.IsValid() {
+		t.Errorf("request span parent = %s, want a new trace without traceparent", server.Parent.SpanID())
+	}
+	wantAttributes(t, server, map[attribute.Key]string{"http.response.status_code": "500"})
+}
+
+func spansByName(t *testing.T, spans tracetest.SpanStubs) map[string]*tracetest.SpanStub {
+	t.Helper()
+	out := make(map[string]*tracetest.SpanStub, len(spans))
+	for i := range spans {
+		if _, ok := out[spans[i].Name]; ok {
+			t.Errorf("span %q exported more than once", spans[i].Name)
+		}
+		out[spans[i].Name] = &spans[i]
+	}
+	return out
+}
+
+func names(spans tracetest.SpanStubs) []string {
+	out := make([]string, len(spans))
+	for i, span := range spans {
+		out[i] = span.Name
+	}
+	return out
+}
+
+func wantAttributes(t *testing.T, span *tracetest.SpanStub, want map[attribute.Key]string) {
+	t.Helper()
+	got := make(map[attribute.Key]string, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		got[kv.Key] = kv.Value.Emit()
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s %s = %q, want %q", span.Name, key, got[key], value)
+		}
+	}
+}
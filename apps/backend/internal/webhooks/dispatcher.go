@@ -0,0 +1,265 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"myapp/internal/background"
+	"myapp/internal/config"
+	"myapp/internal/metrics"
+
+	lkproto "github.com/livekit/protocol/livekit"
+)
+
+// AllEvents subscribes a handler to every event type
+const AllEvents = "*"
+
+const (
+	// handlerTimeout bounds a single attempt of a handler
+	handlerTimeout = 30 * time.Second
+	// maxRetryBackoff caps the doubling delay between attempts
+	maxRetryBackoff = 5 * time.Minute
+)
+
+// ErrQueueFull is returned by Dispatch when an event's handlers do not fit
+// in the queue
+var ErrQueueFull = errors.New("webhook queue full")
+
+// Handler reacts to a verified webhook event. A returned error is retried
+// with backoff unless it is wrapped with Permanent.
+type Handler func(ctx context.Context, event *lkproto.WebhookEvent) error
+
+// Permanent marks err as not worth retrying
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Options tunes a Dispatcher
+type Options struct {
+	// QueueSize bounds the jobs waiting for a worker
+	QueueSize int
+	// Workers is how many handlers run at once
+	Workers int
+	// MaxAttempts bounds how often a failing handler is run for one event
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles after
+	// every further failure
+	RetryBackoff time.Duration
+}
+
+type subscription struct {
+	name   string
+	handle Handler
+}
+
+// job is one handler's run for one event. done marks it finished in the
+// background tracker, whether it succeeded, failed or was dropped.
+type job struct {
+	sub     subscription
+	event   *lkproto.WebhookEvent
+	attempt int
+	done    func()
+}
+
+// Dispatcher runs the handlers subscribed to each webhook event type on a
+// pool of workers fed by a bounded queue, so receiving a webhook never
+// waits on them. Every queued job is tracked until it finishes, so a
+// graceful shutdown waits for handlers and their retries.
+type Dispatcher struct {
+	opts   Options
+	tasks  *background.Tracker
+	logger *slog.Logger
+	queue  chan *job
+	// enqueueMu makes Dispatch queue all of an event's handlers or none
+	enqueueMu sync.Mutex
+
+	mu   sync.RWMutex
+	subs map[string][]subscription
+
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDispatcher creates a dispatcher and starts its workers
+func NewDispatcher(opts Options, tasks *background.Tracker, logger *slog.Logger) *Dispatcher {
+	d := &Dispatcher{
+		opts:     opts,
+		tasks:    tasks,
+		logger:   logger,
+		queue:    make(chan *job, opts.QueueSize),
+		subs:     make(map[string][]subscription),
+		stopping: make(chan struct{}),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// New creates the dispatcher described by the webhook settings, with a
+// Forwarder subscribed to every event for each WEBHOOK_FORWARD_URLS entry
+func New(cfg *config.Config, tasks *background.Tracker, logger *slog.Logger) *Dispatcher {
+	d := NewDispatcher(Options{
+		QueueSize:    cfg.WebhookQueueSize,
+		Workers:      cfg.WebhookWorkers,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		RetryBackoff: cfg.WebhookRetryBackoff,
+	}, tasks, logger)
+	for _, target := range cfg.WebhookForwardURLs {
+		forwarder := NewForwarder(target, cfg.WebhookForwardSecret)
+		d.Subscribe(forwarder.Name(), forwarder.Handle, AllEvents)
+	}
+	return d
+}
+
+// Subscribe runs handle for every event of the given types, or of any type
+// with AllEvents. name identifies the handler in logs and metrics.
+func (d *Dispatcher) Subscribe(name string, handle Handler, eventTypes ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, eventType := range eventTypes {
+		d.subs[eventType] = append(d.subs[eventType], subscription{name: name, handle: handle})
+	}
+}
+
+// Dispatch queues event for every handler subscribed to its type and
+// returns without waiting for them. When they do not all fit in the queue
+// none are queued and ErrQueueFull is returned, so the sender can retry
+// the event later.
+func (d *Dispatcher) Dispatch(event *lkproto.WebhookEvent) error {
+	d.mu.RLock()
+	subs := append(append([]subscription(nil), d.subs[event.GetEvent()]...), d.subs[AllEvents]...)
+	d.mu.RUnlock()
+
+	// Workers only take from the queue, so the free space can only grow
+	// while the lock is held
+	d.enqueueMu.Lock()
+	defer d.enqueueMu.Unlock()
+	if free := cap(d.queue) - len(d.queue); len(subs) > free {
+		for _, sub := range subs {
+			metrics.WebhookJob(sub.name, "dropped")
+		}
+		return fmt.Errorf("%w: %d handlers, %d free", ErrQueueFull, len(subs), free)
+	}
+	for _, sub := range subs {
+		d.enqueue(&job{sub: sub, event: event, attempt: 1})
+	}
+	return nil
+}
+
+// Close stops waiting out retry delays: handlers due a retry get their last
+// attempt straight away, so a shutdown drain does not sit through backoff.
+// Queued handlers still run.
+func (d *Dispatcher) Close() {
+	d.stopOnce.Do(func() { close(d.stopping) })
+}
+
+// enqueue queues j, or drops it when the queue is full. Callers hold
+// enqueueMu.
+func (d *Dispatcher) enqueue(j *job) bool {
+	if j.done == nil {
+		j.done = d.tasks.Track("webhook " + j.event.GetEvent() + " " + j.sub.name)
+	}
+	select {
+	case d.queue <- j:
+		metrics.WebhookQueueDepth(len(d.queue))
+		return true
+	default:
+		j.done()
+		metrics.WebhookJob(j.sub.name, "dropped")
+		d.logger.Error("webhook queue full, dropping handler",
+			"event", j.event.GetEvent(), "event_id", j.event.GetId(), "handler", j.sub.name, "attempt", j.attempt)
+		return false
+	}
+}
+
+func (d *Dispatcher) work() {
+	ctx := d.tasks.Context()
+	for {
+		select {
+		case j := <-d.queue:
+			metrics.WebhookQueueDepth(len(d.queue))
+			d.run(ctx, j)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// run attempts a job once, then finishes it or schedules its retry
+func (d *Dispatcher) run(ctx context.Context, j *job) {
+	logger := d.logger.With("event", j.event.GetEvent(), "event_id", j.event.GetId(), "handler", j.sub.name, "attempt", j.attempt)
+
+	err := d.call(ctx, j)
+	var permanent *permanentError
+	switch {
+	case err == nil:
+		metrics.WebhookJob(j.sub.name, "success")
+		j.done()
+	case errors.As(err, &permanent) || j.attempt >= d.opts.MaxAttempts || d.stopped() || ctx.Err() != nil:
+		metrics.WebhookJob(j.sub.name, "failed")
+		logger.ErrorContext(ctx, "webhook handler failed", "error", err)
+		j.done()
+	default:
+		delay := d.backoff(j.attempt)
+		metrics.WebhookJob(j.sub.name, "retried")
+		logger.WarnContext(ctx, "webhook handler failed, retrying", "error", err, "retry_in", delay.String())
+		j.attempt++
+		go d.retry(j, delay)
+	}
+}
+
+// call runs the handler with a timeout, turning a panic into a permanent
+// failure
+func (d *Dispatcher) call(ctx context.Context, j *job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return j.sub.handle(ctx, j.event)
+}
+
+// retry re-queues a job after delay, or at once when the dispatcher is
+// closing
+func (d *Dispatcher) retry(j *job, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-d.stopping:
+	}
+	d.enqueueMu.Lock()
+	defer d.enqueueMu.Unlock()
+	d.enqueue(j)
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.opts.RetryBackoff
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+func (d *Dispatcher) stopped() bool {
+	select {
+	case <-d.stopping:
+		return true
+	default:
+		return false
+	}
+}
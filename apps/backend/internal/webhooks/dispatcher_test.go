@@ -0,0 +1,173 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"myapp/internal/background"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// attempts is a fake handler that fails a set number of times before
+// succeeding, recording when each run started
+type attempts struct {
+	mu       sync.Mutex
+	failures int
+	err      error
+	runs     []time.Time
+	started  chan struct{}
+}
+
+func newAttempts(failures int, err error) *attempts {
+	return &attempts{failures: failures, err: err, started: make(chan struct{}, 16)}
+}
+
+func (a *attempts) handle(context.Context, *lkproto.WebhookEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.runs = append(a.runs, time.Now())
+	a.started <- struct{}{}
+	if len(a.runs) <= a.failures {
+		return a.err
+	}
+	return nil
+}
+
+func (a *attempts) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.runs)
+}
+
+func event(id, eventType string) *lkproto.WebhookEvent {
+	return &lkproto.WebhookEvent{Id: id, Event: eventType, Room: &lkproto.Room{Name: "standup"}}
+}
+
+func drain(t *testing.T, tasks *background.Tracker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if abandoned := tasks.Drain(ctx); abandoned != nil {
+		t.Fatalf("handlers still running: %v", abandoned)
+	}
+}
+
+func TestDispatchQueuesAllHandlersOrNone(t *testing.T) {
+	// Without workers nothing leaves the queue
+	tasks := background.NewTracker()
+	d := NewDispatcher(Options{QueueSize: 2, MaxAttempts: 1}, tasks, testLogger)
+	noop := func(context.Context, *lkproto.WebhookEvent) error { return nil }
+	d.Subscribe("first", noop, webhook.EventRoomStarted)
+	d.Subscribe("second", noop, webhook.EventRoomStarted)
+	d.Subscribe("all", noop, AllEvents)
+
+	if err := d.Dispatch(event("EV_1", webhook.EventRoomStarted)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("dispatching three handlers into two slots = %v, want ErrQueueFull", err)
+	}
+	if n := len(d.queue); n != 0 {
+		t.Fatalf("%d jobs queued for a refused event, want none", n)
+	}
+
+	if err := d.Dispatch(event("EV_2", webhook.EventParticipantJoined)); err != nil {
+		t.Fatalf("dispatching one handler = %v", err)
+	}
+	if err := d.Dispatch(event("EV_3", webhook.EventRoomStarted)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("dispatching three handlers into one slot = %v, want ErrQueueFull", err)
+	}
+	if err := d.Dispatch(event("EV_4", webhook.EventParticipantJoined)); err != nil {
+		t.Fatalf("dispatching into the last slot = %v", err)
+	}
+	if n := len(tasks.Running()); n != 2 {
+		t.Errorf("%d jobs tracked, want the 2 queued", n)
+	}
+}
+
+func TestDispatchRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures before the handler succeeds
+		failures int
+		err      error
+		want     int
+	}{
+		{"succeeds at once", 0, nil, 1},
+		{"succeeds on the last attempt", 2, errors.New("unavailable"), 3},
+		{"gives up after max attempts", 5, errors.New("unavailable"), 3},
+		{"permanent failure", 5, Permanent(errors.New("bad event")), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := background.NewTracker()
+			d := NewDispatcher(Options{QueueSize: 4, Workers: 1, MaxAttempts: 3, RetryBackoff: 20 * time.Millisecond}, tasks, testLogger)
+			handler := newAttempts(tt.failures, tt.err)
+			d.Subscribe("flaky", handler.handle, webhook.EventRoomStarted)
+
+			if err := d.Dispatch(event("EV_1", webhook.EventRoomStarted)); err != nil {
+				t.Fatal(err)
+			}
+			drain(t, tasks)
+
+			if n := handler.count(); n != tt.want {
+				t.Fatalf("handler ran %d times, want %d", n, tt.want)
+			}
+			// The delay doubles after every failure
+			for i := 1; i < len(handler.runs); i++ {
+				want := 20 * time.Millisecond << (i - 1)
+				if gap := handler.runs[i].Sub(handler.runs[i-1]); gap < want {
+					t.Errorf("attempt %d ran %s after the last, want at least %s", i+1, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchRecoversPanics(t *testing.T) {
+	tasks := background.NewTracker()
+	d := NewDispatcher(Options{QueueSize: 4, Workers: 1, MaxAttempts: 3, RetryBackoff: time.Millisecond}, tasks, testLogger)
+	var runs int
+	d.Subscribe("panics", func(context.Context, *lkproto.WebhookEvent) error {
+		runs++
+		panic("boom")
+	}, webhook.EventRoomStarted)
+
+	if err := d.Dispatch(event("EV_1", webhook.EventRoomStarted)); err != nil {
+		t.Fatal(err)
+	}
+	drain(t, tasks)
+	if runs != 1 {
+		t.Errorf("panicking handler ran %d times, want 1", runs)
+	}
+}
+
+func TestCloseSkipsRetryBackoff(t *testing.T) {
+	tasks := background.NewTracker()
+	d := NewDispatcher(Options{QueueSize: 4, Workers: 1, MaxAttempts: 5, RetryBackoff: time.Hour}, tasks, testLogger)
+	handler := newAttempts(5, errors.New("unavailable"))
+	d.Subscribe("failing", handler.handle, webhook.EventRoomStarted)
+
+	if err := d.Dispatch(event("EV_1", webhook.EventRoomStarted)); err != nil {
+		t.Fatal(err)
+	}
+	// Let the first attempt fail and its hour-long retry be scheduled
+	<-handler.started
+	time.Sleep(20 * time.Millisecond)
+
+	d.Close()
+	start := time.Now()
+	drain(t, tasks)
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("drain waited %s for the retry delay", waited)
+	}
+	if n := handler.count(); n != 2 {
+		t.Errorf("handler ran %d times, want one last attempt after Close", n)
+	}
+}
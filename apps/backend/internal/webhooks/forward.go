@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"myapp/internal/metrics"
+
+	lkproto "github.com/livekit/protocol/livekit"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Headers sent with forwarded events
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of
+	// "<timestamp>.<body>" under the shared secret
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader is when the request was signed, in Unix seconds, so
+	// receivers can reject replays
+	TimestampHeader = "X-Webhook-Timestamp"
+	// EventHeader and IDHeader repeat the event type and ID, the latter for
+	// receivers to ignore redeliveries
+	EventHeader = "X-Webhook-Event"
+	IDHeader    = "X-Webhook-Id"
+)
+
+// forwardTimeout bounds a single delivery
+const forwardTimeout = 10 * time.Second
+
+// Forwarder posts webhook events to an HTTP endpoint in LiveKit's JSON
+// format, signed with HMAC-SHA256 so the receiver can verify them
+type Forwarder struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewForwarder creates a forwarder to target signing with secret
+func NewForwarder(target, secret string) *Forwarder {
+	return &Forwarder{
+		url:    target,
+		secret: []byte(secret),
+		client: &http.Client{
+			Timeout:   forwardTimeout,
+			Transport: metrics.Transport("webhook_forward", func(*http.Request) string { return "forward" }, nil),
+		},
+	}
+}
+
+// Name identifies the forwarder in logs and metrics by the endpoint's host,
+// leaving out any credentials in the URL
+func (f *Forwarder) Name() string {
+	if u, err := url.Parse(f.url); err == nil {
+		return "forward " + u.Host
+	}
+	return "forward"
+}
+
+// Handle delivers event. Network errors and 408, 429 and 5xx responses are
+// retried; other responses outside 2xx are not.
+func (f *Forwarder) Handle(ctx context.Context, event *lkproto.WebhookEvent) error {
+	body, err := protojson.Marshal(event)
+	if err != nil {
+		return Permanent(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.GetEvent())
+	req.Header.Set(IDHeader, event.GetId())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(f.secret, timestamp, body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	default:
+		return Permanent(fmt.Errorf("endpoint responded %s", resp.Status))
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" under secret,
+// as sent in SignatureHeader
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,47 +1,237 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"myapp/internal/background"
 	"myapp/internal/config"
+	"myapp/internal/events"
+	"myapp/internal/invite"
 	"myapp/internal/livekit"
+	"myapp/internal/lobby"
+	"myapp/internal/logging"
 	"myapp/internal/router"
+	"myapp/internal/serviceauth"
+	"myapp/internal/session"
 	"myapp/internal/storage"
+	"myapp/internal/tracing"
+	"myapp/internal/webhooks"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 )
 
-func main() {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+// runServe runs the HTTP server until it is stopped by a signal
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	// Load and validate configuration
+	opts := common.options()
+	cfg, err := config.LoadWith(opts)
+	if err != nil {
+		return err
 	}
+	settings := config.NewReloader(cfg, opts)
 
-	// Load configuration
-	cfg := config.Load()
+	// Structured logging; the level follows LOG_LEVEL across reloads
+	level := new(slog.LevelVar)
+	level.Set(logging.ParseLevel(cfg.LogLevel))
+	logger, err := logging.New(os.Stdout, cfg.LogFormat, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	settings.OnReload(func(rt *config.Runtime) {
+		level.Set(logging.ParseLevel(rt.LogLevel))
+	})
 
-	// Initialize LiveKit client
-	client := livekit.NewClient(cfg)
+	// Tracing; disabled unless TRACING_EXPORTER is set
+	traces, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+
+	// Signed service identities for internal callers
+	services, err := serviceauth.NewVerifier(cfg)
+	if err != nil {
+		return err
+	}
+	if services != nil {
+		defer services.Close()
+	}
+	if cfg.DevTrustUserIDHeader {
+		logger.Warn("DEV_TRUST_USER_ID_HEADER is enabled: any caller can act as any user")
+	}
+
+	// Session validation cache, shared through Redis when configured
+	sessions, err := session.New(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer sessions.Close()
+
+	// Meeting invites, shared through Redis when configured
+	invites, err := invite.New(cfg)
+	if err != nil {
+		return err
+	}
+	if invites != nil {
+		defer invites.Close()
+	}
+
+	// Lobby tickets, shared through Redis when configured
+	lobbies, err := lobby.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer lobbies.Close()
+
+	// Webhook event history, on local disk when configured
+	eventStore, err := events.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer eventStore.Close()
+	feed := events.NewFeed(cfg.EventStreamBuffer)
+
+	// Initialize LiveKit client, with room ACLs shared through Redis when
+	// configured
+	acls, err := livekit.NewACLStore(cfg)
+	if err != nil {
+		return err
+	}
+	client := livekit.NewClient(cfg, acls)
+	defer client.Close()
 
 	// Initialize R2 client (optional - only if configured)
 	var r2 *storage.R2Client
-	if cfg.R2Endpoint != "" && cfg.R2AccessKeyID != "" {
-		var err error
+	if cfg.R2Enabled() {
 		r2, err = storage.NewR2Client(cfg)
 		if err != nil {
-			log.Printf("Warning: Failed to initialize R2 client: %v", err)
+			logger.Warn("failed to initialize R2 client", "error", err)
 		} else {
-			log.Println("R2 storage client initialized")
+			logger.Info("R2 storage client initialized")
 		}
 	}
 
 	// Create Echo instance
 	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	// Event streams never finish on their own, so end them when shutdown
+	// starts rather than at the drain deadline
+	e.Server.RegisterOnShutdown(feed.Close)
+
+	// Webhook handlers run in the background, tracked for shutdown
+	tasks := background.NewTracker()
+	dispatcher := webhooks.New(cfg, tasks, logger)
 
 	// Setup routes
-	router.Setup(e, client, r2, cfg)
+	groups := router.Setup(e, router.Deps{
+		Client:   client,
+		R2:       r2,
+		Config:   cfg,
+		Settings: settings,
+		Tasks:    tasks,
+		Services: services,
+		Sessions: sessions,
+		Invites:  invites,
+		Lobby:    lobbies,
+		Events:   eventStore,
+		Feed:     feed,
+		Webhooks: dispatcher,
+		Traces:   traces.Recorder,
+		Logger:   logger,
+	})
+
+	// Startup summary (secrets redacted)
+	for _, line := range cfg.Summary() {
+		logger.Info("config", "subsystem", line)
+	}
+	logger.Info("enabled route groups", "groups", strings.Join(groups, ", "))
 
-	// Start server
-	e.Logger.Fatal(e.Start(cfg.Port))
+	// Reload runtime settings on SIGHUP
+	go reloadOnSignal(logger, settings)
+
+	// Start server and wait for SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("http server started", "addr", cfg.Port)
+		serverErr <- e.Start(cfg.Port)
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+		stop()
+		// Returned rather than exiting here so the stores above are
+		// closed before main exits non-zero
+		return shutdown(logger, e, tasks, dispatcher, traces, cfg.ShutdownTimeout)
+	}
+	return nil
+}
+
+// shutdown stops accepting connections, then waits up to timeout for
+// in-flight requests and tracked background work, including webhook
+// handlers. It returns an error when any of it had to be abandoned.
+func shutdown(logger *slog.Logger, e *echo.Echo, tasks *background.Tracker, dispatcher *webhooks.Dispatcher, traces *tracing.Provider, timeout time.Duration) error {
+	logger.Info("shutting down", "drain_timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := e.Shutdown(ctx); err != nil {
+		logger.Error("HTTP server did not drain cleanly", "error", err)
+	}
+
+	// No more webhooks arrive; give handlers due a retry their last attempt
+	// now rather than after their backoff
+	dispatcher.Close()
+	abandoned := tasks.Drain(ctx)
+
+	// Flush spans from the drained work; the drain may have used up ctx
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := traces.Shutdown(flushCtx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
+
+	if len(abandoned) == 0 {
+		logger.Info("shutdown complete")
+		return nil
+	}
+	for _, a := range abandoned {
+		logger.Error("task abandoned at shutdown deadline", "task", a.Name, "running", a.Running)
+	}
+	return fmt.Errorf("%d tasks abandoned at shutdown deadline", len(abandoned))
+}
+
+// reloadOnSignal reloads the runtime settings whenever SIGHUP is received
+func reloadOnSignal(logger *slog.Logger, settings *config.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		result, err := settings.Reload()
+		if err != nil {
+			logger.Error("config reload failed, keeping current settings", "error", err)
+			continue
+		}
+		logger.Info("config reloaded", "changed", result.Changed, "requires_restart", result.RequiresRestart)
+	}
 }